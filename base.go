@@ -1,7 +1,13 @@
 package processors
 
 import (
-	"github.com/mitchellh/mapstructure"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/veino/processors/metrics"
 	"github.com/veino/veino"
 	"gopkg.in/go-playground/validator.v8"
 )
@@ -9,7 +15,22 @@ import (
 type Base struct {
 	Send      veino.PacketSender
 	NewPacket veino.PacketBuilder
-	Logger    logger
+	Logger    Logger
+	Metrics   *metrics.Scope
+
+	name     string
+	instance string
+	failures *metrics.Counter
+
+	// counted by Receive
+	received        *metrics.Counter
+	receiveErrors   *metrics.Counter
+	receiveDuration *metrics.Histogram
+
+	// describing stops ConfigureAndValidate once options hold their
+	// defaults, which are kept in described, see Describe
+	describing bool
+	described  interface{}
 }
 
 func (b *Base) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error { return nil }
@@ -24,36 +45,115 @@ func (b *Base) Stop(e veino.IPacket) error { return nil }
 
 func (b *Base) ConfigureAndValidate(ctx veino.ProcessorContext, conf map[string]interface{}, rawVal interface{}) error {
 
-	// Logger
+	// Logger and metrics, identified with the processor's name, and metrics
+	// with the instance, the "id" option or a number, to tell processors of
+	// the same name apart
+	var std StdLogger = DefaultLogger
 	if ctx.Logger != nil {
-		b.Logger = ctx.Logger()
+		std = ctx.Logger()
+	}
+	name := processorName(rawVal)
+	if id, ok := conf["id"]; ok {
+		b.instance = fmt.Sprintf("%v", id)
+		conf = withoutOption(conf, "id")
+	} else if b.instance == "" && !b.describing {
+		b.instance = nextInstance(name)
+	}
+	if name != "" {
+		b.Logger = NewLogger(std, "processor", name)
+		b.Metrics = metrics.Default.Scope("processor", name, "instance", b.instance)
 	} else {
-		b.Logger = DefaultLogger
+		b.Logger = NewLogger(std)
+		b.Metrics = metrics.Default.Scope()
 	}
+	b.name = name
+	b.failures = b.Metrics.Counter("processor_failures_total", "Events the processor failed to process")
+	b.received = b.Metrics.Counter("processor_events_received_total", "Events received by the processor")
+	b.receiveErrors = b.Metrics.Counter("processor_receive_errors_total", "Errors returned by the processor while receiving events")
+	b.receiveDuration = b.Metrics.Histogram("processor_receive_duration_seconds", "Time spent by the processor to receive an event", nil)
 
-	// Packet Sender func
+	// Packet Sender func, counting sent packets
 	if ctx.PacketSender != nil {
-		b.Send = ctx.PacketSender()
+		b.Send = countingSender(ctx.PacketSender(), b.Metrics)
 	} else {
-		// TODO set a dummy packetSender
+		b.Send = countingSender(discardPackets, b.Metrics)
 	}
 
 	// Packet Builder func
 	if ctx.PacketBuilder != nil {
 		b.NewPacket = ctx.PacketBuilder()
 	} else {
-		// TODO set a dummy PacketBuilder
+		b.NewPacket = NewPacket
 	}
 
-	// Set processor's user options
-	if err := mapstructure.Decode(conf, rawVal); err != nil {
+	if b.describing {
+		b.described = rawVal
+		return errDescribing
+	}
+
+	// Expand ${VAR} and ${secret:name} references of user options
+	conf, err := expandOptions(name, conf)
+	if err != nil {
+		return err
+	}
+
+	// Set processor's user options, unknown or mistyped options are errors
+	if err := decodeOptions(name, conf, rawVal); err != nil {
 		return err
 	}
 
 	// validates processor's user options
 	if err := validator.New(&validator.Config{TagName: "validate"}).Struct(rawVal); err != nil {
+		return validationErrors(name, rawVal, err)
+	}
+	if err := validateEnums(name, rawVal); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// textUnmarshalerHook sets options implementing encoding.TextUnmarshaler from
+// their string value (ie: codec)
+func textUnmarshalerHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || !reflect.PtrTo(to).Implements(textUnmarshalerType) {
+		return data, nil
+	}
+
+	v := reflect.New(to)
+	if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(data.(string))); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// discardPackets is the PacketSender used when the runtime does not provide
+// one, packets are dropped
+func discardPackets(veino.IPacket, ...int) bool { return false }
+
+// instances counts the configured processors, by name
+var instances = struct {
+	sync.Mutex
+	count map[string]int
+}{count: map[string]int{}}
+
+// nextInstance returns the number of a new processor named name, from 1
+func nextInstance(name string) string {
+	instances.Lock()
+	defer instances.Unlock()
+	instances.count[name]++
+	return strconv.Itoa(instances.count[name])
+}
+
+// withoutOption returns a copy of conf without key
+func withoutOption(conf map[string]interface{}, key string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(conf))
+	for k, v := range conf {
+		if k != key {
+			copied[k] = v
+		}
+	}
+	return copied
+}
@@ -0,0 +1,93 @@
+// grok-debug proposes grok expressions for sample lines, and tells why lines
+// do not match a grok pattern, with the patterns of filter-grok.
+//
+//	grok-debug [-patterns_dir dir] discover < samples.log
+//	grok-debug [-patterns_dir dir] debug '%{IP:client} %{WORD:verb}' < samples.log
+//
+// Lines are read from stdin.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	grok "github.com/veino/processors/filter-grok"
+)
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(s string) error { *l = append(*l, s); return nil }
+
+func main() {
+	var patternsDir stringList
+	flag.Var(&patternsDir, "patterns_dir", "directory of pattern files, may be repeated")
+	flag.Parse()
+
+	if err := run(patternsDir, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(patternsDir []string, args []string) error {
+	if len(args) == 0 || args[0] == "debug" && len(args) != 2 {
+		return fmt.Errorf("usage : grok-debug [-patterns_dir dir] discover | debug pattern")
+	}
+
+	d, err := grok.NewDebugger(patternsDir, nil)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "discover":
+		expression, err := d.Discover(lines)
+		fmt.Println(expression)
+		return err
+	case "debug":
+		for _, line := range lines {
+			result, err := d.Debug(args[1], line)
+			if err != nil {
+				return err
+			}
+			printResult(line, result)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %s", args[0])
+}
+
+func printResult(line string, result *grok.DebugResult) {
+	if result.Matched {
+		fmt.Printf("match   %s\n", line)
+	} else {
+		fmt.Printf("NO MATCH %s\n", line)
+		fmt.Printf("  matching : %s\n", result.Matching)
+		fmt.Printf("  failing  : %s at %q\n", result.Failing, line[result.Offset:])
+	}
+
+	names := make([]string, 0, len(result.Captures))
+	for name := range result.Captures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %s = %q\n", name, result.Captures[name])
+	}
+}
@@ -0,0 +1,161 @@
+// processors-doc writes the documentation of processors : the Options
+// section of their README.md and the JSON Schema of their options, in
+// schema.json.
+//
+//	processors-doc [-root dir] [-write] [processor ...]
+//
+// Without -write, the Markdown of each processor is printed. Processors are
+// all known processors when none is given.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/veino/processors"
+	date "github.com/veino/processors/filter-date"
+	drop "github.com/veino/processors/filter-drop"
+	geoip "github.com/veino/processors/filter-geoip"
+	grok "github.com/veino/processors/filter-grok"
+	json "github.com/veino/processors/filter-json"
+	mutate "github.com/veino/processors/filter-mutate"
+	split "github.com/veino/processors/filter-split"
+	uuid "github.com/veino/processors/filter-uuid"
+	amqpinput "github.com/veino/processors/input-amqp"
+	beatsinput "github.com/veino/processors/input-beats"
+	execinput "github.com/veino/processors/input-exec"
+	fileinput "github.com/veino/processors/input-file"
+	httppoller "github.com/veino/processors/input-httppoller"
+	imapinput "github.com/veino/processors/input-imap"
+	stdin "github.com/veino/processors/input-stdin"
+	twitter "github.com/veino/processors/input-twitter"
+	elasticsearch "github.com/veino/processors/output-elasticsearch"
+	elasticsearch2 "github.com/veino/processors/output-elasticsearch2"
+	fileoutput "github.com/veino/processors/output-file"
+	mongodb "github.com/veino/processors/output-mongodb"
+	null "github.com/veino/processors/output-null"
+	rabbitmqoutput "github.com/veino/processors/output-rabbitmq"
+	stdout "github.com/veino/processors/output-stdout"
+	"github.com/veino/processors/schema"
+	"github.com/veino/processors/when"
+	"github.com/veino/veino"
+)
+
+// known processors, by directory
+var known = map[string]func() veino.Processor{
+	"filter-date":           date.New,
+	"filter-drop":           drop.New,
+	"filter-geoip":          geoip.New,
+	"filter-grok":           grok.New,
+	"filter-json":           json.New,
+	"filter-mutate":         mutate.New,
+	"filter-split":          split.New,
+	"filter-uuid":           uuid.New,
+	"input-amqp":            amqpinput.New,
+	"input-beats":           beatsinput.New,
+	"input-exec":            execinput.New,
+	"input-file":            fileinput.New,
+	"input-httppoller":      httppoller.New,
+	"input-imap":            imapinput.New,
+	"input-stdin":           stdin.New,
+	"input-twitter":         twitter.New,
+	"output-elasticsearch":  elasticsearch.New,
+	"output-elasticsearch2": elasticsearch2.New,
+	"output-file":           fileoutput.New,
+	"output-mongodb":        mongodb.New,
+	"output-null":           null.New,
+	"output-rabbitmq":       rabbitmqoutput.New,
+	"output-stdout":         stdout.New,
+	"when":                  when.New,
+}
+
+const (
+	beginMarker = "<!-- begin options, generated by processors-doc -->"
+	endMarker   = "<!-- end options -->"
+)
+
+func main() {
+	root := flag.String("root", ".", "directory holding processors sources")
+	write := flag.Bool("write", false, "write README.md and schema.json of each processor")
+	flag.Parse()
+
+	names := flag.Args()
+	if len(names) == 0 {
+		for name := range known {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		if err := document(*root, name, *write); err != nil {
+			fmt.Fprintf(os.Stderr, "%s : %s\n", name, err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+func document(root string, name string, write bool) error {
+	newProcessor, ok := known[name]
+	if !ok {
+		return fmt.Errorf("unknown processor")
+	}
+
+	d, err := processors.Describe(newProcessor())
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(root, name)
+	if err := schema.Document(d, dir); err != nil {
+		return err
+	}
+
+	markdown := schema.Markdown(d)
+	if !write {
+		fmt.Printf("# %s\n\n%s\n", name, markdown)
+		return nil
+	}
+
+	js, err := schema.JSONSchema(d)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "schema.json"), append(js, '\n'), 0644); err != nil {
+		return err
+	}
+	return writeReadme(filepath.Join(dir, "README.md"), name, markdown)
+}
+
+// writeReadme replaces the generated part of the README at path with
+// markdown, text around it is kept
+func writeReadme(path string, name string, markdown []byte) error {
+	current, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	before, after := current, []byte{}
+	if begin := bytes.Index(current, []byte(beginMarker)); begin >= 0 {
+		before = current[:begin]
+		if end := bytes.Index(current, []byte(endMarker)); end > begin {
+			after = current[end+len(endMarker):]
+		}
+	}
+	before = bytes.TrimRight(before, "\n")
+	if len(before) == 0 {
+		before = []byte("# " + name)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(before)
+	buf.WriteString("\n\n" + beginMarker + "\n\n")
+	buf.Write(bytes.TrimRight(markdown, "\n"))
+	buf.WriteString("\n\n" + endMarker + "\n")
+	buf.Write(bytes.TrimLeft(after, "\n"))
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
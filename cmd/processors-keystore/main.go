@@ -0,0 +1,78 @@
+// processors-keystore manages the encrypted keystore holding the secrets
+// referenced in options as ${secret:name}.
+//
+//	processors-keystore [-path file] create
+//	processors-keystore [-path file] add name    (the value is read from stdin)
+//	processors-keystore [-path file] list
+//	processors-keystore [-path file] remove name
+//
+// The keystore password is read from the VEINO_KEYSTORE_PASSWORD environment
+// variable.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veino/processors"
+)
+
+func main() {
+	path := flag.String("path", "veino.keystore", "keystore file")
+	flag.Parse()
+
+	if err := run(*path, os.Getenv("VEINO_KEYSTORE_PASSWORD"), flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(path string, password string, args []string) error {
+	if password == "" {
+		return fmt.Errorf("VEINO_KEYSTORE_PASSWORD is not set")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage : processors-keystore [-path file] create | add name | list | remove name")
+	}
+
+	if args[0] == "create" {
+		ks, err := processors.CreateKeystore(path, password)
+		if err != nil {
+			return err
+		}
+		return ks.Save()
+	}
+
+	ks, err := processors.OpenKeystore(path, password)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		for _, name := range ks.Names() {
+			fmt.Println(name)
+		}
+		return nil
+	case "add":
+		if len(args) != 2 {
+			return fmt.Errorf("usage : processors-keystore add name")
+		}
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && value == "" {
+			return fmt.Errorf("can not read the value of %s from stdin : %s", args[1], err.Error())
+		}
+		ks.Set(args[1], strings.TrimRight(value, "\r\n"))
+		return ks.Save()
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("usage : processors-keystore remove name")
+		}
+		ks.Remove(args[1])
+		return ks.Save()
+	}
+	return fmt.Errorf("unknown command %s", args[0])
+}
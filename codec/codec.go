@@ -0,0 +1,167 @@
+// codec package decodes raw data into events and encodes events into raw data.
+// Inputs and outputs resolve their codec option by name against a shared
+// registry, so a codec behaves the same across the whole pipeline.
+package codec
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Decoder turns raw data into event fields.
+//
+// Decode is called with a unit of data as delivered by an input (a line, a
+// message body, a command output...). It returns the events completed by this
+// data, a decoder may keep state between calls (multiline). Flush returns
+// events still buffered by the decoder, it is called when the input stops.
+type Decoder interface {
+	Decode(data []byte) ([]map[string]interface{}, error)
+	Flush() []map[string]interface{}
+}
+
+// Encoder turns event fields into raw data.
+type Encoder interface {
+	Encode(fields map[string]interface{}) ([]byte, error)
+}
+
+// DecoderFactory builds a Decoder from user options
+type DecoderFactory func(options map[string]interface{}) (Decoder, error)
+
+// EncoderFactory builds an Encoder from user options
+type EncoderFactory func(options map[string]interface{}) (Encoder, error)
+
+var (
+	mu       sync.RWMutex
+	decoders = map[string]DecoderFactory{}
+	encoders = map[string]EncoderFactory{}
+)
+
+// RegisterDecoder makes a decoder available by name.
+// It panics when a decoder is registered twice under the same name.
+func RegisterDecoder(name string, f DecoderFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := decoders[name]; dup {
+		panic("codec: RegisterDecoder called twice for " + name)
+	}
+	decoders[name] = f
+}
+
+// RegisterEncoder makes an encoder available by name.
+// It panics when an encoder is registered twice under the same name.
+func RegisterEncoder(name string, f EncoderFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := encoders[name]; dup {
+		panic("codec: RegisterEncoder called twice for " + name)
+	}
+	encoders[name] = f
+}
+
+// NewDecoder returns a new decoder for the named codec
+func NewDecoder(name string, options map[string]interface{}) (Decoder, error) {
+	mu.RLock()
+	f, ok := decoders[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("codec %q can not decode, known decoders are %v", name, Decoders())
+	}
+	return f(options)
+}
+
+// NewEncoder returns a new encoder for the named codec
+func NewEncoder(name string, options map[string]interface{}) (Encoder, error) {
+	mu.RLock()
+	f, ok := encoders[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("codec %q can not encode, known encoders are %v", name, Encoders())
+	}
+	return f(options)
+}
+
+// Decoders returns the sorted names of registered decoders
+func Decoders() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := []string{}
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Encoders returns the sorted names of registered encoders
+func Encoders() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := []string{}
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Codec is the value of a processor's codec option.
+//
+// It is set from a codec name, `codec => json_lines`, or from a map holding the
+// name and the codec options, `codec => {name => multiline, options => {...}}`
+type Codec struct {
+	Name    string                 `mapstructure:"name"`
+	Options map[string]interface{} `mapstructure:"options"`
+}
+
+// New returns a codec option set to the named codec without options
+func New(name string) Codec {
+	return Codec{Name: name}
+}
+
+// UnmarshalText sets the codec from its name
+func (c *Codec) UnmarshalText(text []byte) error {
+	c.Name = string(text)
+	c.Options = nil
+	return nil
+}
+
+// String returns the codec name
+func (c Codec) String() string {
+	return c.Name
+}
+
+// NewDecoder returns a new decoder for this codec
+func (c Codec) NewDecoder() (Decoder, error) {
+	return NewDecoder(c.Name, c.Options)
+}
+
+// NewEncoder returns a new encoder for this codec
+func (c Codec) NewEncoder() (Encoder, error) {
+	return NewEncoder(c.Name, c.Options)
+}
+
+// decodeOptions sets codec's options struct from user options
+func decodeOptions(name string, options map[string]interface{}, rawVal interface{}) error {
+	if options == nil {
+		return nil
+	}
+	if err := mapstructure.Decode(options, rawVal); err != nil {
+		return fmt.Errorf("codec %s : %s", name, err.Error())
+	}
+	return nil
+}
+
+// message returns the message field of an event as a string
+func message(fields map[string]interface{}) string {
+	switch v := fields["message"].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,206 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veino/processors"
+	"github.com/veino/veino"
+)
+
+func TestRegistry(t *testing.T) {
+	assert.Equal(t, []string{"json", "json_lines", "line", "msgpack", "multiline", "plain"}, Decoders())
+	assert.Equal(t, []string{"json", "json_lines", "line", "msgpack", "plain", "rubydebug"}, Encoders())
+
+	_, err := NewDecoder("rubydebug", nil)
+	assert.NotNil(t, err, "rubydebug can not decode")
+
+	_, err = NewEncoder("unknow", nil)
+	assert.NotNil(t, err, "unknow codec should return an error")
+}
+
+func TestUnmarshalText(t *testing.T) {
+	c := Codec{Name: "line", Options: map[string]interface{}{"delimiter": ";"}}
+	assert.Nil(t, c.UnmarshalText([]byte("json_lines")))
+	assert.Equal(t, "json_lines", c.Name)
+	assert.Nil(t, c.Options, "options should be reset")
+}
+
+func TestConfigure(t *testing.T) {
+	opt := &struct {
+		Codec  Codec
+		Output Codec
+	}{}
+	b := &processors.Base{}
+	err := b.ConfigureAndValidate(veino.ProcessorContext{}, map[string]interface{}{
+		"codec": "json_lines",
+		"output": map[string]interface{}{
+			"name":    "line",
+			"options": map[string]interface{}{"delimiter": ";"},
+		},
+	}, opt)
+	assert.Nil(t, err)
+	assert.Equal(t, New("json_lines"), opt.Codec)
+	assert.Equal(t, Codec{Name: "line", Options: map[string]interface{}{"delimiter": ";"}}, opt.Output)
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		codec    Codec
+		data     string
+		expected []map[string]interface{}
+	}{
+		{
+			New("plain"),
+			"hello\nworld",
+			[]map[string]interface{}{{"message": "hello\nworld"}},
+		},
+		{
+			New("line"),
+			"hello\nworld\n",
+			[]map[string]interface{}{{"message": "hello"}, {"message": "world"}},
+		},
+		{
+			Codec{Name: "line", Options: map[string]interface{}{"delimiter": "|"}},
+			"hello|world",
+			[]map[string]interface{}{{"message": "hello"}, {"message": "world"}},
+		},
+		{
+			New("json"),
+			`{"message":"hello","count":2}`,
+			[]map[string]interface{}{{"message": "hello", "count": float64(2)}},
+		},
+		{
+			New("json"),
+			`[{"a":1},{"b":2}]`,
+			[]map[string]interface{}{{"a": float64(1)}, {"b": float64(2)}},
+		},
+		{
+			New("json"),
+			`{"message":`,
+			[]map[string]interface{}{{"message": `{"message":`, "tags": []string{JSONParseFailureTag}}},
+		},
+		{
+			New("json_lines"),
+			"{\"a\":1}\n\n{\"b\":2}\n",
+			[]map[string]interface{}{{"a": float64(1)}, {"b": float64(2)}},
+		},
+	}
+
+	for i, test := range tests {
+		d, err := test.codec.NewDecoder()
+		if !assert.Nil(t, err, "test %d", i) {
+			continue
+		}
+		events, err := d.Decode([]byte(test.data))
+		assert.Nil(t, err, "test %d", i)
+		assert.Equal(t, test.expected, events, "test %d", i)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	fields := map[string]interface{}{
+		"@timestamp": "2016-06-01T10:00:00Z",
+		"host":       "localhost",
+		"message":    "hello",
+	}
+
+	tests := []struct {
+		codec    Codec
+		expected string
+	}{
+		{New("plain"), "2016-06-01T10:00:00Z localhost hello"},
+		{New("line"), "2016-06-01T10:00:00Z localhost hello\n"},
+		{Codec{Name: "line", Options: map[string]interface{}{"format": "%{host}: %{message}"}}, "localhost: hello\n"},
+		{New("json"), `{"@timestamp":"2016-06-01T10:00:00Z","host":"localhost","message":"hello"}`},
+		{New("json_lines"), `{"@timestamp":"2016-06-01T10:00:00Z","host":"localhost","message":"hello"}` + "\n"},
+	}
+
+	for i, test := range tests {
+		e, err := test.codec.NewEncoder()
+		if !assert.Nil(t, err, "test %d", i) {
+			continue
+		}
+		data, err := e.Encode(fields)
+		assert.Nil(t, err, "test %d", i)
+		assert.Equal(t, test.expected, string(data), "test %d", i)
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	d, err := Codec{Name: "multiline", Options: map[string]interface{}{
+		"pattern": `^\s`,
+		"what":    "previous",
+	}}.NewDecoder()
+	assert.Nil(t, err)
+
+	events, _ := d.Decode([]byte("Exception in thread main"))
+	assert.Equal(t, 0, len(events), "first line should be buffered")
+
+	events, _ = d.Decode([]byte("  at com.example.Foo\n  at com.example.Bar"))
+	assert.Equal(t, 0, len(events), "indented lines belong to the previous event")
+
+	events, _ = d.Decode([]byte("next event"))
+	assert.Equal(t, []map[string]interface{}{{
+		"message": "Exception in thread main\n  at com.example.Foo\n  at com.example.Bar",
+		"tags":    []string{"multiline"},
+	}}, events)
+
+	assert.Equal(t, []map[string]interface{}{{"message": "next event"}}, d.Flush())
+	assert.Nil(t, d.Flush(), "buffer should be empty after a flush")
+
+	_, err = New("multiline").NewDecoder()
+	assert.NotNil(t, err, "pattern is required")
+}
+
+func TestMultilineNext(t *testing.T) {
+	d, err := Codec{Name: "multiline", Options: map[string]interface{}{
+		"pattern": `\\$`,
+		"what":    "next",
+	}}.NewDecoder()
+	assert.Nil(t, err)
+
+	events, _ := d.Decode([]byte("first \\\nsecond\nthird"))
+	assert.Equal(t, []map[string]interface{}{
+		{"message": "first \\\nsecond", "tags": []string{"multiline"}},
+		{"message": "third"},
+	}, events)
+}
+
+func TestMsgpack(t *testing.T) {
+	c := New("msgpack")
+	e, _ := c.NewEncoder()
+	d, _ := c.NewDecoder()
+
+	fields := map[string]interface{}{
+		"message": "hello",
+		"count":   300,
+		"neg":     -5,
+		"ratio":   0.5,
+		"ok":      true,
+		"none":    nil,
+		"tags":    []string{"a", "b"},
+		"nested":  map[string]interface{}{"long": string(make([]byte, 40))},
+	}
+
+	data, err := e.Encode(fields)
+	assert.Nil(t, err)
+
+	// two concatenated events
+	events, err := d.Decode(append(data, data...))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, map[string]interface{}{
+		"message": "hello",
+		"count":   int64(300),
+		"neg":     int64(-5),
+		"ratio":   0.5,
+		"ok":      true,
+		"none":    nil,
+		"tags":    []interface{}{"a", "b"},
+		"nested":  map[string]interface{}{"long": string(make([]byte, 40))},
+	}, events[0])
+
+	events, _ = d.Decode([]byte{0xc1})
+	assert.Equal(t, []string{MsgpackParseFailureTag}, events[0]["tags"])
+}
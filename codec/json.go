@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// JSONParseFailureTag is added to events which data could not be read as JSON
+const JSONParseFailureTag = "_jsonparsefailure"
+
+func init() {
+	RegisterDecoder("json", newJSONDecoder)
+	RegisterEncoder("json", newJSONEncoder)
+	RegisterDecoder("json_lines", newJSONLinesDecoder)
+	RegisterEncoder("json_lines", newJSONLinesEncoder)
+}
+
+// json codec reads JSON data, an object is an event and an array of objects
+// is an event per element. It writes events as JSON objects with no delimiter.
+//
+// json_lines codec reads and writes line delimited JSON objects.
+//
+// Data which can not be read as JSON is set as the event's message and the
+// event is tagged with "_jsonparsefailure"
+type jsonOptions struct {
+	// Line delimiter, only used by json_lines
+	// @default : "\n"
+	Delimiter string
+}
+
+type jsonCodec struct {
+	opt   *jsonOptions
+	lines bool
+}
+
+func newJSON(name string, options map[string]interface{}, lines bool) (*jsonCodec, error) {
+	c := &jsonCodec{opt: &jsonOptions{Delimiter: "\n"}, lines: lines}
+	if err := decodeOptions(name, options, c.opt); err != nil {
+		return nil, err
+	}
+	if c.lines && c.opt.Delimiter == "" {
+		return nil, errors.New("codec json_lines : delimiter can not be empty")
+	}
+	return c, nil
+}
+
+func newJSONDecoder(options map[string]interface{}) (Decoder, error) {
+	return newJSON("json", options, false)
+}
+
+func newJSONEncoder(options map[string]interface{}) (Encoder, error) {
+	return newJSON("json", options, false)
+}
+
+func newJSONLinesDecoder(options map[string]interface{}) (Decoder, error) {
+	return newJSON("json_lines", options, true)
+}
+
+func newJSONLinesEncoder(options map[string]interface{}) (Encoder, error) {
+	return newJSON("json_lines", options, true)
+}
+
+func (c *jsonCodec) Decode(data []byte) ([]map[string]interface{}, error) {
+	if !c.lines {
+		return decodeJSON(data), nil
+	}
+
+	events := []map[string]interface{}{}
+	for _, l := range splitLines(data, c.opt.Delimiter) {
+		if len(l) == 0 {
+			continue
+		}
+		events = append(events, decodeJSON(l)...)
+	}
+	return events, nil
+}
+
+func (c *jsonCodec) Flush() []map[string]interface{} { return nil }
+
+func (c *jsonCodec) Encode(fields map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if c.lines {
+		data = append(data, c.opt.Delimiter...)
+	}
+	return data, nil
+}
+
+// decodeJSON returns events found in a JSON object or in an array of objects
+func decodeJSON(data []byte) []map[string]interface{} {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []map[string]interface{}{jsonParseFailure(data)}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		events := []map[string]interface{}{}
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				events = append(events, m)
+			} else {
+				b, _ := json.Marshal(item)
+				events = append(events, jsonParseFailure(b))
+			}
+		}
+		return events
+	}
+
+	return []map[string]interface{}{jsonParseFailure(data)}
+}
+
+func jsonParseFailure(data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"message": string(data),
+		"tags":    []string{JSONParseFailureTag},
+	}
+}
@@ -0,0 +1,75 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/veino/processors"
+)
+
+func init() {
+	RegisterDecoder("line", newLineDecoder)
+	RegisterEncoder("line", newLineEncoder)
+}
+
+// line codec reads line-oriented text data, each line is an event. It writes
+// each event followed by the delimiter
+type lineOptions struct {
+	// Line delimiter
+	// @default : "\n"
+	Delimiter string
+
+	// Set the message you which to emit for each event. This supports
+	// %{field} strings. When empty "@timestamp host message" is written
+	Format string
+}
+
+type line struct {
+	opt    *lineOptions
+	format *processors.Template
+}
+
+func newLine(options map[string]interface{}) (*line, error) {
+	c := &line{opt: &lineOptions{Delimiter: "\n"}}
+	if err := decodeOptions("line", options, c.opt); err != nil {
+		return nil, err
+	}
+	if c.opt.Delimiter == "" {
+		return nil, errors.New("codec line : delimiter can not be empty")
+	}
+	var err error
+	c.format, err = newFormat(c.opt.Format)
+	return c, err
+}
+
+func newLineDecoder(options map[string]interface{}) (Decoder, error) {
+	return newLine(options)
+}
+
+func newLineEncoder(options map[string]interface{}) (Encoder, error) {
+	return newLine(options)
+}
+
+func (c *line) Decode(data []byte) ([]map[string]interface{}, error) {
+	events := []map[string]interface{}{}
+	for _, l := range splitLines(data, c.opt.Delimiter) {
+		events = append(events, map[string]interface{}{"message": string(l)})
+	}
+	return events, nil
+}
+
+func (c *line) Flush() []map[string]interface{} { return nil }
+
+func (c *line) Encode(fields map[string]interface{}) ([]byte, error) {
+	return []byte(format(c.format, fields) + c.opt.Delimiter), nil
+}
+
+// splitLines splits data on delimiter, a trailing delimiter does not produce
+// an empty line
+func splitLines(data []byte, delimiter string) [][]byte {
+	lines := bytes.Split(data, []byte(delimiter))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
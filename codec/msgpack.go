@@ -0,0 +1,365 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// MsgpackParseFailureTag is added to events which data could not be read as
+// msgpack
+const MsgpackParseFailureTag = "_msgpackparsefailure"
+
+func init() {
+	RegisterDecoder("msgpack", newMsgpackDecoder)
+	RegisterEncoder("msgpack", newMsgpackEncoder)
+}
+
+// msgpack codec reads and writes msgpack (http://msgpack.org) encoded events.
+// Data can hold several concatenated maps, each of them is an event.
+type msgpack struct{}
+
+func newMsgpackDecoder(options map[string]interface{}) (Decoder, error) {
+	return &msgpack{}, nil
+}
+
+func newMsgpackEncoder(options map[string]interface{}) (Encoder, error) {
+	return &msgpack{}, nil
+}
+
+func (c *msgpack) Decode(data []byte) ([]map[string]interface{}, error) {
+	events := []map[string]interface{}{}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		v, err := readMsgpack(r)
+		if err != nil {
+			return append(events, msgpackParseFailure(data)), nil
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return append(events, msgpackParseFailure(data)), nil
+		}
+		events = append(events, m)
+	}
+	return events, nil
+}
+
+func (c *msgpack) Flush() []map[string]interface{} { return nil }
+
+func (c *msgpack) Encode(fields map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, reflect.ValueOf(fields)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func msgpackParseFailure(data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"message": string(data),
+		"tags":    []string{MsgpackParseFailureTag},
+	}
+}
+
+var errMsgpackFormat = errors.New("msgpack : invalid format")
+
+func readMsgpack(r *bytes.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return readMsgpackMap(r, int(c&0x0f))
+	case c >= 0x90 && c <= 0x9f:
+		return readMsgpackArray(r, int(c&0x0f))
+	case c >= 0xa0 && c <= 0xbf:
+		return readMsgpackString(r, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		n, err := readMsgpackUint(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, int(n))
+	case 0xc5, 0xda:
+		n, err := readMsgpackUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, int(n))
+	case 0xc6, 0xdb:
+		n, err := readMsgpackUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, int(n))
+	case 0xca:
+		n, err := readMsgpackUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		n, err := readMsgpackUint(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readMsgpackUint(r, 1<<(c-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0:
+		n, err := readMsgpackUint(r, 1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := readMsgpackUint(r, 2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := readMsgpackUint(r, 4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := readMsgpackUint(r, 8)
+		return int64(n), err
+	case 0xdc:
+		n, err := readMsgpackUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, int(n))
+	case 0xdd:
+		n, err := readMsgpackUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, int(n))
+	case 0xde:
+		n, err := readMsgpackUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, int(n))
+	case 0xdf:
+		n, err := readMsgpackUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, int(n))
+	}
+
+	return nil, errMsgpackFormat
+}
+
+func readMsgpackUint(r *bytes.Reader, size int) (uint64, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func readMsgpackString(r *bytes.Reader, n int) (interface{}, error) {
+	if n > r.Len() {
+		return nil, errMsgpackFormat
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func readMsgpackArray(r *bytes.Reader, n int) (interface{}, error) {
+	if n > r.Len() {
+		return nil, errMsgpackFormat
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func readMsgpackMap(r *bytes.Reader, n int) (interface{}, error) {
+	if n > r.Len() {
+		return nil, errMsgpackFormat
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprintf("%v", k)] = v
+	}
+	return m, nil
+}
+
+func writeMsgpack(w *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		w.WriteByte(0xc0)
+		return nil
+	}
+
+	switch t := v.Interface().(type) {
+	case time.Time:
+		return writeMsgpackString(w, t.Format(time.RFC3339Nano))
+	case json.Number:
+		return writeMsgpackString(w, t.String())
+	case []byte:
+		writeMsgpackHeader(w, len(t), 0xc4, 0xc5, 0xc6)
+		w.Write(t)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			w.WriteByte(0xc0)
+			return nil
+		}
+		return writeMsgpack(w, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			w.WriteByte(0xc3)
+		} else {
+			w.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if n >= 0 {
+			writeMsgpackUint(w, uint64(n))
+		} else if n >= -32 {
+			w.WriteByte(byte(int8(n)))
+		} else {
+			w.WriteByte(0xd3)
+			binary.Write(w, binary.BigEndian, n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		writeMsgpackUint(w, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		w.WriteByte(0xcb)
+		binary.Write(w, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		return writeMsgpackString(w, v.String())
+	case reflect.Slice, reflect.Array:
+		writeMsgpackHeader(w, v.Len(), 0x90, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := writeMsgpack(w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprintf("%v", keys[i].Interface()) < fmt.Sprintf("%v", keys[j].Interface())
+		})
+		writeMsgpackHeader(w, len(keys), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			if err := writeMsgpackString(w, fmt.Sprintf("%v", k.Interface())); err != nil {
+				return err
+			}
+			if err := writeMsgpack(w, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	default:
+		// structs and other values are written with their JSON representation
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Errorf("msgpack : can not encode %s : %s", v.Type(), err.Error())
+		}
+		var i interface{}
+		if err := json.Unmarshal(b, &i); err != nil {
+			return err
+		}
+		return writeMsgpack(w, reflect.ValueOf(i))
+	}
+	return nil
+}
+
+func writeMsgpackUint(w *bytes.Buffer, n uint64) {
+	switch {
+	case n <= 0x7f:
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		w.WriteByte(0xcc)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(0xcd)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		w.WriteByte(0xce)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	default:
+		w.WriteByte(0xcf)
+		binary.Write(w, binary.BigEndian, n)
+	}
+}
+
+func writeMsgpackString(w *bytes.Buffer, s string) error {
+	if len(s) <= 31 {
+		w.WriteByte(0xa0 | byte(len(s)))
+	} else {
+		writeMsgpackHeader(w, len(s), 0xd9, 0xda, 0xdb)
+	}
+	w.WriteString(s)
+	return nil
+}
+
+// writeMsgpackHeader writes a length prefix. When fix is a fixed size family
+// marker (array or map), small lengths are packed within it, else fix is the
+// 8 bits length marker
+func writeMsgpackHeader(w *bytes.Buffer, n int, fix, m16, m32 byte) {
+	switch {
+	case (fix == 0x80 || fix == 0x90) && n <= 15:
+		w.WriteByte(fix | byte(n))
+	case fix != 0x80 && fix != 0x90 && n <= math.MaxUint8:
+		w.WriteByte(fix)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(m16)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(m32)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
@@ -0,0 +1,117 @@
+package codec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	RegisterDecoder("multiline", newMultilineDecoder)
+}
+
+// multiline codec merges lines into a single event.
+//
+// Each line is matched against pattern, with what set to "previous" a matching
+// line belongs to the previous event, with what set to "next" a matching line
+// belongs to the next event.
+type multilineOptions struct {
+	// The regular expression to match
+	Pattern string
+
+	// Negate the regexp pattern (if not matched)
+	Negate bool
+
+	// If the pattern matched, does event belong to the next or previous event?
+	// Value can be any of: previous, next
+	// @default : "previous"
+	What string
+
+	// The maximum number of lines merged in a single event
+	// @default : 500
+	Max_lines int
+
+	// Tag multiline events with a given tag
+	// @default : "multiline"
+	Multiline_tag string
+
+	// Line delimiter
+	// @default : "\n"
+	Delimiter string
+}
+
+type multiline struct {
+	opt     *multilineOptions
+	pattern *regexp.Regexp
+	buffer  []string
+}
+
+func newMultilineDecoder(options map[string]interface{}) (Decoder, error) {
+	c := &multiline{opt: &multilineOptions{
+		What:          "previous",
+		Max_lines:     500,
+		Multiline_tag: "multiline",
+		Delimiter:     "\n",
+	}}
+	if err := decodeOptions("multiline", options, c.opt); err != nil {
+		return nil, err
+	}
+
+	if c.opt.Pattern == "" {
+		return nil, fmt.Errorf("codec multiline : pattern is required")
+	}
+	if c.opt.What != "previous" && c.opt.What != "next" {
+		return nil, fmt.Errorf("codec multiline : what should be previous or next, got %q", c.opt.What)
+	}
+	if c.opt.Delimiter == "" {
+		return nil, fmt.Errorf("codec multiline : delimiter can not be empty")
+	}
+
+	var err error
+	if c.pattern, err = regexp.Compile(c.opt.Pattern); err != nil {
+		return nil, fmt.Errorf("codec multiline : %s", err.Error())
+	}
+	return c, nil
+}
+
+func (c *multiline) Decode(data []byte) ([]map[string]interface{}, error) {
+	events := []map[string]interface{}{}
+	for _, l := range splitLines(data, c.opt.Delimiter) {
+		text := string(l)
+		match := c.pattern.MatchString(text) != c.opt.Negate
+
+		switch c.opt.What {
+		case "previous":
+			if !match {
+				events = append(events, c.Flush()...)
+			}
+			c.buffer = append(c.buffer, text)
+		case "next":
+			c.buffer = append(c.buffer, text)
+			if !match {
+				events = append(events, c.Flush()...)
+			}
+		}
+
+		if c.opt.Max_lines > 0 && len(c.buffer) >= c.opt.Max_lines {
+			events = append(events, c.Flush()...)
+		}
+	}
+	return events, nil
+}
+
+func (c *multiline) Flush() []map[string]interface{} {
+	if len(c.buffer) == 0 {
+		return nil
+	}
+
+	event := map[string]interface{}{
+		"message": strings.Join(c.buffer, c.opt.Delimiter),
+	}
+	if len(c.buffer) > 1 && c.opt.Multiline_tag != "" {
+		event["tags"] = []string{c.opt.Multiline_tag}
+	}
+	c.buffer = nil
+
+	return []map[string]interface{}{event}
+}
@@ -0,0 +1,70 @@
+package codec
+
+import (
+	"github.com/clbanning/mxj"
+	"github.com/veino/processors"
+)
+
+func init() {
+	RegisterDecoder("plain", newPlainDecoder)
+	RegisterEncoder("plain", newPlainEncoder)
+}
+
+// plain codec reads data as a whole message, and writes events as text with no
+// delimiter between events
+type plainOptions struct {
+	// Set the message you which to emit for each event. This supports
+	// %{field} strings. When empty "@timestamp host message" is written
+	Format string
+}
+
+type plain struct {
+	opt    *plainOptions
+	format *processors.Template
+}
+
+func newPlainDecoder(options map[string]interface{}) (Decoder, error) {
+	return &plain{opt: &plainOptions{}}, nil
+}
+
+func newPlainEncoder(options map[string]interface{}) (Encoder, error) {
+	c := &plain{opt: &plainOptions{}}
+	if err := decodeOptions("plain", options, c.opt); err != nil {
+		return nil, err
+	}
+	var err error
+	c.format, err = newFormat(c.opt.Format)
+	return c, err
+}
+
+func (c *plain) Decode(data []byte) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{
+		{"message": string(data)},
+	}, nil
+}
+
+func (c *plain) Flush() []map[string]interface{} { return nil }
+
+func (c *plain) Encode(fields map[string]interface{}) ([]byte, error) {
+	return []byte(format(c.format, fields)), nil
+}
+
+// newFormat parses the user format, an empty format gives a nil template
+func newFormat(f string) (*processors.Template, error) {
+	if f == "" {
+		return nil, nil
+	}
+	return processors.NewTemplate(f)
+}
+
+// format renders an event with the user format, or with the default text
+// representation of an event when format is nil
+func format(t *processors.Template, fields map[string]interface{}) string {
+	m := mxj.Map(fields)
+	if t == nil {
+		return m.ValueOrEmptyForPathString("@timestamp") + " " +
+			m.ValueOrEmptyForPathString("host") + " " +
+			message(fields)
+	}
+	return t.Render(&m)
+}
@@ -0,0 +1,21 @@
+package codec
+
+import (
+	"github.com/k0kubun/pp"
+)
+
+func init() {
+	RegisterEncoder("rubydebug", newRubydebugEncoder)
+}
+
+// rubydebug codec pretty prints events, it is meant for debugging outputs and
+// can not decode
+type rubydebug struct{}
+
+func newRubydebugEncoder(options map[string]interface{}) (Encoder, error) {
+	return &rubydebug{}, nil
+}
+
+func (c *rubydebug) Encode(fields map[string]interface{}) ([]byte, error) {
+	return []byte(pp.Sprintln(fields)), nil
+}
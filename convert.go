@@ -0,0 +1,376 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/clbanning/mxj"
+	"github.com/veino/veino"
+)
+
+// ConvertError is a value which could not be converted
+type ConvertError struct {
+	// Path is the field holding the value
+	Path string
+	// Kind is the conversion target, as "integer"
+	Kind string
+	// Value is the value which could not be converted
+	Value interface{}
+}
+
+func (e *ConvertError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("can not convert %s to %s", convertQuote(e.Value), e.Kind)
+	}
+	return fmt.Sprintf("%s : can not convert %s to %s", e.Path, convertQuote(e.Value), e.Kind)
+}
+
+// ConvertErrors lists the values Convert could not convert
+type ConvertErrors []*ConvertError
+
+func (e ConvertErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, ", ")
+}
+
+// Convert converts the fields of data to the kind mapped to their path, see
+// ConvertValue for the conversion targets. Arrays are converted element-wise
+// and hashes value-wise. Values which can not be converted are left as is and
+// returned in a ConvertErrors
+func Convert(fields map[string]string, data *mxj.Map) error {
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	errs := ConvertErrors{}
+	for _, path := range paths {
+		value, err := GetField(data, path)
+		if err != nil {
+			continue
+		}
+
+		converted, failed := convertDeep(value, fields[path])
+		for _, v := range failed {
+			errs = append(errs, &ConvertError{Path: path, Kind: fields[path], Value: v})
+		}
+		SetField(data, path, converted)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// convertDeep converts value, the elements of arrays and the values of
+// hashes, except for the string kind where hashes are encoded as JSON. It
+// returns values which could not be converted
+func convertDeep(value interface{}, kind string) (interface{}, []interface{}) {
+	failed := []interface{}{}
+	switch v := value.(type) {
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			var f []interface{}
+			a[i], f = convertDeep(item, kind)
+			failed = append(failed, f...)
+		}
+		return a, failed
+	case []string:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			var f []interface{}
+			a[i], f = convertDeep(item, kind)
+			failed = append(failed, f...)
+		}
+		return a, failed
+	case map[string]interface{}:
+		if kind == "string" {
+			break
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var f []interface{}
+			m[key], f = convertDeep(item, kind)
+			failed = append(failed, f...)
+		}
+		return m, failed
+	}
+
+	converted, err := ConvertValue(value, kind)
+	if err != nil {
+		return value, append(failed, value)
+	}
+	return converted, failed
+}
+
+// ConvertValue converts a single value to kind :
+//
+//	integer    "42", "1,234", 42.9 or true to an int, 42, 1234, 42 and 1
+//	integer_eu "1.234" or "1 234" to 1234, with dots or spaces as thousands separators
+//	float      "1,234.5" or 1 to a float64
+//	float_eu   "1.234,5" to 1234.5, with a comma as decimal separator
+//	boolean    true, t, yes, y, 1 or false, f, no, n, 0 (any case, or the 1 and 0 numbers)
+//	string     any value, hashes and arrays are encoded as JSON
+//	bytes      "10MB" or "1.5 KiB" to a number of bytes, KB is 1000 bytes and KiB 1024 bytes
+//	duration   "1m30s" or "1.5s" to a number of seconds, as float64
+//	timestamp  a RFC3339 string or a number of seconds since epoch to a @timestamp string
+//
+// Integers are kept as integers, int64 values do not lose precision.
+func ConvertValue(value interface{}, kind string) (interface{}, error) {
+	if n, ok := value.(json.Number); ok {
+		value = string(n)
+	}
+
+	var (
+		converted interface{}
+		ok        bool
+	)
+	switch kind {
+	case "integer":
+		converted, ok = toInteger(value, ",")
+	case "integer_eu":
+		converted, ok = toInteger(euToDecimal(value), ",")
+	case "float":
+		converted, ok = toFloat(value, ",")
+	case "float_eu":
+		converted, ok = toFloat(euToDecimal(value), ",")
+	case "boolean":
+		converted, ok = toBoolean(value)
+	case "string":
+		converted, ok = toString(value)
+	case "bytes":
+		converted, ok = toBytes(value)
+	case "duration":
+		converted, ok = toSeconds(value)
+	case "timestamp":
+		converted, ok = toTimestamp(value)
+	default:
+		return nil, fmt.Errorf("unknown conversion %q", kind)
+	}
+
+	if !ok {
+		return nil, &ConvertError{Kind: kind, Value: value}
+	}
+	return converted, nil
+}
+
+// euToDecimal turns "1.234,5" into "1234.5"
+func euToDecimal(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	s = strings.Map(func(r rune) rune {
+		if r == '.' || r == '\'' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.Replace(s, ",", ".", 1)
+}
+
+func toInteger(value interface{}, separator string) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		i, err := strconv.ParseInt(fmt.Sprintf("%d", v), 10, 64)
+		return int(i), err == nil
+	case float32:
+		return floatToInteger(float64(v))
+	case float64:
+		return floatToInteger(v)
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		s := strings.Replace(strings.TrimSpace(v), separator, "", -1)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return int(i), true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return floatToInteger(f)
+		}
+	}
+	return nil, false
+}
+
+// floatToInteger truncates f, it returns false when f is not a number or out
+// of the int64 range
+func floatToInteger(f float64) (interface{}, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) >= math.MaxInt64 {
+		return nil, false
+	}
+	return int(f), true
+}
+
+func toFloat(value interface{}, separator string) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int8, int16, int32, uint, uint8, uint16, uint32, uint64, float32:
+		f, err := strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
+		return f, err == nil
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1.0, true
+		}
+		return 0.0, true
+	case string:
+		s := strings.Replace(strings.TrimSpace(v), separator, "", -1)
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toBoolean(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "t", "yes", "y", "1":
+			return true, true
+		case "false", "f", "no", "n", "0":
+			return false, true
+		}
+	default:
+		if f, ok := toFloat(value, ""); ok {
+			switch f.(float64) {
+			case 1:
+				return true, true
+			case 0:
+				return false, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func toString(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, bool:
+		return fmt.Sprintf("%v", v), true
+	case nil:
+		return "null", true
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return string(b), true
+}
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"k":   1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tib": 1 << 40,
+	"p":   1 << 50,
+	"pib": 1 << 50,
+}
+
+func toBytes(value interface{}) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return toInteger(value, "")
+	}
+
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != '-' && r != '+'
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	number, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return nil, false
+	}
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[end:]))]
+	if !ok {
+		return nil, false
+	}
+	return int(number * unit), true
+}
+
+func toSeconds(value interface{}) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return toFloat(value, "")
+	}
+	if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+		return f, true
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return nil, false
+	}
+	return d.Seconds(), true
+}
+
+func toTimestamp(value interface{}) (interface{}, bool) {
+	if s, ok := value.(string); ok {
+		s = strings.TrimSpace(s)
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t.UTC().Format(veino.VeinoTime), true
+		}
+		value = s
+	}
+
+	f, ok := toFloat(value, "")
+	if !ok {
+		return nil, false
+	}
+	seconds, fraction := math.Modf(f.(float64))
+	t := time.Unix(int64(seconds), int64(fraction*1e9))
+	return t.UTC().Format(veino.VeinoTime), true
+}
+
+func convertQuote(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	s, _ := toString(value)
+	if s == nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return s.(string)
+}
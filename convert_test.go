@@ -0,0 +1,91 @@
+package processors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/clbanning/mxj"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		kind     string
+		expected interface{}
+	}{
+		{"42", "integer", 42},
+		{"1,234", "integer", 1234},
+		{"42.9", "integer", 42},
+		{42.9, "integer", 42},
+		{true, "integer", 1},
+		{int64(9007199254740993), "integer", 9007199254740993},
+		{json.Number("9007199254740993"), "integer", 9007199254740993},
+		{"1.234", "integer_eu", 1234},
+		{"1 234", "integer_eu", 1234},
+		{"1,234.5", "float", 1234.5},
+		{3, "float", 3.0},
+		{"1.234,5", "float_eu", 1234.5},
+		{"Y", "boolean", true},
+		{"f", "boolean", false},
+		{"no", "boolean", false},
+		{0, "boolean", false},
+		{1.5, "string", "1.5"},
+		{int64(9007199254740993), "string", "9007199254740993"},
+		{false, "string", "false"},
+		{map[string]interface{}{"a": 1}, "string", `{"a":1}`},
+		{"10MB", "bytes", 10000000},
+		{"1.5 KiB", "bytes", 1536},
+		{"512", "bytes", 512},
+		{"1m30s", "duration", 90.0},
+		{"2.5", "duration", 2.5},
+		{"2017-03-01T10:00:00+01:00", "timestamp", "2017-03-01T09:00:00Z"},
+		{1488358800.5, "timestamp", "2017-03-01T09:00:00.5Z"},
+	}
+
+	for _, test := range tests {
+		value, err := ConvertValue(test.value, test.kind)
+		assert.Nil(t, err, "%v to %s", test.value, test.kind)
+		assert.Equal(t, test.expected, value, "%v to %s", test.value, test.kind)
+	}
+}
+
+func TestConvertValueErrors(t *testing.T) {
+	for _, test := range []struct {
+		value interface{}
+		kind  string
+	}{
+		{"abc", "integer"},
+		{1e20, "integer"},
+		{"-1e20", "integer"},
+		{"maybe", "boolean"},
+		{2, "boolean"},
+		{"10 parsecs", "bytes"},
+		{"soon", "duration"},
+		{"yesterday", "timestamp"},
+		{"1", "roman"},
+	} {
+		_, err := ConvertValue(test.value, test.kind)
+		assert.NotNil(t, err, "%v to %s", test.value, test.kind)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	data := mxj.Map(map[string]interface{}{
+		"codes": []interface{}{"200", "404", "oops"},
+		"sizes": map[string]interface{}{
+			"in":  "1KB",
+			"out": map[string]interface{}{"max": "2KB"},
+		},
+		"ratio": "0.5",
+	})
+
+	err := Convert(map[string]string{"codes": "integer", "sizes": "bytes", "ratio": "float", "missing": "integer"}, &data)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, `codes : can not convert "oops" to integer`, err.Error())
+	}
+	assert.Equal(t, []interface{}{200, 404, "oops"}, data["codes"], "values which can not be converted are kept")
+	assert.Equal(t, map[string]interface{}{"in": 1000, "out": map[string]interface{}{"max": 2000}}, data["sizes"])
+	assert.Equal(t, 0.5, data["ratio"])
+	assert.Equal(t, false, FieldExists(&data, "missing"))
+}
@@ -0,0 +1,288 @@
+package processors
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veino/veino"
+)
+
+//go:generate go run cmd/processors-doc/main.go -root . -write
+
+// Descriptor describes a processor and its options, it is derived from the
+// options struct given to ConfigureAndValidate
+type Descriptor struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Options     []*OptionDescriptor `json:"options"`
+}
+
+// OptionDescriptor describes an option of a processor
+type OptionDescriptor struct {
+	// Name is the configuration key, as "add_field"
+	Name string `json:"name"`
+	// Field is the name of the struct field holding the option
+	Field string `json:"-"`
+	// Type is one of string, integer, number, boolean, duration, array, hash, any, or
+	// the lower cased name of a type set from a string, as "codec"
+	Type string `json:"type"`
+	// Items is the type of the elements of an array or of the values of a hash
+	Items string `json:"items,omitempty"`
+	// Default is the value set by Configure before user options are decoded
+	Default interface{} `json:"default,omitempty"`
+	// Required options are tagged validate:"required"
+	Required bool `json:"required,omitempty"`
+	// Enum lists accepted values of options tagged enum:"a,b,c"
+	Enum []string `json:"enum,omitempty"`
+	// Description is the doc comment of the field, filled from the source by
+	// the schema package
+	Description string `json:"description,omitempty"`
+}
+
+// Option returns the option named name, or nil
+func (d *Descriptor) Option(name string) *OptionDescriptor {
+	for _, o := range d.Options {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// errDescribing stops Configure when a processor is described
+var errDescribing = errors.New("describing processor")
+
+// describable is implemented by processors embedding Base, see Describe and
+// Receive
+type describable interface {
+	base() *Base
+}
+
+func (b *Base) base() *Base { return b }
+
+// Describe returns the descriptor of p, p must embed Base.
+//
+// Describe calls p.Configure, which stops at ConfigureAndValidate, to read
+// options with their defaults. Processors without options, or not calling
+// ConfigureAndValidate, are described without options.
+func Describe(p veino.Processor) (*Descriptor, error) {
+	d, ok := p.(describable)
+	if !ok {
+		return nil, fmt.Errorf("%T does not embed processors.Base", p)
+	}
+
+	b := d.base()
+	b.describing = true
+	b.described = nil
+	defer func() { b.describing = false }()
+
+	if err := p.Configure(veino.ProcessorContext{}, map[string]interface{}{}); err != nil && err != errDescribing {
+		return nil, err
+	}
+
+	descriptor := &Descriptor{Name: processorName(p), Options: []*OptionDescriptor{}}
+	if b.described != nil {
+		descriptor.Options = describeOptions(reflect.ValueOf(b.described))
+	}
+	return descriptor, nil
+}
+
+// describeOptions returns the options of the struct v points to
+func describeOptions(v reflect.Value) []*OptionDescriptor {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	options := []*OptionDescriptor{}
+	for _, f := range optionFields(v.Type()) {
+		value := v.FieldByIndex(f.Index)
+		o := &OptionDescriptor{
+			Name:     optionName(f),
+			Field:    f.Name,
+			Type:     typeName(f.Type),
+			Default:  defaultValue(value),
+			Required: hasTagItem(f.Tag.Get("validate"), "required"),
+			Enum:     enumValues(f),
+		}
+		if f.Type.Kind() == reflect.Slice || f.Type.Kind() == reflect.Array || f.Type.Kind() == reflect.Map {
+			o.Items = typeName(f.Type.Elem())
+		}
+		options = append(options, o)
+	}
+	return options
+}
+
+// optionFields returns the fields of t set from user options : exported
+// fields, but embedded ones (Base), funcs, channels and pointers
+func optionFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Anonymous || f.Tag.Get("mapstructure") == "-" {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Func, reflect.Chan, reflect.Ptr, reflect.UnsafePointer:
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// optionName returns the configuration key of f, its mapstructure name or its
+// lower cased name, as mapstructure matches names without case
+func optionName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+func typeName(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) && t.Kind() == reflect.Struct {
+		return strings.ToLower(t.Name())
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "hash"
+	}
+	return "any"
+}
+
+// defaultValue returns v, or nil when v is the zero value of its type. Values
+// set from a string are returned as this string when possible.
+func defaultValue(v reflect.Value) interface{} {
+	if reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface()) {
+		return nil
+	}
+
+	if s, ok := v.Interface().(fmt.Stringer); ok && reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		parsed := reflect.New(v.Type())
+		if err := parsed.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s.String())); err == nil && reflect.DeepEqual(parsed.Elem().Interface(), v.Interface()) {
+			return s.String()
+		}
+	}
+
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	if v.Kind() == reflect.Struct {
+		m := map[string]interface{}{}
+		for _, f := range optionFields(v.Type()) {
+			if value := defaultValue(v.FieldByIndex(f.Index)); value != nil {
+				m[optionName(f)] = value
+			}
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+func hasTagItem(tag string, item string) bool {
+	for _, t := range strings.Split(tag, ",") {
+		if t == item {
+			return true
+		}
+	}
+	return false
+}
+
+func enumValues(f reflect.StructField) []string {
+	tag := f.Tag.Get("enum")
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+// validateEnums checks options tagged enum:"a,b,c" of rawVal hold one of the
+// values, or are empty. Values of hashes and options of arrays of structs are
+// checked too
+func validateEnums(processor string, rawVal interface{}) error {
+	return validateStructEnums(processor, "", reflect.ValueOf(rawVal))
+}
+
+func validateStructEnums(processor string, prefix string, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, f := range optionFields(v.Type()) {
+		field := v.FieldByIndex(f.Index)
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+			for i := 0; i < field.Len(); i++ {
+				if err := validateStructEnums(processor, fmt.Sprintf("%s%s[%d].", prefix, optionName(f), i), field.Index(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		enum := enumValues(f)
+		if enum == nil {
+			continue
+		}
+
+		values := []string{}
+		switch value := field.Interface().(type) {
+		case string:
+			values = append(values, value)
+		case []string:
+			values = value
+		case map[string]string:
+			for _, item := range value {
+				values = append(values, item)
+			}
+			sort.Strings(values)
+		}
+		for _, value := range values {
+			if value != "" && !contains(enum, value) {
+				return &OptionError{
+					Processor: processor,
+					Key:       prefix + optionName(f),
+					Reason:    fmt.Sprintf("%q is not one of %s", value, strings.Join(enum, ", ")),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
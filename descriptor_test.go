@@ -0,0 +1,86 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veino/veino"
+)
+
+// level is set from a string, as codecs
+type level struct {
+	Name string
+}
+
+func (l *level) UnmarshalText(text []byte) error {
+	l.Name = string(text)
+	return nil
+}
+
+func (l level) String() string { return l.Name }
+
+type describedOptions struct {
+	Add_field  map[string]interface{}
+	Host       string   `mapstructure:"host" validate:"required"`
+	Port       int      `mapstructure:"port"`
+	Mode       string   `mapstructure:"mode" enum:"fast,slow"`
+	Tags       []string `mapstructure:"tags"`
+	Level      level    `mapstructure:"level"`
+	Ignored    string   `mapstructure:"-"`
+	unexported bool
+}
+
+type described struct {
+	Base
+	opt *describedOptions
+}
+
+func (p *described) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
+	p.opt = &describedOptions{Port: 80, Mode: "fast", Level: level{Name: "info"}}
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+	panic("Configure should stop at ConfigureAndValidate")
+}
+
+func TestDescribe(t *testing.T) {
+	p := &described{}
+	d, err := Describe(p)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "processors", d.Name)
+	assert.Equal(t, []*OptionDescriptor{
+		{Name: "add_field", Field: "Add_field", Type: "hash", Items: "any"},
+		{Name: "host", Field: "Host", Type: "string", Required: true},
+		{Name: "port", Field: "Port", Type: "integer", Default: 80},
+		{Name: "mode", Field: "Mode", Type: "string", Default: "fast", Enum: []string{"fast", "slow"}},
+		{Name: "tags", Field: "Tags", Type: "array", Items: "string"},
+		{Name: "level", Field: "Level", Type: "level", Default: "info"},
+	}, d.Options)
+	assert.Equal(t, "port", d.Option("port").Name)
+	assert.Nil(t, d.Option("unknow"))
+
+	assert.False(t, p.describing, "describing mode should end with Describe")
+}
+
+func TestDescribeWithoutOptions(t *testing.T) {
+	d, err := Describe(&Base{})
+	assert.Nil(t, err)
+	assert.Equal(t, []*OptionDescriptor{}, d.Options)
+
+	_, err = Describe(&struct{ veino.Processor }{})
+	assert.NotNil(t, err, "processors not embedding Base can not be described")
+}
+
+func TestValidateEnums(t *testing.T) {
+	b := &Base{}
+	opt := &describedOptions{}
+	assert.Nil(t, b.ConfigureAndValidate(veino.ProcessorContext{}, map[string]interface{}{"host": "localhost", "mode": "slow"}, opt))
+
+	err := b.ConfigureAndValidate(veino.ProcessorContext{}, map[string]interface{}{"host": "localhost", "mode": "medium"}, opt)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "mode")
+	}
+}
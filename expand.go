@@ -0,0 +1,150 @@
+package processors
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// expandOptions returns a copy of conf where strings references are
+// replaced, at any depth :
+//
+//	${VAR}          the environment variable VAR, which must be set
+//	${VAR:default}  the environment variable VAR, or default when VAR is unset
+//	${secret:name}  the secret name of the secret provider, see SetSecretProvider
+//	$${             a literal ${
+//
+// Secrets are masked in logs, as are the values of variables expanded in
+// credential options, whose key holds password, secret or token.
+func expandOptions(processor string, conf map[string]interface{}) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(conf))
+	for key := range conf {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	expanded := make(map[string]interface{}, len(conf))
+	errs := OptionErrors{}
+	for _, key := range keys {
+		value, err := expandValue(processor, key, conf[key])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		expanded[key] = value
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return expanded, nil
+}
+
+func expandValue(processor string, path string, value interface{}) (interface{}, *OptionError) {
+	switch v := value.(type) {
+	case string:
+		s, err := expandString(v, sensitiveOption(path))
+		if err != nil {
+			return nil, &OptionError{Processor: processor, Key: path, Reason: err.Error()}
+		}
+		return s, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			expanded, err := expandValue(processor, path+"."+key, item)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = expanded
+		}
+		return m, nil
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			expanded, err := expandValue(processor, fmt.Sprintf("%s[%d]", path, i), item)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = expanded
+		}
+		return a, nil
+	case []string:
+		a := make([]string, len(v))
+		for i, item := range v {
+			expanded, err := expandString(item, sensitiveOption(path))
+			if err != nil {
+				return nil, &OptionError{Processor: processor, Key: fmt.Sprintf("%s[%d]", path, i), Reason: err.Error()}
+			}
+			a[i] = expanded
+		}
+		return a, nil
+	}
+	return value, nil
+}
+
+// sensitiveOption tells whether the option at path holds credentials
+func sensitiveOption(path string) bool {
+	path = strings.ToLower(path)
+	for _, word := range []string{"password", "secret", "token"} {
+		if strings.Contains(path, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandString replaces the references of s, masking the values of variables
+// in logs when mask is set
+func expandString(s string, mask bool) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			out = append(out, "${"...)
+			i += 2
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.Index(s[i:], "}")
+			if end < 0 {
+				return "", fmt.Errorf("unclosed reference in %q", s)
+			}
+			value, err := resolveReference(s[i+2 : i+end])
+			if err != nil {
+				return "", err
+			}
+			if mask {
+				MaskSecret(value)
+			}
+			out = append(out, value...)
+			i += end
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return string(out), nil
+}
+
+func resolveReference(ref string) (string, error) {
+	if strings.HasPrefix(ref, "secret:") {
+		return lookupSecret(strings.TrimPrefix(ref, "secret:"))
+	}
+
+	name, def, hasDefault := ref, "", false
+	if i := strings.Index(ref, ":"); i >= 0 {
+		name, def, hasDefault = ref[:i], ref[i+1:], true
+	}
+	if name == "" {
+		return "", fmt.Errorf("empty reference ${%s}", ref)
+	}
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set", name)
+}
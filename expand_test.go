@@ -0,0 +1,84 @@
+package processors
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapSecrets map[string]string
+
+func (m mapSecrets) Secret(name string) (string, error) {
+	if value, ok := m[name]; ok {
+		return value, nil
+	}
+	return "", ErrSecretNotFound
+}
+
+func TestExpandOptions(t *testing.T) {
+	os.Setenv("TEST_EXPAND_HOST", "rabbit.local")
+	defer os.Unsetenv("TEST_EXPAND_HOST")
+	SetSecretProvider(mapSecrets{"amqp": "s3cr3t"})
+	defer SetSecretProvider(nil)
+
+	conf := map[string]interface{}{
+		"host":  "${TEST_EXPAND_HOST}",
+		"label": "${TEST_EXPAND_UNSET:guest}-$${literal}",
+		"tags":  []interface{}{"a", "${secret:amqp}"},
+		"server": map[string]interface{}{
+			"name": "${TEST_EXPAND_HOST}:5672",
+		},
+	}
+	opt, err := configure(conf)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "rabbit.local", opt.Host)
+	assert.Equal(t, "guest-${literal}", opt.Label)
+	assert.Equal(t, []string{"a", "s3cr3t"}, opt.Tags)
+	assert.Equal(t, "rabbit.local:5672", opt.Server.Name)
+	assert.Equal(t, "${TEST_EXPAND_HOST}", conf["host"], "user options are not modified")
+	assert.Equal(t, "******", maskSecrets("s3cr3t"), "resolved secrets are masked")
+}
+
+func TestExpandCredentialsMasked(t *testing.T) {
+	os.Setenv("TEST_EXPAND_HOST", "rabbit.local")
+	defer os.Unsetenv("TEST_EXPAND_HOST")
+	os.Setenv("TEST_EXPAND_PASSWORD", "rabb1tpass")
+	defer os.Unsetenv("TEST_EXPAND_PASSWORD")
+
+	conf, err := expandOptions("output-rabbitmq", map[string]interface{}{
+		"host":     "${TEST_EXPAND_HOST}",
+		"password": "${TEST_EXPAND_PASSWORD}",
+		"oauth": map[string]interface{}{
+			"access_token": "${TEST_EXPAND_UNSET:t0k3n}",
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "rabb1tpass", conf["password"])
+
+	var buf bytes.Buffer
+	l := NewLogger(log.New(&buf, "", 0))
+	l.Info("connecting", "host", conf["host"], "password", conf["password"], "token", conf["oauth"].(map[string]interface{})["access_token"])
+	assert.Equal(t, "level=info msg=connecting host=rabbit.local password=****** token=******\n", buf.String())
+}
+
+func TestExpandErrors(t *testing.T) {
+	SetSecretProvider(nil)
+
+	_, err := configure(map[string]interface{}{"host": "${TEST_EXPAND_UNSET}"})
+	assert.EqualError(t, err, "processors : host : environment variable TEST_EXPAND_UNSET is not set")
+
+	_, err = configure(map[string]interface{}{"host": "h", "tags": []interface{}{"a", "${secret:amqp}"}})
+	assert.EqualError(t, err, "processors : tags[1] : no secret provider is set")
+
+	SetSecretProvider(mapSecrets{})
+	defer SetSecretProvider(nil)
+	_, err = configure(map[string]interface{}{"host": "h", "server": map[string]interface{}{"name": "${secret:amqp"}})
+	assert.EqualError(t, err, "processors : server.name : unclosed reference in \"${secret:amqp\"")
+}
@@ -0,0 +1,56 @@
+package processors
+
+import (
+	"github.com/veino/veino"
+)
+
+// Filters send events to PORT_SUCCESS, or to PORT_FAILURE when they could not
+// process them. Pipelines route failed events with their failure port, or with
+// a when condition on the FailureField.
+const (
+	PORT_SUCCESS = 0
+	PORT_FAILURE = 1
+)
+
+// FailureField holds the Failure of an event sent to PORT_FAILURE
+const FailureField = "_error"
+
+// Failure describes why a processor failed to process an event
+type Failure struct {
+	// Processor is the name of the failing processor, as "filter-json"
+	Processor string
+	// Reason is the error message
+	Reason string
+	// Value is the original value which could not be processed, if any
+	Value interface{}
+}
+
+// Map returns the failure as stored in an event
+func (f Failure) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"processor": f.Processor,
+		"reason":    f.Reason,
+	}
+	if f.Value != nil {
+		m["value"] = f.Value
+	}
+	return m
+}
+
+// Fail sets e's FailureField with err and the value which could not be
+// processed, appends tags to e and sends e to PORT_FAILURE
+func (b *Base) Fail(e veino.IPacket, err error, value interface{}, tags ...string) bool {
+	f := Failure{Processor: b.name, Value: value}
+	if err != nil {
+		f.Reason = err.Error()
+	}
+	SetField(e.Fields(), FailureField, f.Map())
+	if len(tags) > 0 {
+		AddTags(tags, e.Fields())
+	}
+
+	if b.failures != nil {
+		b.failures.Inc()
+	}
+	return b.Send(e, PORT_FAILURE)
+}
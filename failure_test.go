@@ -0,0 +1,46 @@
+package processors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veino/veino"
+)
+
+type failingOptions struct{}
+
+func TestFail(t *testing.T) {
+	sent := map[int][]veino.IPacket{}
+	ctx := veino.ProcessorContext{
+		PacketSender: func() veino.PacketSender {
+			return func(e veino.IPacket, ports ...int) bool {
+				for _, port := range ports {
+					sent[port] = append(sent[port], e)
+				}
+				return true
+			}
+		},
+	}
+
+	b := &Base{}
+	assert.Nil(t, b.ConfigureAndValidate(ctx, map[string]interface{}{}, &failingOptions{}))
+	failures := b.failures.Value()
+
+	e := NewPacket("hello", map[string]interface{}{"tags": []string{"a"}})
+	assert.True(t, b.Fail(e, errors.New("bad value"), "hello", "_failure"))
+
+	assert.Len(t, sent[PORT_SUCCESS], 0)
+	if assert.Len(t, sent[PORT_FAILURE], 1) {
+		fields := sent[PORT_FAILURE][0].Fields()
+		failure, _ := GetField(fields, FailureField)
+		assert.Equal(t, Failure{Processor: "processors", Reason: "bad value", Value: "hello"}.Map(), failure)
+		assert.Equal(t, []string{"a", "_failure"}, Tags(fields))
+	}
+	assert.Equal(t, failures+1, b.failures.Value())
+}
+
+func TestFailureMap(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{"processor": "filter-json", "reason": "invalid"},
+		Failure{Processor: "filter-json", Reason: "invalid"}.Map(), "nil value should be omitted")
+}
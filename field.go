@@ -2,38 +2,35 @@ package processors
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
-	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/clbanning/mxj"
 )
 
 const (
-	mark          = "%{"
-	mark_variable = "["
+	mark = "%{"
 )
 
-var maskPattern *regexp.Regexp
-var maskPattern_variable *regexp.Regexp
-
-func init() {
-	maskPattern, _ = regexp.Compile(`%{([\w\.]+)}`)
-	maskPattern_variable, _ = regexp.Compile(`\[([\w\.]+)\]`)
-}
-
-// Dynamic includes field value in place of %{key.path}
+// Dynamic includes field value in place of %{key.path} or %{[key][path]}
 // When no field is not found replace with ""
+//
+// str is parsed as a Template, see Template for the supported syntax.
+// Processors rendering the same option for each event should rather parse
+// it once with NewTemplate when configured.
 func Dynamic(str *string, fields *mxj.Map) {
 	// If %{ exists in value
-	if true == strings.Contains(*str, mark) {
-		// Search for all %{word}
-		for _, values := range maskPattern.FindAllStringSubmatch(*str, -1) {
-			// Search matching value, when not found use ""
-			replaceBy := fields.ValueOrEmptyForPathString(values[1])
-			*str = strings.Replace(*str, values[0], replaceBy, -1)
-		}
+	if !strings.Contains(*str, mark) {
+		return
 	}
+	t, err := cachedTemplate(*str)
+	if err != nil {
+		return
+	}
+	*str = t.Render(fields)
 }
 
 func ProcessCommonFields(data *mxj.Map, add_fields map[string]interface{}, tags []string, typevalue string) {
@@ -68,7 +65,7 @@ func ProcessCommonFields2(data *mxj.Map,
 
 func SetType(typevalue string, data *mxj.Map) {
 	Dynamic(&typevalue, data)
-	data.SetValueForPath(typevalue, "type")
+	SetField(data, "type", typevalue)
 }
 
 func AddFields(fields map[string]interface{}, data *mxj.Map) {
@@ -81,61 +78,81 @@ func AddFields(fields map[string]interface{}, data *mxj.Map) {
 			v = d
 		}
 
-		data.SetValueForPath(v, k)
+		SetField(data, k, v)
 	}
 }
 
 func AddTags(tags []string, data *mxj.Map) {
-	currenttags, err := data.ValueForPath("tags")
-	if err != nil {
-		currenttags = []string{}
-	}
-
 	tags_eval := []string{}
 	for _, t := range tags {
 		Dynamic(&t, data)
 		tags_eval = append(tags_eval, t)
 	}
 
-	newtags := append(currenttags.([]string), tags_eval...)
-	data.SetValueForPath(newtags, "tags")
+	newtags := append(Tags(data), tags_eval...)
+	SetField(data, "tags", newtags)
 }
 
 func RemoveTags(tags []string, data *mxj.Map) {
-	currenttags, err := data.ValueForPath("tags")
-	if err != nil {
+	if !FieldExists(data, "tags") {
 		return
 	}
 
-	ct := currenttags.([]string)
-	for i, t := range ct {
-		for _, ttodelete := range tags {
-			Dynamic(&ttodelete, data)
-			if ttodelete == t {
-				//delete
-				ct = append(ct[:i], ct[i+1:]...)
-			}
+	todelete := map[string]bool{}
+	for _, t := range tags {
+		Dynamic(&t, data)
+		todelete[t] = true
+	}
+
+	ct := []string{}
+	for _, t := range Tags(data) {
+		if !todelete[t] {
+			ct = append(ct, t)
 		}
+	}
 
+	SetField(data, "tags", ct)
+}
+
+// Tags returns data's tags, whatever the type they are stored with
+func Tags(data *mxj.Map) []string {
+	value, err := GetField(data, "tags")
+	if err != nil {
+		return []string{}
 	}
 
-	data.SetValueForPath(ct, "tags")
+	switch v := value.(type) {
+	case []string:
+		return append([]string{}, v...)
+	case []interface{}:
+		tags := []string{}
+		for _, t := range v {
+			tags = append(tags, fmt.Sprintf("%v", t))
+		}
+		return tags
+	case string:
+		return []string{v}
+	}
+	return []string{}
 }
 
 func RemoveFields(fields []string, data *mxj.Map) {
 	for _, k := range fields {
 		Dynamic(&k, data)
-		data.Remove(k)
+		RemoveField(data, k)
 	}
 }
 
 func RemoveAllButFields(fields []string, data *mxj.Map) {
 	if len(fields) > 0 {
 		cp := mxj.New()
+		if metadata, ok := (*data)[MetadataField]; ok {
+			cp[MetadataField] = metadata
+		}
 		for _, k := range fields {
 			Dynamic(&k, data)
-			if value, err := data.ValueForPath(k); err == nil {
-				cp.SetValueForPath(value, k)
+			if value, err := GetField(data, k); err == nil {
+				SetField(&cp, k, value)
 			}
 		}
 		*data = cp
@@ -144,71 +161,133 @@ func RemoveAllButFields(fields []string, data *mxj.Map) {
 
 func UpdateFields(fields map[string]interface{}, data *mxj.Map) {
 	for k, v := range fields {
-		if data.Exists(k) {
-			data.SetValueForPath(v, k)
+		if FieldExists(data, k) {
+			SetField(data, k, v)
 		}
 	}
 }
 
 func RenameFields(fields map[string]string, data *mxj.Map) {
 	for k, v := range fields {
-		if data.Exists(k) {
-			data.RenameKey(k, v)
+		if FieldExists(data, k) {
+			RenameField(data, k, v)
 		}
 	}
 }
 
 func UpperCaseFields(fields []string, data *mxj.Map) {
 	for _, k := range fields {
-		if value, err := data.ValueForPathString(k); err == nil {
-			data.SetValueForPath(strings.ToUpper(value), k)
+		if value, err := GetFieldString(data, k); err == nil {
+			SetField(data, k, strings.ToUpper(value))
 		}
 	}
 }
 
 func LowerCaseFields(fields []string, data *mxj.Map) {
 	for _, k := range fields {
-		if value, err := data.ValueForPathString(k); err == nil {
-			data.SetValueForPath(strings.ToLower(value), k)
+		if value, err := GetFieldString(data, k); err == nil {
+			SetField(data, k, strings.ToLower(value))
 		}
 	}
 }
 
 func Join(fields map[string]string, data *mxj.Map) {
 	for path, glue := range fields {
-		if !data.Exists(path) {
+		if !FieldExists(data, path) {
 			continue
 		}
-		value, _ := data.ValueForPath(path)
+		value, _ := GetField(data, path)
 
-		switch value.(type) {
+		a := []string{}
+		switch v := value.(type) {
 		case []string:
-			a := []string{}
-			for _, s := range value.([]interface{}) {
-				a = append(a, s.(string))
+			a = v
+		case []interface{}:
+			for _, s := range v {
+				a = append(a, fmt.Sprintf("%v", s))
 			}
-			newValue := strings.Join(a, glue)
-			data.SetValueForPath(newValue, path)
+		default:
+			continue
 		}
+		SetField(data, path, strings.Join(a, glue))
 	}
 }
 
 func Split(fields map[string]string, data *mxj.Map) {
+	SplitN(fields, 0, data)
+}
+
+// SplitN splits fields as Split in at most limit elements, the last holding
+// the rest of the string. There is no limit when limit <= 0
+func SplitN(fields map[string]string, limit int, data *mxj.Map) {
+	if limit <= 0 {
+		limit = -1
+	}
 	for path, separator := range fields {
-		if !data.Exists(path) {
+		if !FieldExists(data, path) {
 			continue
 		}
-		value := data.ValueOrEmptyForPathString(path)
-		newValue := strings.Split(value, separator)
-		data.SetValueForPath(newValue, path)
+		value := GetFieldOrEmptyString(data, path)
+		newValue := strings.SplitN(value, separator, limit)
+		SetField(data, path, newValue)
+	}
+}
+
+// CopyFields copies the value of each source field to its destination field,
+// replacing it. Hashes and arrays are copied, not shared
+func CopyFields(fields map[string]string, data *mxj.Map) {
+	for src, dst := range fields {
+		if value, err := GetField(data, src); err == nil {
+			SetField(data, dst, deepCopy(value))
+		}
+	}
+}
+
+// CoerceFields sets fields which are missing or null to their default value
+func CoerceFields(fields map[string]interface{}, data *mxj.Map) {
+	for path, def := range fields {
+		if value, err := GetField(data, path); err != nil || value == nil {
+			SetField(data, path, deepCopy(def))
+		}
+	}
+}
+
+// CapitalizeFields upper cases the first letter of string fields and lower
+// cases the others
+func CapitalizeFields(fields []string, data *mxj.Map) {
+	for _, k := range fields {
+		if value, err := GetFieldString(data, k); err == nil && value != "" {
+			r, size := utf8.DecodeRuneInString(value)
+			SetField(data, k, string(unicode.ToUpper(r))+strings.ToLower(value[size:]))
+		}
+	}
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = deepCopy(item)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			a[i] = deepCopy(item)
+		}
+		return a
+	case []string:
+		return append([]string{}, v...)
 	}
+	return value
 }
 
 func Strip(fields []string, data *mxj.Map) {
 	for _, path := range fields {
-		if value, err := data.ValueForPathString(path); err == nil {
+		if value, err := GetFieldString(data, path); err == nil {
 			newValue := strings.TrimSpace(value)
-			data.SetValueForPath(newValue, path)
+			SetField(data, path, newValue)
 		}
 
 	}
@@ -222,156 +301,109 @@ func Gsub(fields []string, data *mxj.Map) {
 		i++
 		replacement := fields[i]
 
-		if value, err := data.ValueForPathString(fieldname); err == nil {
+		if value, err := GetFieldString(data, fieldname); err == nil {
 			r, _ := regexp.Compile(pattern)
 			newValue := r.ReplaceAllString(value, replacement)
-			data.SetValueForPath(newValue, fieldname)
+			SetField(data, fieldname, newValue)
 		}
 
 	}
 }
 
-func Convert(fields map[string]string, data *mxj.Map) {
-
-	for path, kind := range fields {
-		if !data.Exists(path) {
+// Merge merges each source field into its destination field :
+//
+//	arrays and strings are appended to the destination array, without
+//	duplicates, a destination string is turned into an array
+//	hashes are merged into the destination hash, recursively, values of the
+//	source replace other values of the destination
+//
+// Nothing is done when one of the fields is missing, or when a hash is merged
+// with a value which is not a hash
+func Merge(fields map[string]string, data *mxj.Map) {
+	for path_dst, path_src := range fields {
+		value_src, err := GetField(data, path_src)
+		if err != nil {
 			continue
 		}
-
-		value, err := data.ValueForPath(path)
+		value_dst, err := GetField(data, path_dst)
 		if err != nil {
 			continue
 		}
 
-		switch value.(type) {
-		case string:
-			switch kind {
-			case "integer":
-				newValue, err := strconv.Atoi(value.(string))
-				if err != nil {
-					continue
-				}
-				data.SetValueForPath(newValue, path)
-			case "float":
-				newValue, err := strconv.ParseFloat(value.(string), 64)
-				if err != nil {
-					continue
-				}
-				data.SetValueForPath(newValue, path)
-			case "boolean":
-				newValue := false
-				value = strings.ToLower(value.(string))
-				for _, b := range []string{"true", "t", "yes", "y", "1"} {
-					if b == value {
-						newValue = true
-					}
-				}
-				data.SetValueForPath(newValue, path)
-			}
-		case int:
-			switch kind {
-			case "string":
-				newValue := fmt.Sprintf("%d", value.(int))
-				data.SetValueForPath(newValue, path)
-			case "float":
-				newValue := float64(value.(int))
-				data.SetValueForPath(newValue, path)
-			case "boolean":
-				newValue := false
-				if value.(int) > 0 {
-					newValue = true
-				}
-				data.SetValueForPath(newValue, path)
-			}
-		case float64:
-			switch kind {
-			case "string":
-				newValue := fmt.Sprintf("%f", value.(float64))
-				data.SetValueForPath(newValue, path)
-			case "integer":
-				newValue := int(value.(float64))
-				data.SetValueForPath(newValue, path)
-			case "boolean":
-				newValue := false
-				if value.(float64) > 0 {
-					newValue = true
-				}
-				data.SetValueForPath(newValue, path)
-			}
-		case bool:
-			switch kind {
-			case "string":
-				newValue := "false"
-				if value.(bool) == true {
-					newValue = "true"
-				}
-				data.SetValueForPath(newValue, path)
-			case "integer":
-				var newValue int
-				newValue = 0
-				if value.(bool) == true {
-					newValue = 1
-				}
-				data.SetValueForPath(newValue, path)
-			case "float":
-				var newValue float64
-				newValue = 0
-				if value.(bool) == true {
-					newValue = 1
-				}
-				data.SetValueForPath(newValue, path)
-			}
+		if merged, ok := mergeValues(value_dst, value_src); ok {
+			SetField(data, path_dst, merged)
 		}
-
 	}
-
 }
 
-func Merge(fields map[string]string, data *mxj.Map) {
-	for path_dst, path_src := range fields {
-		if !data.Exists(path_dst) || !data.Exists(path_src) {
-			continue
+func mergeValues(dst interface{}, src interface{}) (interface{}, bool) {
+	dstMap, dstIsMap := dst.(map[string]interface{})
+	srcMap, srcIsMap := src.(map[string]interface{})
+	if dstIsMap || srcIsMap {
+		if !dstIsMap || !srcIsMap {
+			return nil, false
 		}
-		value_src, _ := data.ValueForPath(path_src)
-		value_dst, _ := data.ValueForPath(path_dst)
-
-		a := []string{}
-		b := []string{}
+		return mergeMaps(dstMap, srcMap), true
+	}
 
-		// newValue := append(value_dst, value_src...)
-		switch value_src.(type) {
-		case []string:
+	a := append(asArray(dst), asArray(src)...)
 
-			for _, s := range value_src.([]string) {
-				a = append(a, s)
-			}
-		default:
+	// Remove duplicates, arrays of strings stay arrays of strings
+	result := []interface{}{}
+	strs := []string{}
+	allStrings := true
+	for _, val := range a {
+		if containsValue(result, val) {
 			continue
 		}
+		result = append(result, val)
+		s, ok := val.(string)
+		allStrings = allStrings && ok
+		strs = append(strs, s)
+	}
+	if allStrings {
+		return strs, true
+	}
+	return result, true
+}
 
-		// newValue := append(value_dst, value_src...)
-		switch value_dst.(type) {
-		case []string:
-
-			for _, s := range value_dst.([]string) {
-				b = append(b, s)
-			}
-		default:
+func mergeMaps(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(dst)+len(src))
+	for key, value := range dst {
+		m[key] = value
+	}
+	for key, value := range src {
+		dstMap, dstIsMap := m[key].(map[string]interface{})
+		srcMap, srcIsMap := value.(map[string]interface{})
+		if dstIsMap && srcIsMap {
+			m[key] = mergeMaps(dstMap, srcMap)
 			continue
 		}
+		m[key] = deepCopy(value)
+	}
+	return m
+}
 
-		a = append(b, a...)
-
-		//Remove duplicates
-		result := []string{}
-		seen := map[string]string{}
-		for _, val := range a {
-			if _, ok := seen[val]; !ok {
-				result = append(result, val)
-				seen[val] = val
-			}
+// asArray returns the elements of an array, or a single value as an array
+func asArray(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		a := make([]interface{}, len(v))
+		for i, s := range v {
+			a[i] = s
 		}
+		return a
+	}
+	return []interface{}{value}
+}
 
-		data.SetValueForPath(result, path_dst)
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
 	}
+	return false
 }
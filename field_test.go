@@ -34,3 +34,13 @@ func TestDynamic(t *testing.T) {
 	Dynamic(&str, &fields)
 	assert.Equal(t, "Here nothing replaced  !", str, "")
 }
+
+func TestDynamicTemplate(t *testing.T) {
+	fields := getTestFields()
+	fields["@timestamp"] = "2016-06-01T10:00:00Z"
+	fields["count"] = 2
+
+	str := "%{twitter:-none} %{unknow:-none} %{count} %{+YYYY.MM}"
+	Dynamic(&str, &fields)
+	assert.Equal(t, "@vjeantet none 2 2016.06", str, "")
+}
@@ -0,0 +1,336 @@
+package processors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/clbanning/mxj"
+)
+
+// FieldReference is a parsed reference to an event's field, each element is
+// a nested map key or an array index.
+//
+// A reference is written with the Logstash syntax "[location][city]",
+// "[tags][0]", or with dots "location.city", "tags.0", "tags[0]".
+// Within brackets a dot belongs to the key ("[user.name]"), out of brackets
+// it is escaped with a backslash ("user\.name").
+type FieldReference []string
+
+// ParseFieldReference parses ref into a FieldReference
+func ParseFieldReference(ref string) (FieldReference, error) {
+	if ref == "" {
+		return nil, errors.New("empty field reference")
+	}
+	if ref[0] == '[' {
+		return parseBracketReference(ref)
+	}
+	return parseDottedReference(ref)
+}
+
+// parseBracketReference parses "[a][b]" references, keys are kept as written
+func parseBracketReference(ref string) (FieldReference, error) {
+	r := FieldReference{}
+	for s := ref; len(s) > 0; {
+		if s[0] != '[' {
+			return nil, fmt.Errorf("invalid field reference %q : expected [ at %q", ref, s)
+		}
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid field reference %q : unclosed [", ref)
+		}
+		if end == 1 {
+			return nil, fmt.Errorf("invalid field reference %q : empty key", ref)
+		}
+		r = append(r, s[1:end])
+		s = s[end+1:]
+	}
+	return r, nil
+}
+
+// parseDottedReference parses "a.b", "a\.b" and "a[0]" references
+func parseDottedReference(ref string) (FieldReference, error) {
+	r := FieldReference{}
+	var key bytes.Buffer
+	// closed is true after a [index], when the next key may start without dot
+	closed := false
+
+	for i := 0; i < len(ref); i++ {
+		c := ref[i]
+		switch {
+		case c == '\\' && i+1 < len(ref):
+			i++
+			key.WriteByte(ref[i])
+		case c == '.':
+			if key.Len() == 0 && !closed {
+				return nil, fmt.Errorf("invalid field reference %q : empty key", ref)
+			}
+			if key.Len() > 0 {
+				r = append(r, key.String())
+				key.Reset()
+			}
+			closed = false
+			if i == len(ref)-1 {
+				return nil, fmt.Errorf("invalid field reference %q : empty key", ref)
+			}
+		case c == '[':
+			end := strings.IndexByte(ref[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid field reference %q : unclosed [", ref)
+			}
+			if end == 1 {
+				return nil, fmt.Errorf("invalid field reference %q : empty key", ref)
+			}
+			if key.Len() > 0 {
+				r = append(r, key.String())
+				key.Reset()
+			}
+			r = append(r, ref[i+1:i+end])
+			i += end
+			closed = true
+		default:
+			if closed {
+				return nil, fmt.Errorf("invalid field reference %q : expected . or [ after ]", ref)
+			}
+			key.WriteByte(c)
+		}
+	}
+	if key.Len() > 0 {
+		r = append(r, key.String())
+	}
+	return r, nil
+}
+
+// String returns the reference with the bracket syntax
+func (r FieldReference) String() string {
+	var buf bytes.Buffer
+	for _, key := range r {
+		buf.WriteString("[" + key + "]")
+	}
+	return buf.String()
+}
+
+// Get returns the value referenced by r in data
+func (r FieldReference) Get(data *mxj.Map) (interface{}, error) {
+	var node interface{} = map[string]interface{}(*data)
+	for _, key := range r {
+		value, ok := childValue(node, key)
+		if !ok {
+			return nil, fmt.Errorf("field %s not found", r)
+		}
+		node = value
+	}
+	return node, nil
+}
+
+// Exists returns true when r references a value in data
+func (r FieldReference) Exists(data *mxj.Map) bool {
+	_, err := r.Get(data)
+	return err == nil
+}
+
+// Set sets value in data at r, missing maps on the path are created
+func (r FieldReference) Set(data *mxj.Map, value interface{}) error {
+	if len(r) == 0 {
+		return errors.New("empty field reference")
+	}
+	if *data == nil {
+		*data = mxj.Map{}
+	}
+
+	var node interface{} = map[string]interface{}(*data)
+	for i, key := range r[:len(r)-1] {
+		next, ok := childValue(node, key)
+		if !ok || !isContainer(next) {
+			m, isMap := asMap(node)
+			if !isMap {
+				return fmt.Errorf("can not set %s : %s is not an object", r, r[:i+1])
+			}
+			next = map[string]interface{}{}
+			m[key] = next
+		}
+		node = next
+	}
+	return setChildValue(node, r[len(r)-1], value)
+}
+
+// Remove deletes the value referenced by r from data, it returns false when
+// there was nothing to delete
+func (r FieldReference) Remove(data *mxj.Map) bool {
+	if len(r) == 0 {
+		return false
+	}
+	parent, err := r[:len(r)-1].Get(data)
+	if err != nil {
+		return false
+	}
+	key := r[len(r)-1]
+
+	if m, ok := asMap(parent); ok {
+		if _, ok := m[key]; !ok {
+			return false
+		}
+		delete(m, key)
+		return true
+	}
+
+	v := reflect.ValueOf(parent)
+	if v.Kind() != reflect.Slice {
+		return false
+	}
+	i, ok := sliceIndex(key, v.Len())
+	if !ok {
+		return false
+	}
+	shorter := reflect.MakeSlice(v.Type(), 0, v.Len()-1)
+	shorter = reflect.AppendSlice(shorter, v.Slice(0, i))
+	shorter = reflect.AppendSlice(shorter, v.Slice(i+1, v.Len()))
+	return r[:len(r)-1].Set(data, shorter.Interface()) == nil
+}
+
+// GetField returns the value of the field ref in data
+func GetField(data *mxj.Map, ref string) (interface{}, error) {
+	r, err := ParseFieldReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(data)
+}
+
+// GetFieldString returns the value of the field ref in data, an error is
+// returned when the value is not a string
+func GetFieldString(data *mxj.Map, ref string) (string, error) {
+	value, err := GetField(data, ref)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s is not a string : %T", ref, value)
+	}
+	return s, nil
+}
+
+// GetFieldOrEmptyString returns the string value of the field ref in data, or
+// "" when it is not found or is not a string
+func GetFieldOrEmptyString(data *mxj.Map, ref string) string {
+	s, _ := GetFieldString(data, ref)
+	return s
+}
+
+// SetField sets value at field ref in data
+func SetField(data *mxj.Map, ref string, value interface{}) error {
+	r, err := ParseFieldReference(ref)
+	if err != nil {
+		return err
+	}
+	return r.Set(data, value)
+}
+
+// RemoveField removes field ref from data
+func RemoveField(data *mxj.Map, ref string) bool {
+	r, err := ParseFieldReference(ref)
+	if err != nil {
+		return false
+	}
+	return r.Remove(data)
+}
+
+// FieldExists returns true when field ref exists in data
+func FieldExists(data *mxj.Map, ref string) bool {
+	_, err := GetField(data, ref)
+	return err == nil
+}
+
+// RenameField moves the value of field from to field to
+func RenameField(data *mxj.Map, from string, to string) error {
+	value, err := GetField(data, from)
+	if err != nil {
+		return err
+	}
+	if err := SetField(data, to, value); err != nil {
+		return err
+	}
+	RemoveField(data, from)
+	return nil
+}
+
+func asMap(node interface{}) (map[string]interface{}, bool) {
+	switch m := node.(type) {
+	case map[string]interface{}:
+		return m, true
+	case mxj.Map:
+		return map[string]interface{}(m), true
+	}
+	return nil, false
+}
+
+func isContainer(node interface{}) bool {
+	if _, ok := asMap(node); ok {
+		return true
+	}
+	return node != nil && reflect.TypeOf(node).Kind() == reflect.Slice
+}
+
+// childValue returns the value at key in node, a map or a slice
+func childValue(node interface{}, key string) (interface{}, bool) {
+	if m, ok := asMap(node); ok {
+		value, ok := m[key]
+		return value, ok
+	}
+
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	i, ok := sliceIndex(key, v.Len())
+	if !ok {
+		return nil, false
+	}
+	return v.Index(i).Interface(), true
+}
+
+func setChildValue(node interface{}, key string, value interface{}) error {
+	if m, ok := asMap(node); ok {
+		m[key] = value
+		return nil
+	}
+
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("can not set %s in a %T", key, node)
+	}
+	i, ok := sliceIndex(key, v.Len())
+	if !ok {
+		return fmt.Errorf("index %s out of range", key)
+	}
+	elem := v.Index(i)
+	newValue := reflect.ValueOf(value)
+	if !newValue.IsValid() {
+		newValue = reflect.Zero(elem.Type())
+	}
+	if !newValue.Type().AssignableTo(elem.Type()) {
+		return fmt.Errorf("can not set a %T in a %s", value, v.Type())
+	}
+	elem.Set(newValue)
+	return nil
+}
+
+// sliceIndex converts key to an index within length, negative indexes count
+// from the end
+func sliceIndex(key string, length int) (int, bool) {
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, false
+	}
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return 0, false
+	}
+	return i, true
+}
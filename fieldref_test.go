@@ -0,0 +1,93 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/clbanning/mxj"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFieldReference(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected FieldReference
+	}{
+		{"name", FieldReference{"name"}},
+		{"@timestamp", FieldReference{"@timestamp"}},
+		{"[location][city]", FieldReference{"location", "city"}},
+		{"location.city", FieldReference{"location", "city"}},
+		{"[tags][0]", FieldReference{"tags", "0"}},
+		{"tags.0", FieldReference{"tags", "0"}},
+		{"tags[0]", FieldReference{"tags", "0"}},
+		{"a[0][1].b", FieldReference{"a", "0", "1", "b"}},
+		{"[user.name]", FieldReference{"user.name"}},
+		{`user\.name`, FieldReference{"user.name"}},
+		{`a.user\.name`, FieldReference{"a", "user.name"}},
+	}
+	for _, test := range tests {
+		r, err := ParseFieldReference(test.ref)
+		assert.Nil(t, err, test.ref)
+		assert.Equal(t, test.expected, r, test.ref)
+	}
+
+	for _, ref := range []string{"", "[a", "[a]b", "[]", "a..b", "a.", ".a", "a[0]b"} {
+		_, err := ParseFieldReference(ref)
+		assert.NotNil(t, err, ref)
+	}
+}
+
+func TestFieldReferenceGetSet(t *testing.T) {
+	fields := getTestFields()
+	fields["tags"] = []string{"a", "b", "c"}
+	fields["user.name"] = "valere"
+
+	value, err := GetField(&fields, "[location][city]")
+	assert.Nil(t, err)
+	assert.Equal(t, "Paris", value)
+
+	value, _ = GetField(&fields, "[tags][-1]")
+	assert.Equal(t, "c", value)
+
+	assert.Equal(t, "valere", GetFieldOrEmptyString(&fields, "[user.name]"))
+	assert.Equal(t, "", GetFieldOrEmptyString(&fields, "user.name"))
+	assert.False(t, FieldExists(&fields, "[tags][3]"))
+
+	assert.Nil(t, SetField(&fields, "[tags][1]", "B"))
+	assert.Equal(t, []string{"a", "B", "c"}, fields["tags"])
+	assert.NotNil(t, SetField(&fields, "[tags][1]", 2), "an int can not be set into []string")
+
+	assert.Nil(t, SetField(&fields, "[a][b][c]", 1))
+	assert.Equal(t, map[string]interface{}{"b": map[string]interface{}{"c": 1}}, fields["a"])
+
+	assert.True(t, RemoveField(&fields, "[tags][0]"))
+	assert.Equal(t, []string{"B", "c"}, fields["tags"])
+	assert.True(t, RemoveField(&fields, "location.city"))
+	assert.Equal(t, map[string]interface{}{"country": "France"}, fields["location"])
+	assert.False(t, RemoveField(&fields, "location.city"))
+
+	assert.Nil(t, RenameField(&fields, "[user.name]", "[user][name]"))
+	assert.Equal(t, map[string]interface{}{"name": "valere"}, fields["user"])
+	assert.False(t, FieldExists(&fields, "[user.name]"))
+}
+
+func TestFieldHelpersReferences(t *testing.T) {
+	fields := mxj.Map{
+		"location": map[string]interface{}{"city": "paris"},
+		"tags":     []interface{}{"a", "b"},
+	}
+
+	UpperCaseFields([]string{"[location][city]"}, &fields)
+	assert.Equal(t, "PARIS", GetFieldOrEmptyString(&fields, "location.city"))
+
+	AddFields(map[string]interface{}{"[geo][city]": "%{[location][city]}"}, &fields)
+	assert.Equal(t, "PARIS", GetFieldOrEmptyString(&fields, "geo.city"))
+
+	AddTags([]string{"c"}, &fields)
+	assert.Equal(t, []string{"a", "b", "c"}, fields["tags"])
+
+	RemoveTags([]string{"a", "c"}, &fields)
+	assert.Equal(t, []string{"b"}, fields["tags"])
+
+	RenameFields(map[string]string{"[location][city]": "[city]"}, &fields)
+	assert.Equal(t, "PARIS", fields["city"])
+}
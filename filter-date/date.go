@@ -1,14 +1,27 @@
 package date
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/veino/processors"
 	"github.com/veino/veino"
 )
 
+const (
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
+)
+
 func New() veino.Processor {
-	return &processor{opt: &options{}}
+	return &processor{opt: &options{}, now: time.Now}
 }
 
 type processor struct {
@@ -16,18 +29,48 @@ type processor struct {
 
 	match_field_name string
 	match_patterns   []string
-	opt              *options
+	parsers          []parser
+	location         *time.Location
+	// the Timezone template, when it references fields
+	timezone   *processors.Template
+	translator *translator
+	format     func(t time.Time) interface{}
+	// now is the time years of yearless dates are inferred from
+	now func() time.Time
+	opt *options
 }
 
+// parser parses a value with one of the Match formats
+type parser func(value string, location *time.Location) (time.Time, error)
+
 type options struct {
 	// If this filter is successful, add any arbitrary fields to this event.
 	Add_field map[string]interface{}
 
-	// The date formats allowed are anything allowed by Golang time format.
-	// You can see the docs for this format https://golang.org/src/time/format.go#L20
 	// An array with field name first, and format patterns following, [ field, formats... ]
+	// Formats are tried in order, they are Joda-Time patterns as "dd/MMM/yyyy:HH:mm:ss Z",
+	// Golang time layouts as "02/Jan/2006:15:04:05 -0700" (a format holding a digit),
+	// or one of the special formats :
+	// ISO8601 as "2011-04-19T03:44:01.103Z" or "2011-04-19 03:44:01,103"
+	// UNIX seconds since epoch, as 1326149001.132
+	// UNIX_MS milliseconds since epoch, as 1366125117000
+	// TAI64N as "@4000000050d506482dbdf024"
 	Match []string
 
+	// The locale of month and day names, as "fr" or "de_DE", used by Joda-Time
+	// patterns and Golang time layouts. Supported languages are en, de, es, fr,
+	// it, nl and pt. English names are always understood
+	Locale string
+
+	// Store the original value of the date field into this field, as "logdate_original"
+	Original_field string
+
+	// The format of the date stored into Target : a Joda-Time pattern, a Golang
+	// time layout (a format holding a digit), ISO8601, UNIX for a number of seconds
+	// or UNIX_MS for a number of milliseconds since epoch
+	// @default : "2006-01-02T15:04:05.999Z07:00"
+	Output_format string
+
 	// If this filter is successful, add arbitrary tags to the event. Tags can be dynamic
 	// and include parts of the event using the %{field} syntax.
 	Tags []string
@@ -38,7 +81,7 @@ type options struct {
 	Remove_Tag []string
 
 	// Append values to the tags field when there has been no successful match
-	// @default : ["_grokparsefailure"]
+	// @default : ["_dateparsefailure"]
 	Tag_on_failure []string
 
 	// Store the matching timestamp into the given target field. If not provided,
@@ -47,10 +90,11 @@ type options struct {
 
 	// Specify a time zone canonical ID to be used for date parsing.
 	// The valid IDs are listed on IANA Time Zone database, such as "America/New_York".
-	// This is useful in case the time zone cannot be extracted from the value,
-	// and is not the platform default. If this is not specified the platform default
-	//  will be used. Canonical ID is good as it takes care of daylight saving time
-	// for you For example, America/Los_Angeles or Europe/Paris are valid IDs.
+	// This is useful in case the time zone cannot be extracted from the value.
+	// If this is not specified UTC will be used. Canonical ID is good as it takes
+	// care of daylight saving time for you For example, America/Los_Angeles or
+	// Europe/Paris are valid IDs. It does not apply to UNIX, UNIX_MS and TAI64N
+	// formats, nor to dates holding a time zone offset.
 	// This field can be dynamic and include parts of the event using the %{field} syntax
 	Timezone string
 }
@@ -58,50 +102,282 @@ type options struct {
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
 	p.opt.Target = "@timestamp"
 	p.opt.Tag_on_failure = []string{"_dateparsefailure"}
+	p.opt.Output_format = veino.VeinoTime
 
 	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
 		return err
 	}
 
+	if len(p.opt.Match) < 2 {
+		return &processors.OptionError{Processor: "filter-date", Key: "match", Reason: "set a field and at least one format"}
+	}
 	p.match_field_name = p.opt.Match[0]
 	p.match_patterns = p.opt.Match[1:]
 
+	p.parsers = make([]parser, len(p.match_patterns))
+	for i, format := range p.match_patterns {
+		parser, err := newParser(format)
+		if err != nil {
+			return &processors.OptionError{
+				Processor: "filter-date",
+				Key:       fmt.Sprintf("match[%d]", i+1),
+				Reason:    err.Error(),
+			}
+		}
+		p.parsers[i] = parser
+	}
+
+	p.location = time.UTC
+	if strings.Contains(p.opt.Timezone, "%{") {
+		timezone, err := processors.NewTemplate(p.opt.Timezone)
+		if err != nil {
+			return &processors.OptionError{Processor: "filter-date", Key: "timezone", Reason: err.Error()}
+		}
+		p.timezone = timezone
+	} else if p.opt.Timezone != "" {
+		location, err := time.LoadLocation(p.opt.Timezone)
+		if err != nil {
+			return &processors.OptionError{Processor: "filter-date", Key: "timezone", Reason: err.Error()}
+		}
+		p.location = location
+	}
+
+	if p.opt.Locale != "" {
+		translator, ok := newTranslator(p.opt.Locale)
+		if !ok {
+			return &processors.OptionError{Processor: "filter-date", Key: "locale", Reason: "unsupported locale " + p.opt.Locale}
+		}
+		p.translator = translator
+	}
+
+	p.format = newFormat(p.opt.Output_format)
+
 	return nil
 }
 
+// newFormat returns the formatter of Output_format
+func newFormat(format string) func(t time.Time) interface{} {
+	switch format {
+	case "ISO8601":
+		return func(t time.Time) interface{} { return t.Format("2006-01-02T15:04:05.000Z07:00") }
+	case "UNIX":
+		return func(t time.Time) interface{} { return float64(t.Unix()) + float64(t.Nanosecond())/float64(time.Second) }
+	case "UNIX_MS":
+		return func(t time.Time) interface{} { return t.UnixNano() / int64(time.Millisecond) }
+	}
+	if strings.ContainsAny(format, "0123456789") {
+		return func(t time.Time) interface{} { return t.Format(format) }
+	}
+	joda := processors.NewJodaFormatter(format)
+	return func(t time.Time) interface{} { return joda.Format(t) }
+}
+
+// locations caches the time zones of the dynamic Timezone
+var locations = struct {
+	sync.RWMutex
+	m map[string]*time.Location
+}{m: map[string]*time.Location{}}
+
+func loadLocation(name string) (*time.Location, error) {
+	locations.RLock()
+	location, ok := locations.m[name]
+	locations.RUnlock()
+	if ok {
+		return location, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Lock()
+	locations.m[name] = location
+	locations.Unlock()
+	return location, nil
+}
+
+// inferYear sets the year of a date parsed without year to the one bringing
+// it closest to now, a "Dec 31" date received on January 1st is of the
+// previous year, a "Jan 1" date received on December 31st of the next one
+func inferYear(t time.Time, now time.Time) time.Time {
+	now = now.In(t.Location())
+	var best time.Time
+	for _, year := range []int{now.Year() - 1, now.Year(), now.Year() + 1} {
+		candidate := time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		if best.IsZero() || abs(candidate.Sub(now)) < abs(best.Sub(now)) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// newParser returns the parser of a Match format
+func newParser(format string) (parser, error) {
+	switch format {
+	case "ISO8601":
+		return parseISO8601, nil
+	case "UNIX":
+		return func(value string, _ *time.Location) (time.Time, error) { return parseEpoch(value, 1) }, nil
+	case "UNIX_MS":
+		return func(value string, _ *time.Location) (time.Time, error) { return parseEpoch(value, 1000) }, nil
+	case "TAI64N":
+		return parseTAI64N, nil
+	}
+
+	layout := format
+	if !strings.ContainsAny(format, "0123456789") {
+		var err error
+		if layout, err = processors.JodaLayout(format); err != nil {
+			return nil, err
+		}
+	}
+	return func(value string, location *time.Location) (time.Time, error) {
+		return time.ParseInLocation(layout, value, location)
+	}, nil
+}
+
+var iso8601Layouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseISO8601 parses the ISO8601 forms of Logstash, fractions of second
+// may follow a "." or a ","
+func parseISO8601(value string, location *time.Location) (time.Time, error) {
+	for _, layout := range iso8601Layouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s is not an ISO8601 date", value)
+}
+
+// parseEpoch parses a number of seconds, or milliseconds when perSecond is
+// 1000, since epoch. Digits of the fraction are kept up to the nanosecond
+func parseEpoch(value string, perSecond int64) (time.Time, error) {
+	integer, fraction := value, ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		integer, fraction = value[:i], value[i+1:]
+	}
+	units, err := strconv.ParseInt(integer, 10, 64)
+	if err == nil && fraction != "" {
+		_, err = strconv.ParseUint(fraction, 10, 64)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not a number", value)
+	}
+
+	unit := int64(time.Second) / perSecond
+	nanos := int64(0)
+	for i, scale := 0, unit/10; i < len(fraction) && scale > 0; i, scale = i+1, scale/10 {
+		nanos += int64(fraction[i]-'0') * scale
+	}
+	if strings.HasPrefix(integer, "-") {
+		nanos = -nanos
+	}
+	seconds := units / perSecond
+	nanos += units % perSecond * unit
+	return time.Unix(seconds, nanos).UTC(), nil
+}
+
+// parseTAI64N parses a TAI64N label : 8 bytes of 2^62 + seconds since epoch,
+// then 4 bytes of nanoseconds, in hexadecimal, with an optional leading "@"
+func parseTAI64N(value string, _ *time.Location) (time.Time, error) {
+	label, err := hex.DecodeString(strings.TrimPrefix(value, "@"))
+	if err != nil || len(label) != 12 {
+		return time.Time{}, fmt.Errorf("%s is not a TAI64N label", value)
+	}
+	var seconds, nanos uint64
+	for _, b := range label[:8] {
+		seconds = seconds<<8 | uint64(b)
+	}
+	for _, b := range label[8:] {
+		nanos = nanos<<8 | uint64(b)
+	}
+	if seconds < 1<<62 || seconds-1<<62 > math.MaxInt64 || nanos >= uint64(time.Second) {
+		return time.Time{}, fmt.Errorf("%s is not a TAI64N label", value)
+	}
+	return time.Unix(int64(seconds-1<<62), int64(nanos)).UTC(), nil
+}
+
+// dateValue returns the value of a date field as a string, numbers are
+// written as UNIX and UNIX_MS formats expect them
+func dateValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	}
+	return "", errors.New("field is not a string or a number")
+}
+
 func (p *processor) Receive(e veino.IPacket) error {
 	dated := false
 	var value string
-	var err error
-	value, err = e.Fields().ValueForPathString(p.match_field_name)
+	field, err := processors.GetField(e.Fields(), p.match_field_name)
 	if err == nil {
-		for _, layout := range p.match_patterns {
-			var t time.Time
-
-			if p.opt.Timezone != "" {
-				location, err := time.LoadLocation(p.opt.Timezone)
-				if err == nil {
-					t, err = time.ParseInLocation(layout, value, location)
-				}
-			} else {
-				t, err = time.Parse(layout, value)
-			}
-
+		value, err = dateValue(field)
+	}
+	location := p.location
+	if err == nil && p.timezone != nil {
+		location, err = loadLocation(p.timezone.Render(e.Fields()))
+	}
+	if err == nil {
+		translated := value
+		if p.translator != nil {
+			translated = p.translator.translate(value)
+		}
+		for _, parse := range p.parsers {
+			t, err := parse(translated, location)
 			if err != nil {
 				continue
 			}
+			// a format without year, as syslog's "Dec 11 00:01:45"
+			if t.Year() == 0 {
+				t = inferYear(t, p.now())
+			}
 
 			dated = true
-			e.Fields().SetValueForPath(t.Format(veino.VeinoTime), p.opt.Target)
+			if p.opt.Original_field != "" {
+				processors.SetField(e.Fields(), p.opt.Original_field, value)
+			}
+			processors.SetField(e.Fields(), p.opt.Target, p.format(t))
 			processors.ProcessCommonFields(e.Fields(), p.opt.Add_field, p.opt.Tags, "")
 			break
 		}
 	}
 
 	if dated == false {
-		processors.AddTags(p.opt.Tag_on_failure, e.Fields())
+		if err == nil {
+			err = fmt.Errorf("no format of %s matched", p.match_field_name)
+		}
+		p.Fail(e, err, value, p.opt.Tag_on_failure...)
+		return nil
 	}
 
-	p.Send(e, 0)
+	p.Send(e, PORT_SUCCESS)
 	return nil
 }
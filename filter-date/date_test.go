@@ -0,0 +1,188 @@
+package date
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+	"github.com/veino/veino"
+)
+
+func TestReceive(t *testing.T) {
+	apache := "02/Jan/2006:15:04:05 -0700"
+	expected, _ := time.Parse(apache, "11/Dec/2013:00:01:45 -0800")
+	utc := expected.UTC()
+
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		fields   map[string]interface{}
+		path     string
+		expected interface{}
+		tags     []string
+		port     int
+	}{
+		{"@timestamp",
+			map[string]interface{}{"match": []string{"logdate", apache}},
+			map[string]interface{}{"logdate": "11/Dec/2013:00:01:45 -0800"},
+			"@timestamp", expected.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"second pattern",
+			map[string]interface{}{"match": []string{"logdate", time.RFC3339, apache}},
+			map[string]interface{}{"logdate": "11/Dec/2013:00:01:45 -0800"},
+			"@timestamp", expected.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"target",
+			map[string]interface{}{"match": []string{"logdate", apache}, "target": "parsed"},
+			map[string]interface{}{"logdate": "11/Dec/2013:00:01:45 -0800"},
+			"parsed", expected.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"failure",
+			map[string]interface{}{"match": []string{"logdate", apache}},
+			map[string]interface{}{"logdate": "not a date"},
+			"logdate", "not a date", []string{"_dateparsefailure"}, PORT_FAILURE},
+		{"joda pattern",
+			map[string]interface{}{"match": []string{"logdate", "dd/MMM/yyyy:HH:mm:ss Z"}},
+			map[string]interface{}{"logdate": "11/Dec/2013:00:01:45 -0800"},
+			"@timestamp", expected.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"timezone",
+			map[string]interface{}{"match": []string{"logdate", "yyyy-MM-dd HH:mm:ss"}, "timezone": "America/Los_Angeles"},
+			map[string]interface{}{"logdate": "2013-12-11 00:01:45"},
+			"@timestamp", expected.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"locale",
+			map[string]interface{}{"match": []string{"logdate", "EEEE dd MMM yyyy HH:mm:ss Z"}, "locale": "fr_FR"},
+			map[string]interface{}{"logdate": "mercredi 11 déc. 2013 00:01:45 -0800"},
+			"@timestamp", expected.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"ISO8601",
+			map[string]interface{}{"match": []string{"logdate", "ISO8601"}},
+			map[string]interface{}{"logdate": "2013-12-11 08:01:45,000"},
+			"@timestamp", utc.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"ISO8601 offset",
+			map[string]interface{}{"match": []string{"logdate", "ISO8601"}, "timezone": "Europe/Paris"},
+			map[string]interface{}{"logdate": "2013-12-11T00:01:45.000-08:00"},
+			"@timestamp", expected.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"UNIX",
+			map[string]interface{}{"match": []string{"logdate", "UNIX"}},
+			map[string]interface{}{"logdate": "1386748905.25"},
+			"@timestamp", utc.Add(250 * time.Millisecond).Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"UNIX number",
+			map[string]interface{}{"match": []string{"logdate", "UNIX"}},
+			map[string]interface{}{"logdate": float64(1386748905)},
+			"@timestamp", utc.Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"UNIX_MS",
+			map[string]interface{}{"match": []string{"logdate", "UNIX_MS"}},
+			map[string]interface{}{"logdate": "1386748905250"},
+			"@timestamp", utc.Add(250 * time.Millisecond).Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"TAI64N",
+			map[string]interface{}{"match": []string{"logdate", "TAI64N"}},
+			map[string]interface{}{"logdate": "@4000000052a81be90ee6b280"},
+			"@timestamp", utc.Add(250 * time.Millisecond).Format(veino.VeinoTime), nil, PORT_SUCCESS},
+		{"missing field",
+			map[string]interface{}{"match": []string{"logdate", apache}},
+			map[string]interface{}{},
+			"_error.processor", "filter-date", []string{"_dateparsefailure"}, PORT_FAILURE},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", test.fields), test.name)
+		if !h.AssertSentCount(t, test.port, 1) {
+			continue
+		}
+		e := h.Sent(test.port)[0]
+		h.AssertField(t, e, test.path, test.expected)
+		h.AssertTags(t, e, test.tags...)
+	}
+}
+
+func TestConfigureErrors(t *testing.T) {
+	tests := []struct {
+		conf map[string]interface{}
+		key  string
+	}{
+		{map[string]interface{}{"match": []string{"logdate"}}, "match"},
+		{map[string]interface{}{"match": []string{"logdate", "ISO8601", "yyyy ZZZ"}}, "match[2]"},
+		{map[string]interface{}{"match": []string{"logdate", "ISO8601"}, "timezone": "Mars/Olympus"}, "timezone"},
+		{map[string]interface{}{"match": []string{"logdate", "ISO8601"}, "locale": "tlh"}, "locale"},
+	}
+
+	for _, test := range tests {
+		err := ptesting.New(New()).Configure(test.conf)
+		if assert.NotNil(t, err, test.key) {
+			assert.Contains(t, err.Error(), " : "+test.key+" : ", test.key)
+		}
+	}
+}
+
+func TestReceiveDynamicTimezone(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{
+		"match":    []string{"logdate", "yyyy-MM-dd HH:mm:ss"},
+		"timezone": "%{tz}",
+	}))
+
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"logdate": "2013-12-11 00:01:45", "tz": "America/Los_Angeles"}))
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"logdate": "2013-12-11 00:01:45", "tz": "Europe/Paris"}))
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"logdate": "2013-12-11 00:01:45", "tz": "Mars/Olympus"}))
+	if h.AssertSentCount(t, PORT_SUCCESS, 2) {
+		h.AssertField(t, h.Sent(PORT_SUCCESS)[0], "@timestamp", "2013-12-11T00:01:45-08:00")
+		h.AssertField(t, h.Sent(PORT_SUCCESS)[1], "@timestamp", "2013-12-11T00:01:45+01:00")
+	}
+	if h.AssertSentCount(t, PORT_FAILURE, 1) {
+		h.AssertTags(t, h.Sent(PORT_FAILURE)[0], "_dateparsefailure")
+	}
+}
+
+func TestReceiveYearInference(t *testing.T) {
+	tests := []struct {
+		now      string
+		value    string
+		expected string
+	}{
+		{"2017-06-15T12:00:00Z", "Jun 14 10:00:00", "2017-06-14T10:00:00Z"},
+		{"2018-01-01T00:00:10Z", "Dec 31 23:59:50", "2017-12-31T23:59:50Z"},
+		{"2017-12-31T23:59:50Z", "Jan  1 00:00:10", "2018-01-01T00:00:10Z"},
+	}
+
+	for _, test := range tests {
+		now, _ := time.Parse(time.RFC3339, test.now)
+		p := New().(*processor)
+		p.now = func() time.Time { return now }
+		h := ptesting.New(p)
+		assert.Nil(t, h.Configure(map[string]interface{}{"match": []string{"logdate", "MMM d HH:mm:ss", "MMM  d HH:mm:ss"}}))
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"logdate": test.value}))
+		if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+			h.AssertField(t, h.Sent(PORT_SUCCESS)[0], "@timestamp", test.expected)
+		}
+	}
+}
+
+func TestReceiveOutputFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected interface{}
+	}{
+		{"yyyy.MM.dd HH:mm:ss.SSS", "2013.12.11 08:01:45.250"},
+		{time.RFC1123, "Wed, 11 Dec 2013 08:01:45 UTC"},
+		{"ISO8601", "2013-12-11T08:01:45.250Z"},
+		{"UNIX", 1386748905.25},
+		{"UNIX_MS", int64(1386748905250)},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		assert.Nil(t, h.Configure(map[string]interface{}{
+			"match":          []string{"logdate", "ISO8601"},
+			"output_format":  test.format,
+			"original_field": "logdate_original",
+			"target":         "logdate",
+		}), test.format)
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"logdate": "2013-12-11T08:01:45.25Z"}), test.format)
+		if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+			e := h.Sent(PORT_SUCCESS)[0]
+			h.AssertField(t, e, "logdate", test.expected)
+			h.AssertField(t, e, "logdate_original", "2013-12-11T08:01:45.25Z")
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package date
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// localeNames holds the month and day names of a language, in the order of
+// time.Month and time.Weekday
+type localeNames struct {
+	months      []string
+	shortMonths []string
+	days        []string
+	shortDays   []string
+}
+
+var locales = map[string]localeNames{
+	"de": {
+		months:      []string{"januar", "februar", "märz", "april", "mai", "juni", "juli", "august", "september", "oktober", "november", "dezember"},
+		shortMonths: []string{"jan", "feb", "mär", "apr", "mai", "jun", "jul", "aug", "sep", "okt", "nov", "dez"},
+		days:        []string{"sonntag", "montag", "dienstag", "mittwoch", "donnerstag", "freitag", "samstag"},
+		shortDays:   []string{"so", "mo", "di", "mi", "do", "fr", "sa"},
+	},
+	"es": {
+		months:      []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: []string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:        []string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortDays:   []string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"fr": {
+		months:      []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: []string{"janv", "févr", "mars", "avr", "mai", "juin", "juil", "août", "sept", "oct", "nov", "déc"},
+		days:        []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortDays:   []string{"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+	},
+	"it": {
+		months:      []string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: []string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:        []string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortDays:   []string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"nl": {
+		months:      []string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: []string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:        []string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortDays:   []string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+	"pt": {
+		months:      []string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: []string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		days:        []string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		shortDays:   []string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	},
+}
+
+// translator replaces the month and day names of a language with the English
+// ones, which the parser knows
+type translator struct {
+	// English name by lower case name, short names match with a final "."
+	names map[string]string
+	short map[string]bool
+	// names of several words, as "segunda-feira"
+	compound []string
+}
+
+// newTranslator returns the translator of locale, as "fr", "fr_FR" or "fr-FR",
+// or nil when there is nothing to translate. ok is false for unknown locales
+func newTranslator(locale string) (tr *translator, ok bool) {
+	language := strings.ToLower(locale)
+	if i := strings.IndexAny(language, "_-"); i >= 0 {
+		language = language[:i]
+	}
+	if language == "en" {
+		return nil, true
+	}
+	l, ok := locales[language]
+	if !ok {
+		return nil, false
+	}
+
+	tr = &translator{names: map[string]string{}, short: map[string]bool{}}
+	// month names come last, they win over day names spelled the same, as
+	// "mar" in Spanish
+	for i, name := range l.shortDays {
+		tr.names[name], tr.short[name] = time.Weekday(i).String()[:3], true
+	}
+	for i, name := range l.days {
+		tr.names[name] = time.Weekday(i).String()
+	}
+	for i, name := range l.shortMonths {
+		tr.names[name], tr.short[name] = time.Month(i + 1).String()[:3], true
+	}
+	for i, name := range l.months {
+		tr.names[name], tr.short[name] = time.Month(i+1).String(), false
+	}
+	for name := range tr.names {
+		if strings.ContainsRune(name, '-') {
+			tr.compound = append(tr.compound, name)
+		}
+	}
+	return tr, true
+}
+
+// translate replaces the names of value's words
+func (tr *translator) translate(value string) string {
+	var buf bytes.Buffer
+	for len(value) > 0 {
+		end := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsLetter(r) })
+		if end < 0 {
+			end = len(value)
+		}
+		for _, name := range tr.compound {
+			if len(value) >= len(name) && strings.EqualFold(value[:len(name)], name) {
+				end = len(name)
+			}
+		}
+		if end == 0 {
+			// not a word, up to the next one
+			end = strings.IndexFunc(value, unicode.IsLetter)
+			if end < 0 {
+				end = len(value)
+			}
+			buf.WriteString(value[:end])
+			value = value[end:]
+			continue
+		}
+
+		word := strings.ToLower(value[:end])
+		english, ok := tr.names[word]
+		if !ok {
+			buf.WriteString(value[:end])
+			value = value[end:]
+			continue
+		}
+		buf.WriteString(english)
+		value = value[end:]
+		// the abbreviation mark, as "janv."
+		if tr.short[word] && strings.HasPrefix(value, ".") {
+			value = value[1:]
+		}
+	}
+	return buf.String()
+}
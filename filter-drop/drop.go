@@ -2,12 +2,24 @@
 package drop
 
 import (
+	"container/list"
+	"hash/fnv"
 	"math/rand"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ChimeraCoder/tokenbucket"
 	"github.com/veino/processors"
+	"github.com/veino/processors/metrics"
 	"github.com/veino/veino"
 )
 
+const (
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
+)
+
 func New() veino.Processor {
 	return &processor{opt: &options{}}
 }
@@ -15,6 +27,17 @@ func New() veino.Processor {
 type processor struct {
 	processors.Base
 
+	dropped *metrics.Counter
+	// the Rate_key template, when it references fields
+	rateKey *processors.Template
+	// the time to refill a token at Max_rate
+	refill time.Duration
+
+	mu sync.Mutex
+	// buckets by key, and from the most to the least recently used
+	buckets map[string]*list.Element
+	lru     *list.List
+
 	opt *options
 }
 
@@ -36,17 +59,77 @@ type options struct {
 
 	// Drop all the events within a pre-configured percentage.
 	// This is useful if you just need a percentage but not the whole.
+	// @default : 100
 	Percentage int
+
+	// Drop Percentage of the events by the hash of this field's value, as
+	// "trace_id", instead of randomly. Events with the same value are all kept
+	// or all dropped, on every node. Events without the field share the same
+	// decision
+	Sample_field string
+
+	// Keep at most this number of events per second and per Rate_key, dropping
+	// the others, instead of dropping Percentage of the events. Disabled when 0
+	Max_rate float64
+
+	// The number of events kept at once after a calm period, before Max_rate
+	// applies. Defaults to Max_rate, and at least 1
+	Burst int
+
+	// The key events are counted by for Max_rate, as "%{host}". Events share
+	// the same rate when it is not set. The 10000 most recently seen keys are
+	// tracked, each refilled in the background, an older key starts again
+	// with Burst events
+	Rate_key string
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
 	p.opt.Percentage = 100
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	if p.opt.Max_rate < 0 {
+		return &processors.OptionError{Processor: "filter-drop", Key: "max_rate", Reason: "must not be negative"}
+	}
+	if p.opt.Max_rate > 0 {
+		p.refill = time.Duration(float64(time.Second) / p.opt.Max_rate)
+		if p.refill <= 0 {
+			return &processors.OptionError{Processor: "filter-drop", Key: "max_rate", Reason: "must be at most 1000000000"}
+		}
+	}
+	if p.opt.Burst <= 0 {
+		p.opt.Burst = int(p.opt.Max_rate)
+		if p.opt.Burst < 1 {
+			p.opt.Burst = 1
+		}
+	}
+	if strings.Contains(p.opt.Rate_key, "%{") {
+		key, err := processors.NewTemplate(p.opt.Rate_key)
+		if err != nil {
+			return &processors.OptionError{Processor: "filter-drop", Key: "rate_key", Reason: err.Error()}
+		}
+		p.rateKey = key
+	}
+	p.stopBuckets()
+	p.buckets = map[string]*list.Element{}
+	p.lru = list.New()
+
+	mode := "percentage"
+	switch {
+	case p.opt.Max_rate > 0:
+		mode = "rate"
+	case p.opt.Sample_field != "":
+		mode = "sample"
+	}
+	p.dropped = p.Metrics.Counter("drop_dropped_total", "Events dropped", "mode", mode)
+	return nil
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-
-	if p.opt.Percentage == 100 || rand.Intn(100) < p.opt.Percentage {
+	if p.drops(e) {
+		p.dropped.Inc()
 		return nil
 	}
 
@@ -56,6 +139,98 @@ func (p *processor) Receive(e veino.IPacket) error {
 		p.opt.Remove_field,
 		p.opt.Remove_Tag,
 	)
-	p.Send(e, 0)
+	p.Send(e, PORT_SUCCESS)
+	return nil
+}
+
+// drops tells whether e is dropped
+func (p *processor) drops(e veino.IPacket) bool {
+	if p.opt.Max_rate > 0 {
+		key := p.opt.Rate_key
+		if p.rateKey != nil {
+			key = p.rateKey.Render(e.Fields())
+		}
+		return !p.take(key)
+	}
+
+	if p.opt.Percentage >= 100 {
+		return true
+	}
+	if p.opt.Percentage <= 0 {
+		return false
+	}
+	if p.opt.Sample_field != "" {
+		return sample(processors.GetFieldOrEmptyString(e.Fields(), p.opt.Sample_field)) < p.opt.Percentage
+	}
+	return rand.Intn(100) < p.opt.Percentage
+}
+
+// sample returns the bucket of value, from 0 to 99
+func sample(value string) int {
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	return int(h.Sum32() % 100)
+}
+
+// bucket holds the tokens of a key, the vendored tokenbucket refills it at
+// Max_rate up to Burst tokens
+type bucket struct {
+	key string
+	*tokenbucket.Bucket
+}
+
+func (p *processor) newBucket(key string) *bucket {
+	b := &bucket{key: key, Bucket: tokenbucket.NewBucket(p.refill, int64(p.opt.Burst))}
+	b.AddToken(int64(p.opt.Burst))
+	return b
+}
+
+// TryTake takes a token, it returns false when the bucket is empty rather
+// than waiting for one as SpendToken
+func (b *bucket) TryTake() bool {
+	return b.TrySpendToken()
+}
+
+// maxBuckets is the number of keys kept, above it the least recently used
+// bucket is stopped and forgotten : its key starts again with a full bucket
+const maxBuckets = 10000
+
+// take takes a token of the bucket of key, it returns false when the bucket
+// is empty
+func (p *processor) take(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.buckets[key]
+	if ok {
+		p.lru.MoveToFront(element)
+	} else {
+		if p.lru.Len() >= maxBuckets {
+			oldest := p.lru.Remove(p.lru.Back()).(*bucket)
+			oldest.Stop()
+			delete(p.buckets, oldest.key)
+		}
+		element = p.lru.PushFront(p.newBucket(key))
+		p.buckets[key] = element
+	}
+	return element.Value.(*bucket).TryTake()
+}
+
+// stopBuckets stops refilling the buckets
+func (p *processor) stopBuckets() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lru == nil {
+		return
+	}
+	for element := p.lru.Front(); element != nil; element = element.Next() {
+		element.Value.(*bucket).Stop()
+	}
+	p.buckets = map[string]*list.Element{}
+	p.lru.Init()
+}
+
+func (p *processor) Stop(e veino.IPacket) error {
+	p.stopBuckets()
 	return nil
 }
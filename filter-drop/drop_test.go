@@ -0,0 +1,129 @@
+package drop
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+)
+
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+		sent int
+	}{
+		{"drop everything", map[string]interface{}{}, 0},
+		{"drop nothing", map[string]interface{}{"percentage": 0}, 10},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, h.Receive("test", nil), test.name)
+		}
+		h.AssertSentCount(t, 0, test.sent)
+	}
+}
+
+func TestReceiveCommonFields(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{
+		"percentage": 0,
+		"add_tag":    []string{"kept"},
+		"add_field":  map[string]interface{}{"kept_by": "drop"},
+	}))
+
+	h.Receive("test", nil)
+	if h.AssertSentCount(t, 0, 1) {
+		h.AssertTags(t, h.Sent(0)[0], "kept")
+		h.AssertField(t, h.Sent(0)[0], "kept_by", "drop")
+	}
+}
+
+func TestReceiveSampleField(t *testing.T) {
+	kept := map[string]int{}
+	for node := 0; node < 2; node++ {
+		h := ptesting.New(New())
+		assert.Nil(t, h.Configure(map[string]interface{}{"percentage": 50, "sample_field": "trace_id"}))
+		for i := 0; i < 100; i++ {
+			for _, trace := range []string{"a", "b", "c", "d", "e", "f"} {
+				assert.Nil(t, h.Receive("test", map[string]interface{}{"trace_id": trace}))
+			}
+		}
+		for _, e := range h.Sent(PORT_SUCCESS) {
+			kept[e.Fields().ValueOrEmptyForPathString("trace_id")]++
+		}
+	}
+
+	// a trace is kept on every node, or dropped on every node
+	for trace, count := range kept {
+		assert.Equal(t, 200, count, trace)
+	}
+	for _, trace := range []string{"a", "b", "c", "d", "e", "f"} {
+		assert.Equal(t, sample(trace) >= 50, kept[trace] == 200, trace)
+	}
+}
+
+func TestReceiveMaxRate(t *testing.T) {
+	p := New().(*processor)
+	h := ptesting.New(p)
+	assert.Nil(t, h.Configure(map[string]interface{}{"max_rate": 2, "rate_key": "%{host}"}))
+	defer h.Stop()
+
+	receive := func(host string, count int) {
+		for i := 0; i < count; i++ {
+			assert.Nil(t, h.Receive("test", map[string]interface{}{"host": host}))
+		}
+	}
+
+	// a burst of 2 per host
+	receive("a", 5)
+	receive("b", 5)
+	h.AssertSentCount(t, PORT_SUCCESS, 4)
+
+	// 2 events per second
+	time.Sleep(700 * time.Millisecond)
+	receive("a", 5)
+	h.AssertSentCount(t, PORT_SUCCESS, 5)
+
+	assert.Equal(t, float64(10), p.dropped.Value())
+}
+
+func TestReceiveMaxRateBuckets(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	p := New().(*processor)
+	h := ptesting.New(p)
+	assert.Nil(t, h.Configure(map[string]interface{}{"max_rate": 1, "rate_key": "%{host}"}))
+
+	// every bucket starts full, with a token
+	for i := 0; i <= maxBuckets; i++ {
+		assert.True(t, p.take(strconv.Itoa(i)))
+	}
+	assert.Len(t, p.buckets, maxBuckets)
+	assert.Equal(t, maxBuckets, p.lru.Len())
+
+	assert.False(t, p.take(strconv.Itoa(maxBuckets)), "recent buckets are kept")
+	assert.True(t, p.take("0"), "the least recently used bucket was forgotten")
+	assert.Len(t, p.buckets, maxBuckets)
+	assert.False(t, p.take("2"), "a used bucket is recent")
+	assert.True(t, p.take("1"), "the least recently used bucket was forgotten")
+
+	assert.Nil(t, h.Stop())
+	assert.Len(t, p.buckets, 0)
+	for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= goroutines, "stopped and forgotten buckets are not refilled")
+}
+
+func TestConfigureMaxRate(t *testing.T) {
+	h := ptesting.New(New())
+	assert.EqualError(t, h.Configure(map[string]interface{}{"max_rate": 2e9}), "filter-drop : max_rate : must be at most 1000000000")
+}
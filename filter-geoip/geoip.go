@@ -2,15 +2,23 @@ package geoip
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"strings"
 
 	"github.com/hraban/lrucache"
 	"github.com/oschwald/geoip2-golang"
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/veino/processors"
+	"github.com/veino/processors/metrics"
 	"github.com/veino/veino"
 )
 
+const (
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
+)
+
 // New returns the processor struct
 func New() veino.Processor {
 	return &processor{opt: &options{}}
@@ -21,7 +29,18 @@ type processor struct {
 
 	opt       *options
 	cache     *lrucache.Cache
-	databases map[string]*geoip2.Reader
+	databases []database
+	// fields set in events, Fields or every field of the databases
+	fields []string
+
+	lookups *metrics.Counter
+	misses  *metrics.Counter
+}
+
+// database is an opened MaxMind database of a kind, as "city"
+type database struct {
+	kind   string
+	reader *maxminddb.Reader
 }
 
 type options struct {
@@ -34,12 +53,20 @@ type options struct {
 	AddTag []string `mapstructure:"add_tag"`
 
 	// Path or url to the GeoIP database (can be gziped).
-	// Default value is "http://geolite.maxmind.com/download/geoip/database/GeoLite2-City.mmdb.gz".
+	// Default value is "http://geolite.maxmind.com/download/geoip/database/GeoLite2-City.mmdb.gz",
+	// it is not used when Databases is set.
 	Database string `mapstructure:"database"`
 
+	// A hash of database type ⇒ path, to look IP addresses up in several databases
+	// at once, as { "city" => "GeoLite2-City.mmdb", "asn" => "GeoLite2-ASN.mmdb" }.
+	// A field provided by several databases is read from the first one of
+	// enterprise, city, country, isp, asn, domain, connectiontype and anonymousip
+	Databases map[string]string `mapstructure:"databases"`
+
 	// GeoIP database type. Default value is "city".
-	// Accepted value can be one of "city", "isp", "country" "domain" or "anonymousip"
-	Type string `mapstructure:"type"`
+	// Accepted value can be one of "city", "country", "asn", "isp", "domain",
+	// "connectiontype", "anonymousip" or "enterprise"
+	Type string `mapstructure:"type" enum:"city,country,asn,isp,domain,connectiontype,anonymousip,enterprise"`
 
 	// GeoIP database update interval (in minutes). Default value is 0 (no updates).
 	// If `database` field contains an url, the database will be retrieved from this url at specified interval.
@@ -50,6 +77,15 @@ type options struct {
 
 	// An array of geoip fields to be included in the event.
 	// Possible fields depend on the database type. By default, all geoip fields are included in the event.
+	// city : city_name, country_code, country_name, continent_code, continent_name, latitude, longitude,
+	// metro_code, timezone, postal_code, region_code, region_name, is_anonymous_proxy, is_satellite_provider
+	// country : country_code, country_name, continent_code, continent_name, is_anonymous_proxy, is_satellite_provider
+	// asn : asn, organization
+	// isp : asn, organization, isp
+	// domain : domain
+	// connectiontype : connection_type
+	// anonymousip : is_anonymous, is_anonymous_vpn, is_hosting_provider, is_public_proxy, is_tor_exit_node
+	// enterprise : the city fields, asn, organization, isp, domain, connection_type and user_type
 	Fields []string `mapstructure:"fields"`
 
 	// Cache size
@@ -75,31 +111,8 @@ type options struct {
 	Language string `mapstructure:"language"`
 }
 
-type geoipRecords struct {
-	city *geoip2.City
-	isp  *geoip2.ISP
-}
-
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
 	defaults := options{
-		Fields: []string{
-			"city_name",
-			"country_code",
-			"country_name",
-			"continent_code",
-			"continent_name",
-			"latitude",
-			"longitude",
-			"timezone",
-			"postal_code",
-			"region_code",
-			"region_name",
-			"is_anonymous_proxy",
-			"is_satellite_provider",
-			"asn",
-			"organization",
-			"isp",
-		},
 		Language:       "en",
 		CacheSize:      1000,
 		Target:         "geoip",
@@ -109,130 +122,125 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 	}
 	p.opt = &defaults
 
-	err := p.ConfigureAndValidate(ctx, conf, p.opt)
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
 
 	if p.opt.LruCacheSize > 0 {
 		p.opt.CacheSize = p.opt.LruCacheSize
 	}
 
+	p.lookups = p.Metrics.Counter("geoip_cache_lookups_total", "IP addresses looked up in the geoip cache")
+	p.misses = p.Metrics.Counter("geoip_cache_misses_total", "IP addresses not found in the geoip cache, and read from databases")
+
 	p.cache = lrucache.New(p.opt.CacheSize)
-	p.databases = map[string]*geoip2.Reader{}
+	p.cache.OnMiss(p.getInfo())
 
-	if err == nil {
+	databases := map[string]string{}
+	for kind, path := range p.opt.Databases {
+		kind = strings.ToLower(kind)
+		if _, ok := kindFields[kind]; !ok {
+			return &processors.OptionError{Processor: "filter-geoip", Key: "databases", Reason: "unknown database type " + kind}
+		}
+		databases[kind] = path
+	}
+	if _, ok := conf["database"]; ok || len(databases) == 0 {
+		databases[p.opt.Type] = p.opt.Database
+	}
+
+	if err := p.load(databases); err != nil {
+		p.Logger.Error("can not open database", "error", err)
+		return err
+	}
+
+	return p.selectFields()
+}
+
+// kinds are the database types, in the order fields are read from them
+var kinds = []string{"enterprise", "city", "country", "isp", "asn", "domain", "connectiontype", "anonymousip"}
+
+var cityFields = []string{
+	"city_name", "country_code", "country_name", "continent_code", "continent_name", "latitude", "longitude",
+	"metro_code", "timezone", "postal_code", "region_code", "region_name", "is_anonymous_proxy", "is_satellite_provider",
+}
 
-		if p.opt.Database != "" {
-			p.opt.Databases["city"] = p.opt.Database
+// kindFields are the fields of each database type
+var kindFields = map[string][]string{
+	"city":           cityFields,
+	"country":        {"country_code", "country_name", "continent_code", "continent_name", "is_anonymous_proxy", "is_satellite_provider"},
+	"asn":            {"asn", "organization"},
+	"isp":            {"asn", "organization", "isp"},
+	"domain":         {"domain"},
+	"connectiontype": {"connection_type"},
+	"anonymousip":    {"is_anonymous", "is_anonymous_vpn", "is_hosting_provider", "is_public_proxy", "is_tor_exit_node"},
+	"enterprise":     append(append([]string{}, cityFields...), "asn", "organization", "isp", "domain", "connection_type", "user_type"),
+}
+
+// selectFields checks Fields are provided by the databases, or selects all
+// the fields of the databases
+func (p *processor) selectFields() error {
+	provided := map[string]bool{}
+	p.fields = []string{}
+	for _, db := range p.databases {
+		for _, field := range kindFields[db.kind] {
+			if !provided[field] {
+				provided[field] = true
+				p.fields = append(p.fields, field)
+			}
 		}
+	}
 
-		err = p.load(p.opt.Databases)
-		if err != nil {
-			p.Logger.Println(err)
+	if len(p.opt.Fields) == 0 {
+		return nil
+	}
+	for i, field := range p.opt.Fields {
+		if !provided[field] {
+			return &processors.OptionError{
+				Processor: "filter-geoip",
+				Key:       fmt.Sprintf("fields[%d]", i),
+				Reason:    fmt.Sprintf("%s is not provided by the %s database(s)", field, p.kinds()),
+			}
 		}
 	}
+	p.fields = p.opt.Fields
+	return nil
+}
 
-	return err
+func (p *processor) kinds() string {
+	names := make([]string, len(p.databases))
+	for i, db := range p.databases {
+		names[i] = db.kind
+	}
+	return strings.Join(names, ", ")
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-	ip, err := e.Fields().ValueForPathString(p.opt.Source)
-
+	ip, err := processors.GetFieldString(e.Fields(), p.opt.Source)
 	if err != nil {
-		return err
+		p.Fail(e, err, nil)
+		return nil
 	}
 
-	p.cache.OnMiss(p.getInfo())
-
-	records, err := p.cache.Get(ip)
+	p.lookups.Inc()
+	record, err := p.cache.Get(ip)
 	if err != nil {
-		return err
+		p.Fail(e, err, ip, "_geoip_lookup_failure")
+		return nil
 	}
 
-	geoip := records.(geoipRecords)
-
+	geoip := record.(map[string]interface{})
 	data := make(map[string]interface{})
-	lang := p.opt.Language
-
-	for _, field := range p.opt.Fields {
-		switch field {
-		// City database
-		case "city_name":
-			if geoip.city != nil {
-				data["city_name"] = geoip.city.City.Names[lang]
-			}
-		case "country_code":
-			if geoip.city != nil {
-				data["country_code"] = geoip.city.Country.IsoCode
-			}
-		case "country_name":
-			if geoip.city != nil {
-				data["country_name"] = geoip.city.Country.Names[lang]
-			}
-		case "continent_code":
-			if geoip.city != nil {
-				data["continent_code"] = geoip.city.Continent.Code
-			}
-		case "continent_name":
-			if geoip.city != nil {
-				data["continent_name"] = geoip.city.Continent.Names[lang]
-			}
-		case "latitude":
-			if geoip.city != nil {
-				data["latitude"] = geoip.city.Location.Latitude
-			}
-		case "longitude":
-			if geoip.city != nil {
-				data["longitude"] = geoip.city.Location.Longitude
-			}
-		case "metro_code":
-			if geoip.city != nil {
-				data["metro_code"] = geoip.city.Location.MetroCode
-			}
-		case "timezone":
-			if geoip.city != nil {
-				data["timezone"] = geoip.city.Location.TimeZone
-			}
-		case "postal_code":
-			if geoip.city != nil {
-				data["postal_code"] = geoip.city.Postal.Code
-			}
-		case "region_code":
-			if geoip.city != nil {
-				data["region_code"] = geoip.city.Subdivisions[0].IsoCode
-			}
-		case "region_name":
-			if geoip.city != nil {
-				data["region_name"] = geoip.city.Subdivisions[0].Names[lang]
-			}
-		case "is_anonymous_proxy":
-			if geoip.city != nil {
-				data["is_anonymous_proxy"] = geoip.city.Traits.IsAnonymousProxy
-			}
-		case "is_satellite_provider":
-			if geoip.city != nil {
-				data["is_satellite_provider"] = geoip.city.Traits.IsSatelliteProvider
-			}
-
-		// ISP database
-		case "asn":
-			if geoip.isp != nil {
-				data["asn"] = geoip.isp.AutonomousSystemNumber
-			}
-		case "organization":
-			if geoip.isp != nil {
-				data["organization"] = geoip.isp.AutonomousSystemOrganization
-			}
-		case "isp":
-			if geoip.isp != nil {
-				data["isp"] = geoip.isp.ISP
-			}
+	for _, field := range p.fields {
+		if value, ok := geoip[field]; ok {
+			data[field] = value
 		}
 	}
 
 	if p.opt.Target != "" {
-		e.Fields().SetValueForPath(data, p.opt.Target)
+		processors.SetField(e.Fields(), p.opt.Target, data)
 	} else {
 		for k, v := range data {
-			e.Fields().SetValueForPath(v, k)
+			processors.FieldReference{k}.Set(e.Fields(), v)
 		}
 	}
 
@@ -243,52 +251,202 @@ func (p *processor) Receive(e veino.IPacket) error {
 		p.opt.RemoveTag,
 	)
 
-	p.Send(e, 0)
+	p.Send(e, PORT_SUCCESS)
 	return nil
 }
 
-func (p *processor) load(databases map[string]string) (err error) {
-	if databases == nil {
+// load opens the databases, in the order of kinds
+func (p *processor) load(databases map[string]string) error {
+	if len(databases) == 0 {
 		return errors.New("no valid GeoIP database found")
 	}
-	for name, path := range databases {
-		p.databases[name], err = geoip2.Open(path)
+	p.databases = []database{}
+	for _, kind := range kinds {
+		path, ok := databases[kind]
+		if !ok {
+			continue
+		}
+		reader, err := maxminddb.Open(path)
 		if err != nil {
+			p.close()
 			return err
 		}
+		p.databases = append(p.databases, database{kind: kind, reader: reader})
 	}
 	return nil
 }
 
+func (p *processor) close() {
+	for _, db := range p.databases {
+		db.reader.Close()
+	}
+	p.databases = nil
+}
+
+// getInfo looks an IP address up in every database, the first database
+// providing a field sets it
 func (p *processor) getInfo() func(ip string) (lrucache.Cacheable, error) {
 	return func(ip string) (lrucache.Cacheable, error) {
-		records := geoipRecords{}
+		p.misses.Inc()
 		netIP := net.ParseIP(ip)
 		if netIP == nil {
 			return nil, errors.New("no valid IP address found")
 		}
 
-		for name, db := range p.databases {
-			switch strings.ToLower(name) {
-			case "isp":
-				if record, err := db.ISP(netIP); err == nil {
-					records.isp = record
+		record := map[string]interface{}{}
+		found := false
+		for _, db := range p.databases {
+			offset, err := db.reader.LookupOffset(netIP)
+			if err != nil || offset == maxminddb.NotFound {
+				continue
+			}
+			fields, err := decode(db, offset, p.opt.Language)
+			if err != nil {
+				return nil, err
+			}
+			found = true
+			for field, value := range fields {
+				if _, ok := record[field]; !ok {
+					record[field] = value
 				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s not found in the %s database(s)", ip, p.kinds())
+		}
+		return record, nil
+	}
+}
 
-			//case "country":
-			//case "domain":
-			//case "anonymousip":
+// enterpriseTraits are the traits of enterprise records which are not in City
+type enterpriseTraits struct {
+	Traits struct {
+		AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+		AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+		ConnectionType               string `maxminddb:"connection_type"`
+		Domain                       string `maxminddb:"domain"`
+		ISP                          string `maxminddb:"isp"`
+		UserType                     string `maxminddb:"user_type"`
+	} `maxminddb:"traits"`
+}
 
-			default:
-				if record, err := db.City(netIP); err == nil {
-					records.city = record
-				}
+// asn is a record of ASN databases
+type asn struct {
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// decode reads the record at offset of db, as fields. Values missing from the
+// record are not set, flags are always set
+func decode(db database, offset uintptr, lang string) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	switch db.kind {
+	case "city", "enterprise":
+		var city geoip2.City
+		if err := db.reader.Decode(offset, &city); err != nil {
+			return nil, err
+		}
+		set(fields, "city_name", city.City.Names[lang])
+		set(fields, "country_code", city.Country.IsoCode)
+		set(fields, "country_name", city.Country.Names[lang])
+		set(fields, "continent_code", city.Continent.Code)
+		set(fields, "continent_name", city.Continent.Names[lang])
+		if city.Location.Latitude != 0 || city.Location.Longitude != 0 {
+			fields["latitude"] = city.Location.Latitude
+			fields["longitude"] = city.Location.Longitude
+		}
+		set(fields, "metro_code", city.Location.MetroCode)
+		set(fields, "timezone", city.Location.TimeZone)
+		set(fields, "postal_code", city.Postal.Code)
+		if len(city.Subdivisions) > 0 {
+			set(fields, "region_code", city.Subdivisions[0].IsoCode)
+			set(fields, "region_name", city.Subdivisions[0].Names[lang])
+		}
+		fields["is_anonymous_proxy"] = city.Traits.IsAnonymousProxy
+		fields["is_satellite_provider"] = city.Traits.IsSatelliteProvider
+
+		if db.kind == "enterprise" {
+			var enterprise enterpriseTraits
+			if err := db.reader.Decode(offset, &enterprise); err != nil {
+				return nil, err
 			}
+			set(fields, "asn", enterprise.Traits.AutonomousSystemNumber)
+			set(fields, "organization", enterprise.Traits.AutonomousSystemOrganization)
+			set(fields, "isp", enterprise.Traits.ISP)
+			set(fields, "domain", enterprise.Traits.Domain)
+			set(fields, "connection_type", enterprise.Traits.ConnectionType)
+			set(fields, "user_type", enterprise.Traits.UserType)
+		}
+	case "country":
+		var country geoip2.Country
+		if err := db.reader.Decode(offset, &country); err != nil {
+			return nil, err
+		}
+		set(fields, "country_code", country.Country.IsoCode)
+		set(fields, "country_name", country.Country.Names[lang])
+		set(fields, "continent_code", country.Continent.Code)
+		set(fields, "continent_name", country.Continent.Names[lang])
+		fields["is_anonymous_proxy"] = country.Traits.IsAnonymousProxy
+		fields["is_satellite_provider"] = country.Traits.IsSatelliteProvider
+	case "asn":
+		var record asn
+		if err := db.reader.Decode(offset, &record); err != nil {
+			return nil, err
 		}
-		return records, nil
+		set(fields, "asn", record.AutonomousSystemNumber)
+		set(fields, "organization", record.AutonomousSystemOrganization)
+	case "isp":
+		var isp geoip2.ISP
+		if err := db.reader.Decode(offset, &isp); err != nil {
+			return nil, err
+		}
+		set(fields, "asn", isp.AutonomousSystemNumber)
+		set(fields, "organization", isp.AutonomousSystemOrganization)
+		set(fields, "isp", isp.ISP)
+	case "domain":
+		var domain geoip2.Domain
+		if err := db.reader.Decode(offset, &domain); err != nil {
+			return nil, err
+		}
+		set(fields, "domain", domain.Domain)
+	case "connectiontype":
+		var connection geoip2.ConnectionType
+		if err := db.reader.Decode(offset, &connection); err != nil {
+			return nil, err
+		}
+		set(fields, "connection_type", connection.ConnectionType)
+	case "anonymousip":
+		var anonymous geoip2.AnonymousIP
+		if err := db.reader.Decode(offset, &anonymous); err != nil {
+			return nil, err
+		}
+		fields["is_anonymous"] = anonymous.IsAnonymous
+		fields["is_anonymous_vpn"] = anonymous.IsAnonymousVPN
+		fields["is_hosting_provider"] = anonymous.IsHostingProvider
+		fields["is_public_proxy"] = anonymous.IsPublicProxy
+		fields["is_tor_exit_node"] = anonymous.IsTorExitNode
 	}
+	return fields, nil
+}
+
+// set sets fields[name] to value, unless it is empty or 0
+func set(fields map[string]interface{}, name string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+	case uint:
+		if v == 0 {
+			return
+		}
+	}
+	fields[name] = value
 }
 
 func (p *processor) Tick(e veino.IPacket) error  { return nil }
 func (p *processor) Start(e veino.IPacket) error { return nil }
-func (p *processor) Stop(e veino.IPacket) error  { return nil }
+func (p *processor) Stop(e veino.IPacket) error {
+	p.close()
+	return nil
+}
@@ -0,0 +1,219 @@
+package geoip
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+)
+
+// writeDatabase writes a MaxMind IPv4 database of type dbType, where addresses
+// of 0.0.0.0/1 have record and others are not found
+func writeDatabase(t *testing.T, dir string, dbType string, record map[string]interface{}) string {
+	var db bytes.Buffer
+	// one node : the left record points to the data, the right one is empty
+	db.Write([]byte{0, 0, 1 + 16, 0, 0, 1})
+	db.Write(make([]byte, 16))
+	writeValue(&db, record)
+	db.WriteString("\xAB\xCD\xEFMaxMind.com")
+	writeValue(&db, map[string]interface{}{
+		"binary_format_major_version": uint(2),
+		"binary_format_minor_version": uint(0),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": "test"},
+		"ip_version":                  uint(4),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint(1),
+		"record_size":                 uint(24),
+	})
+
+	path := filepath.Join(dir, dbType+".mmdb")
+	if err := ioutil.WriteFile(path, db.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeValue writes value in the MaxMind DB data format
+func writeValue(w *bytes.Buffer, value interface{}) {
+	control := func(kind byte, size int) {
+		// sizes from 29 to 284 take an extra byte
+		extra := []byte{}
+		if size >= 29 {
+			extra, size = []byte{byte(size - 29)}, 29
+		}
+		if kind < 8 {
+			w.WriteByte(kind<<5 | byte(size))
+		} else {
+			w.WriteByte(byte(size))
+			w.WriteByte(kind - 7)
+		}
+		w.Write(extra)
+	}
+
+	switch v := value.(type) {
+	case string:
+		control(2, len(v))
+		w.WriteString(v)
+	case float64:
+		control(3, 8)
+		bits := math.Float64bits(v)
+		for i := 7; i >= 0; i-- {
+			w.WriteByte(byte(bits >> (8 * uint(i))))
+		}
+	case uint:
+		digits := []byte{}
+		for n := v; n > 0; n >>= 8 {
+			digits = append([]byte{byte(n)}, digits...)
+		}
+		control(6, len(digits))
+		w.Write(digits)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		control(14, size)
+	case []interface{}:
+		control(11, len(v))
+		for _, item := range v {
+			writeValue(w, item)
+		}
+	case map[string]interface{}:
+		control(7, len(v))
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeValue(w, key)
+			writeValue(w, v[key])
+		}
+	}
+}
+
+func testDatabases(t *testing.T) map[string]interface{} {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return map[string]interface{}{
+		"city": writeDatabase(t, dir, "GeoLite2-City", map[string]interface{}{
+			"city":     map[string]interface{}{"names": map[string]interface{}{"en": "Paris", "fr": "Paris"}},
+			"country":  map[string]interface{}{"iso_code": "FR", "names": map[string]interface{}{"en": "France"}},
+			"location": map[string]interface{}{"latitude": 48.85, "longitude": 2.35, "time_zone": "Europe/Paris"},
+		}),
+		"asn": writeDatabase(t, dir, "GeoLite2-ASN", map[string]interface{}{
+			"autonomous_system_number":       uint(3215),
+			"autonomous_system_organization": "Orange",
+		}),
+		"anonymousip": writeDatabase(t, dir, "GeoIP2-Anonymous-IP", map[string]interface{}{
+			"is_anonymous":     true,
+			"is_public_proxy":  true,
+			"is_tor_exit_node": false,
+		}),
+		"connectiontype": writeDatabase(t, dir, "GeoIP2-Connection-Type", map[string]interface{}{
+			"connection_type": "Cable/DSL",
+		}),
+		"domain": writeDatabase(t, dir, "GeoIP2-Domain", map[string]interface{}{
+			"domain": "orange.fr",
+		}),
+		"enterprise": writeDatabase(t, dir, "GeoIP2-Enterprise", map[string]interface{}{
+			"city":   map[string]interface{}{"names": map[string]interface{}{"en": "Lyon"}},
+			"traits": map[string]interface{}{"user_type": "residential", "isp": "Orange"},
+		}),
+	}
+}
+
+func TestReceive(t *testing.T) {
+	databases := testDatabases(t)
+
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{"city and asn",
+			map[string]interface{}{"databases": map[string]interface{}{"city": databases["city"], "asn": databases["asn"]}},
+			map[string]interface{}{"city_name": "Paris", "country_code": "FR", "country_name": "France",
+				"latitude": 48.85, "longitude": 2.35, "timezone": "Europe/Paris",
+				"is_anonymous_proxy": false, "is_satellite_provider": false,
+				"asn": uint(3215), "organization": "Orange"}},
+		{"fields",
+			map[string]interface{}{"databases": map[string]interface{}{"city": databases["city"], "asn": databases["asn"]},
+				"fields": []string{"country_code", "asn"}},
+			map[string]interface{}{"country_code": "FR", "asn": uint(3215)}},
+		{"database and type",
+			map[string]interface{}{"database": databases["domain"], "type": "domain"},
+			map[string]interface{}{"domain": "orange.fr"}},
+		{"anonymousip and connectiontype",
+			map[string]interface{}{"databases": map[string]interface{}{"anonymousip": databases["anonymousip"], "connectiontype": databases["connectiontype"]}},
+			map[string]interface{}{"is_anonymous": true, "is_anonymous_vpn": false, "is_hosting_provider": false,
+				"is_public_proxy": true, "is_tor_exit_node": false, "connection_type": "Cable/DSL"}},
+		{"enterprise first",
+			map[string]interface{}{"databases": map[string]interface{}{"city": databases["city"], "enterprise": databases["enterprise"]},
+				"fields": []string{"city_name", "isp", "user_type"}},
+			map[string]interface{}{"city_name": "Lyon", "isp": "Orange", "user_type": "residential"}},
+		{"missing values of the first database",
+			map[string]interface{}{"databases": map[string]interface{}{"enterprise": databases["enterprise"], "city": databases["city"], "asn": databases["asn"]},
+				"fields": []string{"city_name", "country_code", "latitude", "asn", "postal_code"}},
+			map[string]interface{}{"city_name": "Lyon", "country_code": "FR", "latitude": 48.85, "asn": uint(3215)}},
+	}
+
+	for _, test := range tests {
+		test.conf["source"] = "ip"
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"ip": "1.2.3.4"}), test.name)
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"ip": "1.2.3.4"}), test.name)
+		if h.AssertSentCount(t, PORT_SUCCESS, 2) {
+			assert.Equal(t, test.expected, (*h.Sent(PORT_SUCCESS)[1].Fields())["geoip"], test.name)
+		}
+		h.Stop()
+	}
+}
+
+func TestReceiveNotFound(t *testing.T) {
+	databases := testDatabases(t)
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"source": "ip", "database": databases["city"]}))
+
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"ip": "200.1.2.3"}))
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"ip": "not an ip"}))
+	if h.AssertSentCount(t, PORT_FAILURE, 2) {
+		h.AssertTags(t, h.Sent(PORT_FAILURE)[0], "_geoip_lookup_failure")
+		h.AssertField(t, h.Sent(PORT_FAILURE)[0], "_error.value", "200.1.2.3")
+	}
+	h.Stop()
+}
+
+func TestConfigureErrors(t *testing.T) {
+	databases := testDatabases(t)
+	tests := []struct {
+		conf map[string]interface{}
+		key  string
+	}{
+		{map[string]interface{}{"databases": map[string]interface{}{"town": databases["city"]}}, "databases"},
+		{map[string]interface{}{"databases": map[string]interface{}{"asn": databases["asn"]}, "fields": []string{"asn", "city_name"}}, "fields[1]"},
+		{map[string]interface{}{"database": databases["city"], "type": "town"}, "type"},
+	}
+
+	for _, test := range tests {
+		test.conf["source"] = "ip"
+		err := ptesting.New(New()).Configure(test.conf)
+		if assert.NotNil(t, err, test.key) {
+			assert.Contains(t, err.Error(), " : "+test.key+" : ", test.key)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package grok
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vjeantet/grok"
+)
+
+// Debugger proposes grok expressions for sample lines, and tells why a line
+// does not match a pattern, with the patterns known by the grok filter.
+//
+//	d, _ := grok.NewDebugger(nil, nil)
+//	expression, _ := d.Discover([]string{"2017-03-01T10:00:00Z INFO started in 12ms"})
+//	result, _ := d.Debug("%{TIMESTAMP_ISO8601:ts} %{LOGLEVEL:level}", line)
+type Debugger struct {
+	grok *grok.Grok
+}
+
+// NewDebugger returns a Debugger knowing the default patterns, the patterns
+// of patternsDir and definitions, as the Patterns_dir and Pattern_definitions
+// options of the filter
+func NewDebugger(patternsDir []string, definitions map[string]string) (*Debugger, error) {
+	g, err := grok.NewWithConfig(&grok.Config{NamedCapturesOnly: true, PatternsDir: patternsDir})
+	if err != nil {
+		return nil, err
+	}
+	if err := g.AddPatternsFromMap(definitions); err != nil {
+		return nil, err
+	}
+	return &Debugger{grok: g}, nil
+}
+
+// discoverPatterns are tried on each part of a line, the longest match wins,
+// then the first pattern matching the whole part names it
+var discoverPatterns = []string{
+	"TIMESTAMP_ISO8601",
+	"HTTPDATE",
+	"SYSLOGTIMESTAMP",
+	"DATESTAMP",
+	"UUID",
+	"MAC",
+	"IP",
+	"EMAILADDRESS",
+	"URI",
+	"UNIXPATH",
+	"LOGLEVEL",
+	"QUOTEDSTRING",
+	"NUMBER",
+	"WORD",
+	"HOSTNAME",
+}
+
+// part is a piece of a line, matched by a pattern or a literal
+type part struct {
+	pattern string
+	text    string
+}
+
+// Discover returns a grok expression matching every line. Lines with the same
+// structure are generalized, as "%{NUMBER}" and "%{WORD}" for "12" and "ab"
+func (d *Debugger) Discover(lines []string) (string, error) {
+	if len(lines) == 0 {
+		return "", errors.New("no sample line")
+	}
+
+	partsOfLines := make([][]part, len(lines))
+	for i, line := range lines {
+		partsOfLines[i] = d.discoverParts(line)
+	}
+
+	parts, ok := d.generalize(partsOfLines)
+	if !ok {
+		// lines do not share a structure, keep the expression matching most lines
+		best, bestCount := "", -1
+		for _, p := range partsOfLines {
+			expression := expressionOf(p)
+			count := 0
+			for _, line := range lines {
+				if d.matches(expression, line) {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = expression, count
+			}
+		}
+		if bestCount < len(lines) {
+			return best, fmt.Errorf("%s matches %d of %d lines", best, bestCount, len(lines))
+		}
+		return best, nil
+	}
+	return expressionOf(parts), nil
+}
+
+// discoverParts splits line into parts matched by discoverPatterns and literals
+func (d *Debugger) discoverParts(line string) []part {
+	parts := []part{}
+	for i := 0; i < len(line); {
+		best, bestLength := "", 0
+		for _, name := range discoverPatterns {
+			length := d.prefixLength(reference(name), line[i:])
+			if length <= 0 {
+				continue
+			}
+			// spaces after the match are literals
+			length = len(strings.TrimRightFunc(line[i:i+length], unicode.IsSpace))
+			if length > bestLength && atBoundary(line, i+length) {
+				best, bestLength = name, length
+			}
+		}
+		if best == "" {
+			// a literal character, or a word no pattern knows
+			r, size := utf8.DecodeRuneInString(line[i:])
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				size = d.prefixLength(reference("NOTSPACE"), line[i:])
+				best = "NOTSPACE"
+			}
+			if best == "" && len(parts) > 0 && parts[len(parts)-1].pattern == "" {
+				parts[len(parts)-1].text += line[i : i+size]
+			} else {
+				parts = append(parts, part{pattern: best, text: line[i : i+size]})
+			}
+			i += size
+			continue
+		}
+		// the first pattern matching the whole text, as IP rather than
+		// HOSTNAME for "10.0.0.1"
+		text := line[i : i+bestLength]
+		for _, name := range discoverPatterns {
+			if d.prefixLength(reference(name)+"$", text) >= 0 {
+				best = name
+				break
+			}
+		}
+		parts = append(parts, part{pattern: best, text: text})
+		i += bestLength
+	}
+	return parts
+}
+
+// generalize merges the parts of lines sharing the same literals, using for
+// each part the first pattern matching the texts of all lines
+func (d *Debugger) generalize(partsOfLines [][]part) ([]part, bool) {
+	first := partsOfLines[0]
+	for _, parts := range partsOfLines[1:] {
+		if len(parts) != len(first) {
+			return nil, false
+		}
+		for i := range parts {
+			if (parts[i].pattern == "") != (first[i].pattern == "") || parts[i].pattern == "" && parts[i].text != first[i].text {
+				return nil, false
+			}
+		}
+	}
+
+	merged := make([]part, len(first))
+	copy(merged, first)
+	for i := range merged {
+		if merged[i].pattern == "" {
+			continue
+		}
+		candidates := append(append([]string{}, discoverPatterns...), "NOTSPACE", "DATA")
+	candidate:
+		for _, name := range candidates {
+			for _, parts := range partsOfLines {
+				if d.prefixLength(reference(name)+"$", parts[i].text) < 0 {
+					continue candidate
+				}
+			}
+			merged[i].pattern = name
+			break
+		}
+	}
+	return merged, true
+}
+
+// reference returns a reference to the pattern name. It is named, as
+// vjeantet/grok does not expand unnamed references to names shorter than 3
+// letters, as %{IP}
+func reference(name string) string {
+	return "%{" + name + ":grokdiscover}"
+}
+
+// expressionOf returns the grok expression of parts, captures are named
+// after their pattern
+func expressionOf(parts []part) string {
+	var expression string
+	names := map[string]int{}
+	for _, p := range parts {
+		if p.pattern == "" {
+			expression += regexp.QuoteMeta(p.text)
+			continue
+		}
+		name := strings.ToLower(p.pattern)
+		names[name]++
+		if names[name] > 1 {
+			name = fmt.Sprintf("%s%d", name, names[name])
+		}
+		expression += "%{" + p.pattern + ":" + name + "}"
+	}
+	return expression
+}
+
+// atBoundary tells whether a match ending at i of line ends a token
+func atBoundary(line string, i int) bool {
+	if i >= len(line) {
+		return true
+	}
+	before, _ := utf8.DecodeLastRuneInString(line[:i])
+	after, _ := utf8.DecodeRuneInString(line[i:])
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' }
+	return !isWord(before) || !isWord(after)
+}
+
+// prefixLength returns the length of the prefix of text matched by pattern,
+// or -1 when text does not start with a match. Patterns which do not compile
+// never match
+func (d *Debugger) prefixLength(pattern string, text string) int {
+	captures, err := d.grok.ParseToMultiMap(`^(?P<grokdebugprefix>`+pattern+`)`, text)
+	if err != nil {
+		return -1
+	}
+	prefix, ok := captures["grokdebugprefix"]
+	if !ok {
+		return -1
+	}
+	return len(prefix[0])
+}
+
+// matches tells whether text starts with a match of pattern
+func (d *Debugger) matches(pattern string, text string) bool {
+	return d.prefixLength(pattern, text) >= 0
+}
+
+// DebugResult tells how a line matches a pattern
+type DebugResult struct {
+	// Matched is true when the whole pattern matches the start of the line
+	Matched bool
+	// Captures are the values captured by the pattern, or by the longest
+	// matching start of the pattern
+	Captures map[string]string
+	// Matching is the longest start of the pattern matching the line
+	Matching string
+	// Failing is the first part of the pattern which does not match, after
+	// Matching
+	Failing string
+	// Offset is the position in the line where Failing is tried
+	Offset int
+}
+
+var grokReference = regexp.MustCompile(`%{\w+(?::[^:}]+(?::\w+)?)?}`)
+
+// Debug matches line with pattern, when it does not match it finds the
+// first part of the pattern, a %{PATTERN} or the text between two, which
+// stops matching. The pattern is anchored at the start of the line, for
+// the match as for the diagnosis : start it with %{DATA} to find it
+// further in the line.
+func (d *Debugger) Debug(pattern string, line string) (*DebugResult, error) {
+	if _, err := d.grok.Parse(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	result := &DebugResult{Captures: map[string]string{}}
+	if d.matches(pattern, line) {
+		captures, _ := d.grok.Parse(pattern, line)
+		result.Matched, result.Captures, result.Matching = true, captures, pattern
+		return result, nil
+	}
+
+	// parts of the pattern : references and the literals between them
+	parts := []string{}
+	last := 0
+	for _, loc := range grokReference.FindAllStringIndex(pattern, -1) {
+		if loc[0] > last {
+			parts = append(parts, pattern[last:loc[0]])
+		}
+		parts = append(parts, pattern[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	if last < len(pattern) {
+		parts = append(parts, pattern[last:])
+	}
+	// an anchor is not a part which can fail
+	if len(parts) > 0 && parts[0] == "^" {
+		result.Matching, parts = "^", parts[1:]
+	}
+
+	prefix := result.Matching
+	for _, part := range parts {
+		length := d.prefixLength(prefix+part, line)
+		if length < 0 {
+			result.Failing = part
+			break
+		}
+		prefix += part
+		result.Matching, result.Offset = prefix, length
+	}
+	if result.Matching != "" && result.Matching != "^" {
+		captures, _ := d.grok.Parse("^"+strings.TrimPrefix(result.Matching, "^"), line)
+		result.Captures = captures
+	}
+	return result, nil
+}
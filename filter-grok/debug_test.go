@@ -0,0 +1,65 @@
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	d, err := NewDebugger(nil, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expression, err := d.Discover([]string{
+		"2017-03-01T10:00:00Z INFO 192.168.0.1 GET /index.html took 12ms",
+		"2017-03-01T10:00:05Z WARN 10.0.0.12 POST /login took 153ms",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `%{TIMESTAMP_ISO8601:timestamp_iso8601} %{LOGLEVEL:loglevel} %{IP:ip} %{WORD:word} %{UNIXPATH:unixpath} %{WORD:word2} %{WORD:word3}`, expression)
+
+	for _, line := range []string{"2017-03-02T11:00:00Z ERROR 127.0.0.1 PUT /a took 1ms"} {
+		ok, _ := d.grok.Match(expression, line)
+		assert.True(t, ok, "the expression matches lines of the same structure")
+	}
+
+	_, err = d.Discover(nil)
+	assert.NotNil(t, err)
+}
+
+func TestDebug(t *testing.T) {
+	d, err := NewDebugger(nil, map[string]string{"DURATION": `%{NUMBER}ms`})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	result, err := d.Debug(`%{IP:client} %{WORD:verb} %{NUMBER:status} %{DURATION:took}`, "10.0.0.1 GET OK 12ms")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, result.Matched)
+	assert.Equal(t, `%{IP:client} %{WORD:verb} `, result.Matching)
+	assert.Equal(t, `%{NUMBER:status}`, result.Failing)
+	assert.Equal(t, 13, result.Offset, "status is tried at OK")
+	assert.Equal(t, map[string]string{"client": "10.0.0.1", "verb": "GET"}, result.Captures)
+
+	result, err = d.Debug(`%{IP:client} %{WORD:verb} %{WORD:status} %{DURATION:took}`, "10.0.0.1 GET OK 12ms")
+	assert.Nil(t, err)
+	assert.True(t, result.Matched)
+	assert.Equal(t, "12ms", result.Captures["took"])
+
+	result, err = d.Debug(`%{WORD:verb} %{NUMBER:status}`, "[front] GET 200")
+	assert.Nil(t, err)
+	assert.False(t, result.Matched, "the pattern is anchored at the start of the line")
+	assert.Equal(t, `%{WORD:verb}`, result.Failing)
+	assert.Equal(t, 0, result.Offset)
+
+	result, err = d.Debug(`%{DATA} %{WORD:verb} %{NUMBER:status}`, "[front] GET 200")
+	assert.Nil(t, err)
+	assert.True(t, result.Matched)
+	assert.Equal(t, "200", result.Captures["status"])
+
+	_, err = d.Debug(`%{UNKNOWN}`, "line")
+	assert.NotNil(t, err)
+}
@@ -1,16 +1,22 @@
 package grok
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
 
-	"github.com/mitchellh/mapstructure"
 	"github.com/veino/processors"
+	"github.com/veino/processors/metrics"
 	"github.com/veino/veino"
 	"github.com/vjeantet/grok"
 )
 
 const (
-	PORT_SUCCESS = 0
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
 )
 
 func New() veino.Processor {
@@ -20,7 +26,17 @@ func New() veino.Processor {
 type processor struct {
 	processors.Base
 
-	grok *grok.Grok
+	grok     *grok.Grok
+	matchers []matcher
+	// field references of bracketed capture names, as "[http][bytes]", by
+	// the capture name given to grok
+	fields   map[string]string
+	matches  *metrics.Counter
+	failures *metrics.Counter
+	timeouts *metrics.Counter
+	// running matches, some may outlive a timed out event
+	wg      sync.WaitGroup
+	running chan struct{}
 
 	// If this filter is successful, add any arbitrary fields to this event. Field names can
 	// be dynamic and include parts of the event using the %{field}.
@@ -39,9 +55,23 @@ type processor struct {
 	// If true, keep empty captures as event fields
 	Keep_empty_captures bool
 
-	// A hash of matches of field ⇒ value
-	// TODO : keep order
-	Match map[string]string
+	// A hash of field ⇒ pattern, or field ⇒ [patterns], to match fields against.
+	// Captures are strings, unless typed as %{NUMBER:bytes:int} or %{NUMBER:ratio:float},
+	// in these patterns or in the ones they use. A capture may name a nested
+	// field, as %{NUMBER:[http][bytes]:int}.
+	// Patterns of a field are tried in the written order, fields of a hash in
+	// alphabetical order, use an array of hashes to set the order of fields :
+	// [ { "message" => ["%{SYSLOGLINE}", "%{GREEDYDATA:message}"] }, { "program" => "%{WORD:daemon}" } ]
+	// The matched field and pattern are stored in [@metadata][grok]
+	Match []map[string][]string
+
+	// The fields to overwrite with captures, as "message". A capture into any
+	// other existing field is appended to it, turning the field into an array
+	Overwrite []string
+
+	// A hash of pattern name ⇒ pattern, defined for this filter only. They
+	// take precedence over patterns of Patterns_dir
+	Pattern_definitions map[string]string
 
 	// If true, only store named captures from grok.
 	// @default : true
@@ -63,6 +93,12 @@ type processor struct {
 	// Append values to the tags field when there has been no successful match
 	// @default : ["_grokparsefailure"]
 	Tag_on_failure []string
+
+	// Abort matching an event after this time, in milliseconds. The event is
+	// tagged with "_groktimeout" and sent to the failure port. There is no
+	// timeout when 0. A timed out match goes on in the background, while 8
+	// are running the next events wait for one to end, within this time
+	Timeout_millis int
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
@@ -75,6 +111,11 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 		return err
 	}
 
+	p.matches = p.Metrics.Counter("grok_matches_total", "Events matched by a grok pattern")
+	p.failures = p.Metrics.Counter("grok_failures_total", "Events matched by no grok pattern")
+	p.timeouts = p.Metrics.Counter("grok_timeouts_total", "Events whose matching timed out")
+	p.running = make(chan struct{}, maxMatches)
+
 	p.grok, err = grok.NewWithConfig(&grok.Config{
 		NamedCapturesOnly: p.Named_captures_only,
 		RemoveEmptyValues: !p.Keep_empty_captures,
@@ -90,49 +131,279 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 		}
 	}
 
+	p.fields = map[string]string{}
+	definitions := make(map[string]string, len(p.Pattern_definitions))
+	for name, definition := range p.Pattern_definitions {
+		definitions[name] = p.aliasFields(definition)
+	}
+	if err := p.grok.AddPatternsFromMap(definitions); err != nil {
+		return &processors.OptionError{Processor: "filter-grok", Key: "pattern_definitions", Reason: err.Error()}
+	}
+
+	// compile patterns now, a bad pattern fails at startup
+	p.matchers = []matcher{}
+	for i, match := range p.Match {
+		fields := make([]string, 0, len(match))
+		for field := range match {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			m := matcher{field: field, patterns: match[field]}
+			for j, pattern := range match[field] {
+				expression := p.aliasFields(pattern)
+				if _, err := p.grok.Parse(expression, ""); err != nil {
+					return &processors.OptionError{
+						Processor: "filter-grok",
+						Key:       fmt.Sprintf("match[%d].%s[%d]", i, field, j),
+						Reason:    err.Error(),
+					}
+				}
+				m.expressions = append(m.expressions, expression)
+			}
+			p.matchers = append(p.matchers, m)
+		}
+	}
+
 	return nil
 }
 
+// matcher holds the patterns tried on a field, in order, and their
+// expressions given to grok
+type matcher struct {
+	field       string
+	patterns    []string
+	expressions []string
+}
+
+var bracketedCapture = regexp.MustCompile(`%{(\w+):((?:\[[^\]}]+\])+)(:\w+)?}`)
+
+// aliasFields replaces the bracketed capture names of pattern, which grok
+// does not take, with names setCaptures turns back into field references
+func (p *processor) aliasFields(pattern string) string {
+	return bracketedCapture.ReplaceAllStringFunc(pattern, func(capture string) string {
+		m := bracketedCapture.FindStringSubmatch(capture)
+		alias := ""
+		for name, field := range p.fields {
+			if field == m[2] {
+				alias = name
+			}
+		}
+		if alias == "" {
+			alias = fmt.Sprintf("grokfield%d", len(p.fields))
+			p.fields[alias] = m[2]
+		}
+		return "%{" + m[1] + ":" + alias + m[3] + "}"
+	})
+}
+
+// maxMatches bounds the matches running at once when Timeout_millis is set,
+// timed out ones included. Go regular expressions run in linear time, a timed
+// out match ends by itself, but holds a CPU until then
+const maxMatches = 8
+
+// errTimeout stops matching an event which takes longer than Timeout_millis,
+// waiting for a running match to end included
+var errTimeout = errors.New("grok timeout")
+
+// match is a successful match of a field
+type match struct {
+	field   string
+	pattern string
+	values  map[string]interface{}
+}
+
 func (p *processor) Receive(e veino.IPacket) error {
-	groked := false
-	for fkey, pattern := range p.Match {
-		values, _ := p.grok.Parse(pattern, e.Fields().ValueOrEmptyForPathString(fkey))
-		if len(values) > 0 {
-			groked = true
-			// if f, err := mxj.Ma(values); err == nil {
-			if err := mapstructure.Decode(values, e.Fields()); err != nil {
-				return fmt.Errorf("Error while groking : %s", err.Error())
+	values, existing := p.fieldValues(e)
+	// values change while matching, which may outlive the event
+	var tried interface{}
+	if len(values) == 1 {
+		for _, v := range values {
+			tried = v
+		}
+	} else {
+		all := make(map[string]interface{}, len(values))
+		for field, v := range values {
+			all[field] = v
+		}
+		tried = all
+	}
+
+	matches, err := p.matchAll(values, existing)
+	switch {
+	case err == errTimeout:
+		p.timeouts.Inc()
+		p.Fail(e, fmt.Errorf("grok timeout after %dms", p.Timeout_millis), tried, "_groktimeout")
+		return nil
+	case err != nil:
+		// a capture typed neither int nor float
+		p.failures.Inc()
+		p.Fail(e, err, tried, p.Tag_on_failure...)
+		return nil
+	case len(matches) == 0:
+		p.failures.Inc()
+		p.Fail(e, errors.New("no pattern matched"), tried, p.Tag_on_failure...)
+		return nil
+	}
+
+	matched := map[string]interface{}{}
+	for _, m := range matches {
+		p.setCaptures(e, m.values)
+		if _, ok := matched[m.field]; !ok {
+			matched[m.field] = m.pattern
+		}
+	}
+
+	p.matches.Inc()
+	processors.SetMetadata(e.Fields(), "grok", matched)
+	processors.AddFields(p.Add_field, e.Fields())
+	processors.RemoveFields(p.Remove_field, e.Fields())
+	if len(p.Add_tag) > 0 {
+		processors.AddTags(p.Add_tag, e.Fields())
+	}
+	processors.RemoveTags(p.Remove_tag, e.Fields())
+
+	p.Send(e, PORT_SUCCESS)
+	return nil
+}
+
+// fieldValues returns the values of the matched fields, and whether they
+// exist in the event
+func (p *processor) fieldValues(e veino.IPacket) (map[string]string, map[string]bool) {
+	values := map[string]string{}
+	existing := map[string]bool{}
+	for _, m := range p.matchers {
+		values[m.field] = processors.GetFieldOrEmptyString(e.Fields(), m.field)
+		_, err := processors.GetField(e.Fields(), m.field)
+		existing[m.field] = err == nil
+	}
+	return values, existing
+}
+
+// matchAll runs match, in the background when Timeout_millis is set : it
+// waits for one of the maxMatches running matches to end, and gives up with
+// errTimeout once Timeout_millis passed
+func (p *processor) matchAll(values map[string]string, existing map[string]bool) ([]match, error) {
+	if p.Timeout_millis <= 0 {
+		return p.match(values, existing)
+	}
+
+	timer := time.NewTimer(time.Duration(p.Timeout_millis) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case p.running <- struct{}{}:
+	case <-timer.C:
+		return nil, errTimeout
+	}
+
+	type result struct {
+		matches []match
+		err     error
+	}
+	done := make(chan result, 1)
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.running
+			p.wg.Done()
+		}()
+		matches, err := p.match(values, existing)
+		done <- result{matches, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.matches, r.err
+	case <-timer.C:
+		return nil, errTimeout
+	}
+}
+
+// match tries the patterns of the matchers on values, the event's ones. A
+// capture into a matched field changes its value for the next matchers, as
+// setCaptures will do in the event
+func (p *processor) match(values map[string]string, existing map[string]bool) ([]match, error) {
+	matches := []match{}
+	for _, m := range p.matchers {
+		for i, pattern := range m.patterns {
+			captures, err := p.grok.ParseTyped(m.expressions[i], values[m.field])
+			if err != nil {
+				return nil, err
 			}
+			if len(captures) == 0 {
+				continue
+			}
+
+			matches = append(matches, match{field: m.field, pattern: pattern, values: captures})
 			if p.Break_on_match == true {
-				break
+				return matches, nil
+			}
+			for capture, value := range captures {
+				field := p.fieldOf(capture)
+				if _, ok := values[field]; !ok {
+					continue
+				}
+				// an existing field becomes an array
+				s, _ := value.(string)
+				if existing[field] && !p.overwrites(field) {
+					s = ""
+				}
+				values[field], existing[field] = s, true
 			}
 		}
 	}
+	return matches, nil
+}
 
-	if groked {
-		processors.AddFields(p.Add_field, e.Fields())
-		processors.RemoveFields(p.Remove_field, e.Fields())
-		if len(p.Add_tag) > 0 {
-			processors.AddTags(p.Add_tag, e.Fields())
-		}
-		processors.RemoveTags(p.Remove_tag, e.Fields())
+// fieldOf returns the event field of a capture
+func (p *processor) fieldOf(capture string) string {
+	if field, ok := p.fields[capture]; ok {
+		return field
+	}
+	return capture
+}
 
+// setCaptures sets the captured values in the event, overwriting the
+// Overwrite fields and appending to other existing fields
+func (p *processor) setCaptures(e veino.IPacket, values map[string]interface{}) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	if !groked {
-		tags, err := e.Fields().ValueForPath("tags")
-		if err != nil {
-			tags = []string{}
+	for _, capture := range names {
+		value := values[capture]
+		name := p.fieldOf(capture)
+
+		existing, err := processors.GetField(e.Fields(), name)
+		if err != nil || p.overwrites(name) {
+			processors.SetField(e.Fields(), name, value)
+			continue
+		}
+		switch v := existing.(type) {
+		case []interface{}:
+			processors.SetField(e.Fields(), name, append(v, value))
+		default:
+			processors.SetField(e.Fields(), name, []interface{}{v, value})
 		}
-		newtags := append(tags.([]string), p.Tag_on_failure...)
-		e.Fields().SetValueForPath(newtags, "tags")
 	}
+}
 
-	p.Send(e, PORT_SUCCESS)
-	return nil
+func (p *processor) overwrites(name string) bool {
+	for _, field := range p.Overwrite {
+		if field == name {
+			return true
+		}
+	}
+	return false
 }
 
-func (p *processor) Stop(e veino.IPacket) error { return nil }
+func (p *processor) Stop(e veino.IPacket) error {
+	p.wg.Wait()
+	return nil
+}
 
 func (p *processor) Tick(e veino.IPacket) error { return nil }
 
@@ -1,15 +1,45 @@
 package grok
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/veino/runtime/testutils"
+	"github.com/veino/processors"
+	ptesting "github.com/veino/processors/testing"
 	"github.com/veino/veino"
 )
 
+const syslogLine = "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]"
+
+// newTestProcessor returns a configured processor and its test harness
+func newTestProcessor(t *testing.T, conf map[string]interface{}) (*processor, *ptesting.Harness) {
+	p := New().(*processor)
+	h := ptesting.New(p)
+	assert.Nil(t, h.Configure(conf), "configuration is correct, error should be nil")
+	return p, h
+}
+
+// receive hands a new event to the processor and returns the event it sent,
+// on the failure port when grok failed
+func receive(t *testing.T, h *ptesting.Harness, message string, fields map[string]interface{}) veino.IPacket {
+	h.Reset()
+	h.Receive(message, fields)
+	if failed := h.Sent(PORT_FAILURE); len(failed) > 0 {
+		h.AssertSentCount(t, PORT_FAILURE, 1)
+		return failed[0]
+	}
+	h.AssertSentCount(t, PORT_SUCCESS, 1)
+	return h.Sent(PORT_SUCCESS)[0]
+}
+
 func TestNew(t *testing.T) {
-	p := New(nil)
+	p := New()
 	_, ok := p.(*processor)
 	assert.Equal(t, ok, true, "New() should return a mutate.processos struct")
 }
@@ -26,113 +56,244 @@ func getExampleConfiguration() map[string]interface{} {
 			"timestamp": "%{MONTHDAY:jour}/%{MONTH:mois}/%{YEAR:annee}",
 		},
 		"named_captures_only": true,
-		"unknow":              "Unknow value",
 	}
 }
 
 func TestConfigureError(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
+	h := ptesting.New(New())
 
 	conf := map[string]interface{}{
 		"match": 54,
 	}
-	ret := p.Configure(conf)
+	ret := h.Configure(conf)
 	assert.NotEqual(t, ret, nil, "configuration is not correct, it should return an error")
 	assert.Implements(t, new(error), ret)
 }
 
 func TestConfigure(t *testing.T) {
-	p := New(nil).(*processor)
+	p := New().(*processor)
 	conf := getExampleConfiguration()
 
-	ret := p.Configure(conf)
+	ret := ptesting.New(p).Configure(conf)
 	assert.Equal(t, ret, nil, "configuration is correct, error should be nil")
 
 	assert.Equal(t, len(p.Add_field), 2, "Add_field options should have 2 elements")
 	assert.Equal(t, len(p.Remove_field), 4, "Remove_field options should have 4 elements")
-	assert.Equal(t, len(p.Match), 2, "Match options should have 2 elements")
+	assert.Equal(t, len(p.matchers), 2, "Match options should have 2 fields")
 	assert.Equal(t, p.Named_captures_only, true, "Named_captures_only should be true")
 }
 
 func TestReceive(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`}
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match":     map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+		"overwrite": []string{"message"},
+	})
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
+	em := receive(t, h, syslogLine, nil)
 
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-
-	em.AssertExpectations(t)
-	// pp.Print(em.Fields())
 	assert.Equal(t, "evita", em.Fields().ValueOrEmptyForPathString("logsource"), "field value not proprely groked")
 	assert.Equal(t, "connect from camomile.cloud9.net[168.100.1.3]", em.Fields().ValueOrEmptyForPathString("message"), "field value not proprely groked")
 }
 
 func TestReceiveFailure(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `^%{NUMBER:n}$`},
+	})
+
+	h.Receive("hello world", map[string]interface{}{
+		"field1": "VALUE",
+	})
+	h.AssertSentCount(t, PORT_SUCCESS, 0)
+	if !h.AssertSentCount(t, PORT_FAILURE, 1) {
+		return
+	}
+	em := h.Sent(PORT_FAILURE)[0]
+	assert.Equal(t, "VALUE", em.Fields().ValueOrEmptyForPathString("field1"), "field value should stay")
+	h.AssertTags(t, em, "_grokparsefailure")
+	h.AssertField(t, em, "_error.processor", "filter-grok")
+	h.AssertField(t, em, "_error.reason", "no pattern matched")
+	h.AssertField(t, em, "_error.value", "hello world")
+}
 
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{UNKNOW}`}
+func TestConfigureUnknownPattern(t *testing.T) {
+	h := ptesting.New(New())
+	err := h.Configure(map[string]interface{}{
+		"match": map[string]interface{}{"message": []string{`%{NUMBER:n}`, `%{UNKNOW}`}},
+	})
+	if assert.NotNil(t, err, "patterns are compiled at configuration") {
+		assert.Equal(t, "filter-grok : match[0].message[1] : no pattern found for %{UNKNOW}", err.Error())
+	}
+}
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "hello world", nil)
-	em.Fields().SetValueForPath("VALUE", "field1")
+func TestReceiveFallbackPatterns(t *testing.T) {
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{
+			"message": []string{`^%{NUMBER:code}$`, `%{SYSLOGBASE} %{GREEDYDATA:message}`, `%{GREEDYDATA:rest}`},
+		},
+	})
+
+	em := receive(t, h, syslogLine, nil)
+	assert.Equal(t, "evita", em.Fields().ValueOrEmptyForPathString("logsource"))
+	assert.False(t, em.Fields().Exists("rest"), "patterns after the match are not tried")
+	pattern, err := processors.GetMetadata(em.Fields(), "[grok][message]")
+	assert.Nil(t, err)
+	assert.Equal(t, `%{SYSLOGBASE} %{GREEDYDATA:message}`, pattern)
+}
 
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
-	// pp.Print(em.Fields())
-	assert.Equal(t, "VALUE", em.Fields().ValueOrEmptyForPathString("field1"), "field value should stay")
+func TestReceiveAppendsToExistingFields(t *testing.T) {
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+	})
 
-	tags, _ := em.Fields().ValueForPath("tags")
-	assert.Contains(t, tags.([]string), "_grokparsefailure", "failure tag should be set")
+	em := receive(t, h, syslogLine, nil)
+	assert.Equal(t, []interface{}{syslogLine, "connect from camomile.cloud9.net[168.100.1.3]"}, (*em.Fields())["message"])
+}
+
+func TestReceiveTypedCaptures(t *testing.T) {
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"pattern_definitions": map[string]interface{}{
+			"DURATION": `%{NUMBER:duration:float}ms`,
+			"REQUEST":  `%{WORD:verb} %{NOTSPACE:path} %{NUMBER:bytes:int} %{DURATION}`,
+		},
+		"match": map[string]interface{}{"message": `%{REQUEST}`},
+	})
 
+	em := receive(t, h, "GET /index.html 2048 12.5ms", nil)
+	h.AssertField(t, em, "verb", "GET")
+	h.AssertField(t, em, "bytes", 2048)
+	h.AssertField(t, em, "duration", 12.5)
 }
 
-func TestRemoveTagNoTags(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
+func TestReceiveTypedCapturesOfPatternsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grok")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "http"), []byte("HTTPSTATUS %{INT:status:int}\n"), 0644)
+	if !assert.Nil(t, err) {
+		return
+	}
 
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`}
-	p.Remove_tag = []string{"field1"}
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"patterns_dir": []string{dir},
+		"match":        map[string]interface{}{"message": `%{HTTPSTATUS} %{NUMBER:[http][bytes]:int} %{NUMBER:[http][took]}`},
+	})
+
+	em := receive(t, h, "404 2048 12.5", nil)
+	h.AssertField(t, em, "status", 404)
+	h.AssertField(t, em, "http.bytes", 2048)
+	h.AssertField(t, em, "http.took", "12.5")
+}
+
+func TestReceiveUnknownCaptureType(t *testing.T) {
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{NUMBER:bytes:long}`},
+	})
+
+	em := receive(t, h, "2048", nil)
+	h.AssertTags(t, em, "_grokparsefailure")
+	h.AssertField(t, em, "_error.reason", "ERROR the value 2048 cannot be converted to long")
+}
+
+func TestReceiveTimeout(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match":          map[string]interface{}{"message": `%{COMBINEDAPACHELOG}`},
+		"timeout_millis": 20,
+	})
+	timeouts := p.timeouts.Value()
+
+	line := strings.Repeat("a ", 200000)
+	h.Receive(line, nil)
+	h.AssertSentCount(t, PORT_SUCCESS, 0)
+	if h.AssertSentCount(t, PORT_FAILURE, 1) {
+		em := h.Sent(PORT_FAILURE)[0]
+		h.AssertTags(t, em, "_groktimeout")
+		h.AssertField(t, em, "_error.reason", "grok timeout after 20ms")
+	}
+	assert.Equal(t, timeouts+1, p.timeouts.Value())
+
+	em := receive(t, h, `127.0.0.1 - - [11/Dec/2013:00:01:45 -0800] "GET /index.html HTTP/1.1" 200 3891 "-" "curl"`, nil)
+	h.AssertField(t, em, "clientip", "127.0.0.1")
+
+	assert.Nil(t, h.Stop())
+	assert.Equal(t, goroutines, runtime.NumGoroutine(), "abandoned matches end by Stop")
+}
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-	// em.Fields().SetValueForPath([]string{"myTag", "field1", "myTag2"}, "notags")
+func TestReceiveTimeoutBoundsMatches(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": []string{
+			`^%{NUMBER:n}$`, `%{COMBINEDAPACHELOG}`, `%{SYSLOGBASE}`,
+		}},
+		"timeout_millis": 1,
+	})
+
+	line := strings.Repeat("a ", 100000)
+	for i := 0; i < 2*maxMatches; i++ {
+		h.Receive(line, nil)
+		assert.True(t, runtime.NumGoroutine() <= goroutines+maxMatches, "timed out matches are bounded")
+	}
+	h.AssertSentCount(t, PORT_SUCCESS, 0)
+	if h.AssertSentCount(t, PORT_FAILURE, 2*maxMatches) {
+		for _, em := range h.Sent(PORT_FAILURE) {
+			h.AssertTags(t, em, "_groktimeout")
+		}
+		h.AssertField(t, h.Sent(PORT_FAILURE)[2*maxMatches-1], "_error.reason", "grok timeout after 1ms")
+	}
+
+	assert.Nil(t, h.Stop())
+	assert.Equal(t, goroutines, runtime.NumGoroutine(), "abandoned matches end by Stop")
+}
+
+func TestReceiveTimeoutWaitsForRunningMatches(t *testing.T) {
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match":          map[string]interface{}{"message": `^%{NUMBER:n}$`},
+		"timeout_millis": 1000,
+	})
+
+	for i := 0; i < maxMatches; i++ {
+		p.running <- struct{}{}
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-p.running
+	}()
+	em := receive(t, h, "42", nil)
+	h.AssertField(t, em, "n", "42")
+
+	p.running <- struct{}{}
+	p.Timeout_millis = 1
+	h.Reset()
+	h.Receive("42", nil)
+	if h.AssertSentCount(t, PORT_FAILURE, 1) {
+		h.AssertTags(t, h.Sent(PORT_FAILURE)[0], "_groktimeout")
+		h.AssertField(t, h.Sent(PORT_FAILURE)[0], "_error.reason", "grok timeout after 1ms")
+	}
+}
 
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+func TestRemoveTagNoTags(t *testing.T) {
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+	})
+	p.Remove_tag = []string{"field1"}
+
+	em := receive(t, h, syslogLine, nil)
 
 	_, err := em.Fields().ValueForPath("tags")
 	assert.NotNil(t, err, "...")
 }
 
 func TestRemoveTag(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`}
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+	})
 	p.Remove_tag = []string{"field1"}
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-	em.Fields().SetValueForPath([]string{"myTag", "field1", "myTag2"}, "tags")
-	// em.Fields().SetValueForPath("newvalue", "upfield3")
-	// em.Fields().SetValueForPath("myValue", "rnfieldA")
-
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, syslogLine, map[string]interface{}{
+		"tags": []string{"myTag", "field1", "myTag2"},
+	})
 
 	assert.Equal(t, "evita", em.Fields().ValueOrEmptyForPathString("logsource"), "field value not proprely groked")
 
@@ -144,19 +305,12 @@ func TestRemoveTag(t *testing.T) {
 }
 
 func TestAddTagToNoTags(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`}
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+	})
 	p.Add_tag = []string{"tag1", "tag2"}
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, syslogLine, nil)
 
 	tags, err := em.Fields().ValueForPath("tags")
 	assert.Nil(t, err, "...")
@@ -164,22 +318,14 @@ func TestAddTagToNoTags(t *testing.T) {
 }
 
 func TestAddTag(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`}
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+	})
 	p.Add_tag = []string{"tiptop", "tiptop2"}
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-	em.Fields().SetValueForPath([]string{"myTag", "field1", "myTag2"}, "tags")
-	// em.Fields().SetValueForPath("newvalue", "upfield3")
-	// em.Fields().SetValueForPath("myValue", "rnfieldA")
-
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, syslogLine, map[string]interface{}{
+		"tags": []string{"myTag", "field1", "myTag2"},
+	})
 
 	assert.Equal(t, "evita", em.Fields().ValueOrEmptyForPathString("logsource"), "field value not proprely groked")
 
@@ -190,23 +336,15 @@ func TestAddTag(t *testing.T) {
 }
 
 func TestRemoveField(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`}
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+	})
 	p.Remove_field = []string{"field1"}
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-	em.Fields().SetValueForPath("valueA", "field1")
-	em.Fields().SetValueForPath("valueB", "field2")
-	// em.Fields().SetValueForPath("newvalue", "upfield3")
-	// em.Fields().SetValueForPath("myValue", "rnfieldA")
-
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, syslogLine, map[string]interface{}{
+		"field1": "valueA",
+		"field2": "valueB",
+	})
 
 	assert.Equal(t, "evita", em.Fields().ValueOrEmptyForPathString("logsource"), "field value not proprely groked")
 
@@ -215,22 +353,14 @@ func TestRemoveField(t *testing.T) {
 	assert.Equal(t, "valueB", em.Fields().ValueOrEmptyForPathString("field2"), "field2's should remain unchanged")
 }
 func TestAddField(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`}
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+	})
 	p.Add_field = map[string]interface{}{"field1": `Hello World`}
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-	em.Fields().SetValueForPath("valueB", "field2")
-	// em.Fields().SetValueForPath("newvalue", "upfield3")
-	// em.Fields().SetValueForPath("myValue", "rnfieldA")
-
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, syslogLine, map[string]interface{}{
+		"field2": "valueB",
+	})
 
 	assert.Equal(t, "evita", em.Fields().ValueOrEmptyForPathString("logsource"), "field value not proprely groked")
 
@@ -240,12 +370,11 @@ func TestAddField(t *testing.T) {
 }
 
 func TestPatterns_dirError(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
+	h := ptesting.New(New())
 
 	conf := getExampleConfiguration()
 	conf["patterns_dir"] = []string{"/tmp/unknow"}
-	ret := p.Configure(conf)
+	ret := h.Configure(conf)
 
 	assert.NotEqual(t, ret, nil, "configuration is not correct, error should not be nil")
 
@@ -256,111 +385,92 @@ func TestTag_on_failure(t *testing.T) { t.Skip("...") }
 func TestNamed_captures_only(t *testing.T) { t.Skip("...") }
 
 func TestKeep_empty_captures(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"keep_empty_captures": true,
+		"match":               map[string]interface{}{"message": `%{COMBINEDAPACHELOG}`},
+	})
 
-	p.Configure(map[string]interface{}{"keep_empty_captures": true})
-	p.Match = map[string]string{
-		"message": `%{COMBINEDAPACHELOG}`,
-	}
-
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", `127.0.0.1 - - [11/Dec/2013:00:01:45 -0800] "GET /xampp/status.php HTTP/1.1" 200 3891 "http://cadenza/xampp/navi.php" "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.9; rv:25.0) Gecko/20100101 Firefox/25.0"`, nil)
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, `127.0.0.1 - - [11/Dec/2013:00:01:45 -0800] "GET /xampp/status.php HTTP/1.1" 200 3891 "http://cadenza/xampp/navi.php" "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.9; rv:25.0) Gecko/20100101 Firefox/25.0"`, nil)
 
 	assert.Equal(t, "", em.Fields().ValueOrEmptyForPathString("rawrequest"), "field value not proprely groked")
 }
 
 func TestKeep_empty_capturesFalse(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{"keep_empty_captures": false})
-	p.Match = map[string]string{
-		"message": `%{COMBINEDAPACHELOG}`,
-	}
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"keep_empty_captures": false,
+		"match":               map[string]interface{}{"message": `%{COMBINEDAPACHELOG}`},
+	})
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", `127.0.0.1 - - [11/Dec/2013:00:01:45 -0800] "GET /xampp/status.php HTTP/1.1" 200 3891 "http://cadenza/xampp/navi.php" "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.9; rv:25.0) Gecko/20100101 Firefox/25.0"`, nil)
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, `127.0.0.1 - - [11/Dec/2013:00:01:45 -0800] "GET /xampp/status.php HTTP/1.1" 200 3891 "http://cadenza/xampp/navi.php" "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.9; rv:25.0) Gecko/20100101 Firefox/25.0"`, nil)
 
 	_, err := em.Fields().ValueForPath("rawrequest")
 	assert.NotNil(t, err, "field should not exists")
 }
 
 func TestBreak_on_matchFalse(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{
-		"unknow":  `%{NUMBER} %{GREEDYDATA:message}`,
-		"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`,
-		"program": `%{GREEDYDATA:programname}/%{GREEDYDATA:daemon}`,
-	}
-	p.Break_on_match = false
-
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
-	// pp.Print(em.Fields())
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match": []interface{}{
+			map[string]interface{}{"unknow": `%{NUMBER} %{GREEDYDATA:message}`},
+			map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+			map[string]interface{}{"program": `%{GREEDYDATA:programname}/%{GREEDYDATA:daemon}`},
+		},
+		"break_on_match": false,
+	})
+
+	em := receive(t, h, syslogLine, nil)
 	assert.Equal(t, "smtpd", em.Fields().ValueOrEmptyForPathString("daemon"), "field value not proprely groked")
 }
 
 func TestBreak_on_matchTrue(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-
-	p.Configure(map[string]interface{}{})
-	p.Match = map[string]string{
-		"unknow":  `%{NUMBER} %{GREEDYDATA:message}`,
-		"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`,
-		"program": `%{GREEDYDATA:programname}/%{GREEDYDATA:daemon}`,
-	}
-	p.Break_on_match = true
+	_, h := newTestProcessor(t, map[string]interface{}{
+		"match": []interface{}{
+			map[string]interface{}{"unknow": `%{NUMBER} %{GREEDYDATA:message}`},
+			map[string]interface{}{"message": `%{SYSLOGBASE} %{GREEDYDATA:message}`},
+			map[string]interface{}{"program": `%{GREEDYDATA:programname}/%{GREEDYDATA:daemon}`},
+		},
+		"break_on_match": true,
+	})
 
-	//NewTestEvent(sourceAgentName string, message string, fields map[string]interface{}) Event {
-	em := testutils.NewTestEvent("srcAgentName", "Mar 16 00:01:25 evita postfix/smtpd[1713]: connect from camomile.cloud9.net[168.100.1.3]", nil)
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
-	p.Receive(&em)
-	em.AssertExpectations(t)
+	em := receive(t, h, syslogLine, nil)
 
 	_, err := em.Fields().ValueForPath("daemon")
 	assert.NotNil(t, err, "field should not exists")
 }
 
 func TestStart(t *testing.T) {
-	p := New(nil)
-	em := new(testutils.Event)
+	_, h := newTestProcessor(t, map[string]interface{}{})
 
-	ret := p.Start(em)
+	ret := h.Start()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
 
 func TestStop(t *testing.T) {
-	p := New(nil)
-	em := new(testutils.Event)
+	_, h := newTestProcessor(t, map[string]interface{}{})
 
-	ret := p.Stop(em)
+	ret := h.Stop()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
 
 func TestTick(t *testing.T) {
-	p := New(nil)
-	em := new(testutils.Event)
+	_, h := newTestProcessor(t, map[string]interface{}{})
 
-	ret := p.Tick(em)
+	ret := h.Tick()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
+}
+
+func TestMetrics(t *testing.T) {
+	p, h := newTestProcessor(t, map[string]interface{}{
+		"match": map[string]interface{}{"message": "%{SYSLOGBASE}"},
+	})
+	matches, failures := p.matches.Value(), p.failures.Value()
+
+	receive(t, h, syslogLine, nil)
+	receive(t, h, "no match", nil)
+	receive(t, h, "still no match", nil)
+
+	assert.Equal(t, matches+1, p.matches.Value())
+	assert.Equal(t, failures+2, p.failures.Value())
 }
@@ -2,11 +2,20 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 
 	"github.com/veino/processors"
 	"github.com/veino/veino"
 )
 
+const (
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
+)
+
 func New() veino.Processor {
 	return &processor{opt: &options{}}
 }
@@ -33,48 +42,157 @@ type options struct {
 	// Tags can be dynamic and include parts of the event using the %{field} syntax
 	Remove_tag []string
 
-	// The configuration for the JSON filter
+	// Serialise the Source field into a JSON string stored into Target, instead
+	// of parsing it. The whole event, but @metadata, is serialised when Source
+	// is omitted, the string replaces Source when Target is omitted
+	Json_encode bool
+
+	// Let events with invalid JSON pass through unchanged, without tag
+	Skip_on_invalid_json bool
+
+	// The configuration for the JSON filter. Events without this field pass
+	// through unchanged
 	Source string
 
+	// Append values to the tags field when the JSON could not be parsed or encoded
+	// @default : ["_jsonparsefailure"]
+	Tag_on_failure []string
+
 	// Define the target field for placing the parsed data. If this setting is omitted,
-	// the JSON data will be stored at the root (top level) of the event
+	// the JSON data will be stored at the root (top level) of the event. Arrays and
+	// other values than objects require a target
 	Target string
+
+	// Keep numbers as written, integers larger than 2^53 do not lose precision.
+	// Numbers are parsed as floats otherwise
+	Use_number bool
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	p.opt.Tag_on_failure = []string{"_jsonparsefailure"}
+
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	if p.opt.Json_encode {
+		if p.opt.Source == "" && p.opt.Target == "" {
+			return &processors.OptionError{Processor: "filter-json", Key: "target", Reason: "required to encode the whole event"}
+		}
+	} else if p.opt.Source == "" {
+		return &processors.OptionError{Processor: "filter-json", Key: "source", Reason: "required to parse JSON"}
+	}
+	return nil
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
+	if p.opt.Json_encode {
+		return p.encode(e)
+	}
 
-	json_string, err := e.Fields().ValueForPathString(p.opt.Source)
+	value, err := processors.GetField(e.Fields(), p.opt.Source)
 	if err != nil {
-		return err
+		// nothing to parse
+		p.Send(e, PORT_SUCCESS)
+		return nil
+	}
+	json_string, ok := value.(string)
+	if !ok {
+		p.Fail(e, fmt.Errorf("field %s is not a string : %T", p.opt.Source, value), value, p.opt.Tag_on_failure...)
+		return nil
 	}
 
-	byt := []byte(json_string)
-	var dat map[string]interface{}
-	if err := json.Unmarshal(byt, &dat); err != nil {
-		return err
+	dat, err := decode(json_string, p.opt.Use_number)
+	if err != nil {
+		if p.opt.Skip_on_invalid_json {
+			p.Send(e, PORT_SUCCESS)
+			return nil
+		}
+		p.Fail(e, err, json_string, p.opt.Tag_on_failure...)
+		return nil
 	}
 
 	if p.opt.Target != "" {
-		e.Fields().SetValueForPath(dat, p.opt.Target)
+		processors.SetField(e.Fields(), p.opt.Target, dat)
+	} else {
+		object, ok := dat.(map[string]interface{})
+		if !ok {
+			p.Fail(e, errors.New("parsed JSON is not an object, set a target"), json_string, p.opt.Tag_on_failure...)
+			return nil
+		}
+		for k, v := range object {
+			processors.FieldReference{k}.Set(e.Fields(), v)
+		}
+	}
+
+	p.processCommonFields(e)
+	p.Send(e, PORT_SUCCESS)
+	return nil
+}
+
+// decode parses s, which may hold any JSON value
+func decode(s string, useNumber bool) (interface{}, error) {
+	var dat interface{}
+	if !useNumber {
+		err := json.Unmarshal([]byte(s), &dat)
+		return dat, err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	if err := decoder.Decode(&dat); err != nil {
+		return nil, err
+	}
+	// as json.Unmarshal, nothing may follow the value
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after top-level value")
+	}
+	return dat, nil
+}
+
+// encode serialises Source, or the event, into a JSON string
+func (p *processor) encode(e veino.IPacket) error {
+	var value interface{}
+	if p.opt.Source == "" {
+		event := map[string]interface{}{}
+		for k, v := range *e.Fields() {
+			if k != processors.MetadataField {
+				event[k] = v
+			}
+		}
+		value = event
 	} else {
-		for k, v := range dat {
-			e.Fields().SetValueForPath(v, k)
+		var err error
+		if value, err = processors.GetField(e.Fields(), p.opt.Source); err != nil {
+			p.Fail(e, err, nil, p.opt.Tag_on_failure...)
+			return nil
 		}
 	}
 
+	b, err := json.Marshal(value)
+	if err != nil {
+		p.Fail(e, err, nil, p.opt.Tag_on_failure...)
+		return nil
+	}
+
+	target := p.opt.Target
+	if target == "" {
+		target = p.opt.Source
+	}
+	processors.SetField(e.Fields(), target, string(b))
+
+	p.processCommonFields(e)
+	p.Send(e, PORT_SUCCESS)
+	return nil
+}
+
+func (p *processor) processCommonFields(e veino.IPacket) {
 	processors.ProcessCommonFields2(e.Fields(),
 		p.opt.Add_field,
 		p.opt.Add_tag,
 		p.opt.Remove_field,
 		p.opt.Remove_tag,
 	)
-
-	p.Send(e, 0)
-	return nil
 }
 
 func (p *processor) Tick(e veino.IPacket) error { return nil }
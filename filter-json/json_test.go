@@ -0,0 +1,155 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+)
+
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		path     string
+		expected interface{}
+	}{
+		{"root", map[string]interface{}{"source": "payload"}, "user.name", "alice"},
+		{"target", map[string]interface{}{"source": "payload", "target": "doc"}, "doc.user.name", "alice"},
+		{"add_field", map[string]interface{}{"source": "payload", "add_field": map[string]interface{}{"who": "%{user.name}"}}, "who", "alice"},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"payload": `{"user":{"name":"alice"}}`}), test.name)
+		if h.AssertSentCount(t, 0, 1) {
+			h.AssertField(t, h.Sent(0)[0], test.path, test.expected)
+		}
+	}
+}
+
+func TestReceiveInvalidJSON(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"source": "payload"}))
+
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"payload": `{"user":`}))
+	if h.AssertSentCount(t, PORT_FAILURE, 1) {
+		e := h.Sent(PORT_FAILURE)[0]
+		h.AssertField(t, e, "_error.processor", "filter-json")
+		h.AssertField(t, e, "_error.value", `{"user":`)
+		h.AssertNoField(t, e, "user")
+	}
+}
+
+func TestReceiveFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    map[string]interface{}
+		payload interface{}
+		port    int
+		tags    []string
+	}{
+		{"invalid", map[string]interface{}{"source": "payload"}, `{"user":`, PORT_FAILURE, []string{"_jsonparsefailure"}},
+		{"trailing data", map[string]interface{}{"source": "payload", "use_number": true}, `{} }`, PORT_FAILURE, []string{"_jsonparsefailure"}},
+		{"tag_on_failure", map[string]interface{}{"source": "payload", "tag_on_failure": []string{"bad_json"}}, `nope`, PORT_FAILURE, []string{"bad_json"}},
+		{"skip_on_invalid_json", map[string]interface{}{"source": "payload", "skip_on_invalid_json": true}, `{"user":`, PORT_SUCCESS, nil},
+		{"array without target", map[string]interface{}{"source": "payload"}, `[1, 2]`, PORT_FAILURE, []string{"_jsonparsefailure"}},
+		{"not a string", map[string]interface{}{"source": "payload"}, 12.5, PORT_FAILURE, []string{"_jsonparsefailure"}},
+		{"no source", map[string]interface{}{"source": "body"}, `{"user":`, PORT_SUCCESS, nil},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"payload": test.payload}), test.name)
+		if h.AssertSentCount(t, test.port, 1) {
+			e := h.Sent(test.port)[0]
+			h.AssertTags(t, e, test.tags...)
+			h.AssertField(t, e, "payload", test.payload)
+		}
+	}
+}
+
+func TestReceiveRoots(t *testing.T) {
+	tests := []struct {
+		payload  string
+		expected interface{}
+	}{
+		{`[1, "a"]`, []interface{}{float64(1), "a"}},
+		{`"text"`, "text"},
+		{`12.5`, 12.5},
+		{`null`, nil},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		assert.Nil(t, h.Configure(map[string]interface{}{"source": "payload", "target": "doc"}))
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"payload": test.payload}), test.payload)
+		if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+			assert.Equal(t, test.expected, (*h.Sent(PORT_SUCCESS)[0].Fields())["doc"], test.payload)
+		}
+	}
+}
+
+func TestReceiveUseNumber(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"source": "payload", "use_number": true}))
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"payload": `{"id": 9007199254740993, "ratio": 0.5}`}))
+	if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		e := h.Sent(PORT_SUCCESS)[0]
+		h.AssertField(t, e, "id", json.Number("9007199254740993"))
+		h.AssertField(t, e, "ratio", json.Number("0.5"))
+	}
+}
+
+func TestReceiveEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		path     string
+		expected string
+	}{
+		{"source", map[string]interface{}{"json_encode": true, "source": "user"}, "user", `{"name":"alice"}`},
+		{"target", map[string]interface{}{"json_encode": true, "source": "user", "target": "user_json"}, "user_json", `{"name":"alice"}`},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", map[string]interface{}{"user": map[string]interface{}{"name": "alice"}}), test.name)
+		if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+			h.AssertField(t, h.Sent(PORT_SUCCESS)[0], test.path, test.expected)
+		}
+	}
+
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"json_encode": true, "target": "[@metadata][json]"}))
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"user": map[string]interface{}{"name": "alice"}}))
+	if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		e := h.Sent(PORT_SUCCESS)[0]
+		encoded, _ := e.Fields().ValueForPath("@metadata.json")
+		assert.Contains(t, encoded, `"user":{"name":"alice"}`)
+		assert.NotContains(t, encoded, "@metadata")
+	}
+
+	// round trip
+	h = ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"source": "user", "target": "user"}))
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"user": `{"name":"alice"}`}))
+	if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		h.AssertField(t, h.Sent(PORT_SUCCESS)[0], "user.name", "alice")
+	}
+}
+
+func TestConfigureErrors(t *testing.T) {
+	assert.NotNil(t, ptesting.New(New()).Configure(map[string]interface{}{}))
+	assert.NotNil(t, ptesting.New(New()).Configure(map[string]interface{}{"json_encode": true}))
+}
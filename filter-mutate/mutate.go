@@ -2,12 +2,18 @@
 package mutate
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/clbanning/mxj"
 	"github.com/veino/processors"
 	"github.com/veino/veino"
 )
 
 const (
-	PORT_SUCCESS = 0
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
 )
 
 func New() veino.Processor {
@@ -16,7 +22,12 @@ func New() veino.Processor {
 
 type processor struct {
 	processors.Base
+	options
+}
 
+// options are the mutations of the filter, an operation of Operations holds
+// one of them
+type options struct {
 	// If this filter is successful, add any arbitrary fields to this event.
 	Add_field map[string]interface{}
 
@@ -24,15 +35,33 @@ type processor struct {
 	// Tags can be dynamic and include parts of the event using the %{field} syntax.
 	Add_tag []string
 
+	// Capitalize string fields : the first letter is upper cased, the others lower cased
+	Capitalize []string
+
+	// Set fields which are missing or null to a default value
+	Coerce map[string]interface{}
+
 	// Convert a field’s value to a different type, like turning a string to an integer.
 	// If the field value is an array, all members will be converted. If the field is a hash,
-	// no action will be taken.
+	// its values will be converted.
 	// If the conversion type is boolean, the acceptable values are:
 	// True: true, t, yes, y, and 1
 	// False: false, f, no, n, and 0
-	// If a value other than these is provided, it will pass straight through and log a warning message.
-	// Valid conversion targets are: integer, float, string, and boolean.
-	Convert map[string]string
+	// If a value can not be converted, it will pass straight through and log a warning message,
+	// see Strict.
+	// Valid conversion targets are:
+	// integer, float : "1,234.5" (comma as thousands separator)
+	// integer_eu, float_eu : "1.234,5" (dot or space as thousands separator, comma as decimal separator)
+	// boolean
+	// string : from any value, hashes are encoded as JSON
+	// bytes : "10MB" or "1.5 KiB" as a number of bytes (KB is 1000 bytes, KiB 1024 bytes)
+	// duration : "1m30s" as a number of seconds
+	// timestamp : a RFC3339 date or a number of seconds since epoch, as a @timestamp
+	Convert map[string]string `enum:"integer,integer_eu,float,float_eu,boolean,string,bytes,duration,timestamp"`
+
+	// Copy an existing field to another field, an existing target field is replaced.
+	// Hashes and arrays are copied
+	Copy map[string]string
 
 	// Convert a string field by applying a regular expression and a replacement. If the field is not a string, no action will be taken.
 	// This configuration takes an array consisting of 3 elements per field/substitution.
@@ -45,7 +74,9 @@ type processor struct {
 	// Convert a value to its lowercase equivalent
 	Lowercase []string
 
-	// Merge two fields of arrays or hashes. String fields will be automatically be converted into an array
+	// Merge two fields of arrays or hashes. String fields will be automatically be converted into an array.
+	// Hashes are merged recursively, values of the source field replace the ones of the destination field.
+	// Arrays are merged without duplicates
 	Merge map[string]string
 
 	// If this filter is successful, remove arbitrary fields from this event.
@@ -65,6 +96,10 @@ type processor struct {
 	// Split a field to an array using a separator character. Only works on string fields
 	Split map[string]string
 
+	// Split fields in at most Split_limit elements, the last one holding the rest of the string.
+	// There is no limit when 0
+	Split_limit int
+
 	// Strip whitespace from processors. NOTE: this only works on leading and trailing whitespace
 	Strip []string
 
@@ -76,31 +111,136 @@ type processor struct {
 
 	// remove all fields, except theses fields (work only with first level fields)
 	Remove_all_but []string
+
+	// When a conversion fails, send the event to the failure port, tagged with
+	// Tag_on_failure, instead of passing the value through
+	Strict bool
+
+	// Append values to the tags field when Strict and a conversion fails
+	// @default : ["_mutate_error"]
+	Tag_on_failure []string
+
+	// Operations run one after the other, in the written order, after the
+	// options above. Each operation holds exactly one option, as
+	// { rename => { "a" => "b" } }, write an operation per field when the order
+	// of fields matters
+	Operations []options
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
-	return p.ConfigureAndValidate(ctx, conf, p)
+	p.Tag_on_failure = []string{"_mutate_error"}
+	if err := p.ConfigureAndValidate(ctx, conf, &p.options); err != nil {
+		return err
+	}
+
+	for i, operation := range p.Operations {
+		names := operation.names()
+		for _, name := range names {
+			if !isOperation(name) {
+				return &processors.OptionError{
+					Processor: "filter-mutate",
+					Key:       fmt.Sprintf("operations[%d].%s", i, name),
+					Reason:    "is a setting of the filter, not an operation",
+				}
+			}
+		}
+		if len(names) != 1 {
+			return &processors.OptionError{
+				Processor: "filter-mutate",
+				Key:       fmt.Sprintf("operations[%d]", i),
+				Reason:    fmt.Sprintf("an operation holds one option, got %s", strings.Join(names, ", ")),
+			}
+		}
+	}
+	return nil
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-	processors.AddFields(p.Add_field, e.Fields())
-	processors.AddTags(p.Add_tag, e.Fields())
-	processors.UpdateFields(p.Update, e.Fields())
-	processors.UpdateFields(p.Replace, e.Fields())
-	processors.RemoveFields(p.Remove_field, e.Fields())
-	processors.RenameFields(p.Rename, e.Fields())
-	processors.UpperCaseFields(p.Uppercase, e.Fields())
-	processors.LowerCaseFields(p.Lowercase, e.Fields())
-	processors.RemoveAllButFields(p.Remove_all_but, e.Fields())
-	processors.Convert(p.Convert, e.Fields())
-	processors.Join(p.Join, e.Fields())
-	processors.RemoveTags(p.Remove_tag, e.Fields())
-	processors.Gsub(p.Gsub, e.Fields())
-	processors.Split(p.Split, e.Fields())
-	processors.Strip(p.Strip, e.Fields())
-	processors.Merge(p.Merge, e.Fields())
+	errs := p.options.apply(e.Fields(), p.Split_limit, p.Strict)
+	for _, operation := range p.Operations {
+		if len(errs) > 0 && p.Strict {
+			break
+		}
+		errs = append(errs, operation.apply(e.Fields(), p.Split_limit, p.Strict)...)
+	}
+
+	if len(errs) > 0 {
+		if p.Strict {
+			var value interface{} = errs[0].Value
+			if len(errs) > 1 {
+				values := map[string]interface{}{}
+				for _, convertErr := range errs {
+					values[convertErr.Path] = convertErr.Value
+				}
+				value = values
+			}
+			p.Fail(e, errs, value, p.Tag_on_failure...)
+			return nil
+		}
+		p.Logger.Warn("conversion failed, values are kept", "error", errs)
+	}
 
 	p.Send(e, PORT_SUCCESS)
 
 	return nil
 }
+
+// apply runs the mutations of o, it returns conversion errors. When strict,
+// mutations stop at a conversion error
+func (o *options) apply(fields *mxj.Map, splitLimit int, strict bool) processors.ConvertErrors {
+	processors.CoerceFields(o.Coerce, fields)
+	processors.AddFields(o.Add_field, fields)
+	processors.AddTags(o.Add_tag, fields)
+	processors.UpdateFields(o.Update, fields)
+	processors.UpdateFields(o.Replace, fields)
+	processors.RemoveFields(o.Remove_field, fields)
+	processors.RenameFields(o.Rename, fields)
+	processors.UpperCaseFields(o.Uppercase, fields)
+	processors.LowerCaseFields(o.Lowercase, fields)
+	processors.CapitalizeFields(o.Capitalize, fields)
+	processors.RemoveAllButFields(o.Remove_all_but, fields)
+	errs, _ := processors.Convert(o.Convert, fields).(processors.ConvertErrors)
+	if len(errs) > 0 && strict {
+		return errs
+	}
+	processors.Join(o.Join, fields)
+	processors.RemoveTags(o.Remove_tag, fields)
+	processors.Gsub(o.Gsub, fields)
+	processors.SplitN(o.Split, splitLimit, fields)
+	processors.Strip(o.Strip, fields)
+	processors.Merge(o.Merge, fields)
+	processors.CopyFields(o.Copy, fields)
+	return errs
+}
+
+// names returns the names of the options set in o
+func (o *options) names() []string {
+	names := []string{}
+	v := reflect.ValueOf(o).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		set := false
+		switch field.Kind() {
+		case reflect.Bool:
+			set = field.Bool()
+		case reflect.Int:
+			set = field.Int() != 0
+		default:
+			set = field.Len() > 0
+		}
+		if set {
+			names = append(names, strings.ToLower(v.Type().Field(i).Name))
+		}
+	}
+	return names
+}
+
+// isOperation tells whether the option name is a mutation, and not a setting
+// of the filter
+func isOperation(name string) bool {
+	switch name {
+	case "operations", "strict", "tag_on_failure", "split_limit":
+		return false
+	}
+	return true
+}
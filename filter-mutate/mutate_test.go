@@ -4,12 +4,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/veino/runtime/testutils"
-	"github.com/veino/veino"
+	ptesting "github.com/veino/processors/testing"
 )
 
 func TestNew(t *testing.T) {
-	p := New(nil)
+	p := New()
 	_, ok := p.(*processor)
 	assert.Equal(t, ok, true, "New() should return a mutate.processos struct")
 }
@@ -39,8 +38,7 @@ func getExampleConfiguration() map[string]interface{} {
 		"split": map[string]interface{}{
 			"splitme": ",",
 		},
-		"strip":  []string{"trim1", "trim2"},
-		"unknow": "Unknow value",
+		"strip": []string{"trim1", "trim2"},
 
 		"merge": map[string]interface{}{
 			"array_dst": "array_src",
@@ -49,20 +47,30 @@ func getExampleConfiguration() map[string]interface{} {
 }
 
 func TestConfigureError(t *testing.T) {
-	p := New(nil).(*processor)
+	h := ptesting.New(New())
 	conf := map[string]interface{}{
 		"update": 54,
 	}
-	ret := p.Configure(conf)
+	ret := h.Configure(conf)
 	assert.NotEqual(t, ret, nil, "configuration is not correct, it should return an error")
 	assert.Implements(t, new(error), ret)
 }
 
+func TestConfigureUnknownOption(t *testing.T) {
+	h := ptesting.New(New())
+	err := h.Configure(map[string]interface{}{
+		"remove_tags": []string{"a"},
+	})
+	if assert.NotNil(t, err, "unknown options should be rejected") {
+		assert.Equal(t, `filter-mutate : remove_tags : unknown option, did you mean "remove_tag" ?`, err.Error())
+	}
+}
+
 func TestConfigure(t *testing.T) {
-	p := New(nil).(*processor)
-	conf := getExampleConfiguration()
+	p := New().(*processor)
+	h := ptesting.New(p)
 
-	ret := p.Configure(conf)
+	ret := h.Configure(getExampleConfiguration())
 	assert.Equal(t, ret, nil, "configuration is correct, it should return nil")
 
 	assert.Equal(t, len(p.Lowercase), 2, "lowercase options should have 2 strings")
@@ -74,31 +82,29 @@ func TestConfigure(t *testing.T) {
 }
 
 func TestReceive(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
-	p.Configure(getExampleConfiguration())
-
-	em := testutils.NewTestEvent("test", "test", nil)
-	em.Fields().SetValueForPath("VALUE", "field1")
-	em.Fields().SetValueForPath("loRem", "ucfield2")
-	em.Fields().SetValueForPath("newvalue", "upfield3")
-	em.Fields().SetValueForPath("myValue", "rnfieldA")
-	em.Fields().SetValueForPath("4", "fieldname")
-	em.Fields().SetValueForPath("abc /dEF/GHJ-K/", "fngsub1")
-	em.Fields().SetValueForPath("Hello How are you ? c#omment lo-l ", "fngsub2")
-	em.Fields().SetValueForPath("hello,my,name,is,yow", "splitme")
-
-	em.Fields().SetValueForPath("bonjour\t", "trim1")
-	em.Fields().SetValueForPath(" bonjour 	", "trim2")
-
-	em.Fields().SetValueForPath([]string{"apple", "banana", "200"}, "array_dst")
-	em.Fields().SetValueForPath([]string{"200", "500"}, "array_src")
-
-	em.On("Send").Return(nil)
-
-	p.Receive(em)
-
-	em.AssertExpectations(t)
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(getExampleConfiguration()))
+
+	err := h.Receive("test", map[string]interface{}{
+		"field1":    "VALUE",
+		"ucfield2":  "loRem",
+		"upfield3":  "newvalue",
+		"rnfieldA":  "myValue",
+		"fieldname": "4",
+		"fngsub1":   "abc /dEF/GHJ-K/",
+		"fngsub2":   "Hello How are you ? c#omment lo-l ",
+		"splitme":   "hello,my,name,is,yow",
+		"trim1":     "bonjour\t",
+		"trim2":     " bonjour 	",
+		"array_dst": []string{"apple", "banana", "200"},
+		"array_src": []string{"200", "500"},
+	})
+	assert.Nil(t, err)
+
+	if !h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		return
+	}
+	em := h.Sent(PORT_SUCCESS)[0]
 
 	assert.Equal(t, "value1", em.Fields().ValueOrEmptyForPathString("adfield1"), "a new field should be added")
 	assert.Equal(t, "value", em.Fields().ValueOrEmptyForPathString("field1"), "field's value should be lowercase")
@@ -111,69 +117,311 @@ func TestReceive(t *testing.T) {
 	assert.Equal(t, 4, number, "fieldname should be 4")
 
 	assert.Equal(t, "abc _dEF_GHJ-K_", em.Fields().ValueOrEmptyForPathString("fngsub1"), "fngsub1 should be abc _dEF_GHJ-K_")
-	assert.Equal(t, "abc _dEF_GHJ-K_", em.Fields().ValueOrEmptyForPathString("fngsub1"), "fngsub1 should be abc _dEF_GHJ-K_")
+	assert.Equal(t, "Hello How are you . c.omment lo.l ", em.Fields().ValueOrEmptyForPathString("fngsub2"), "fngsub2 should be Hello How are you . c.omment lo.l ")
 	value, _ := em.Fields().ValueForPath("splitme")
 	assert.Equal(t, []string{"hello", "my", "name", "is", "yow"}, value, "split ")
 
+	assert.Equal(t, "bonjour", em.Fields().ValueOrEmptyForPathString("trim1"), "trim1 should be stripped")
+	assert.Equal(t, "bonjour", em.Fields().ValueOrEmptyForPathString("trim2"), "trim2 should be stripped")
+
 	array, _ := em.Fields().ValueForPath("array_dst")
 	assert.Equal(t, []string{"apple", "banana", "200", "500"}, array, "array merge")
-
 }
 
 func TestReceiveRemoveAllBut(t *testing.T) {
-	p := New(nil).(*processor)
-	p.Send = func(veino.IPacket, ...int) bool { return true }
+	h := ptesting.New(New())
 
 	conf := map[string]interface{}{
 		"Remove_all_but": []string{"upfield3", "field1"},
 	}
-	p.Configure(conf)
-
-	em := testutils.NewTestEvent("test", "test", nil)
-	em.Fields().SetValueForPath("VALUE", "field1")
-	em.Fields().SetValueForPath("loRem", "ucfield2")
-	em.Fields().SetValueForPath("newvalue", "upfield3")
-	em.Fields().SetValueForPath("myValue", "rnfieldA")
+	assert.Nil(t, h.Configure(conf))
 
-	// em.On("Pipe", PORT_SUCCESS).Return(nil)
+	h.Receive("test", map[string]interface{}{
+		"field1":   "VALUE",
+		"ucfield2": "loRem",
+		"upfield3": "newvalue",
+		"rnfieldA": "myValue",
+	})
 
-	p.Receive(&em)
-
-	em.AssertExpectations(t)
+	if !h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		return
+	}
+	em := h.Sent(PORT_SUCCESS)[0]
 	assert.Equal(t, false, em.Fields().Exists("ucfield2"), "field should not exists")
 	assert.Equal(t, false, em.Fields().Exists("rnfieldA"), "field should not exists")
 
 	assert.Equal(t, true, em.Fields().Exists("field1"), "field should exists")
 	assert.Equal(t, true, em.Fields().Exists("upfield3"), "field should exists")
+}
 
+func TestReceiveOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		fields   map[string]interface{}
+		path     string
+		expected interface{}
+	}{
+		{"add_field", map[string]interface{}{"add_field": map[string]interface{}{"greeting": "hello %{name}"}},
+			map[string]interface{}{"name": "alice"}, "greeting", "hello alice"},
+		{"replace", map[string]interface{}{"replace": map[string]interface{}{"name": "bob"}},
+			map[string]interface{}{"name": "alice"}, "name", "bob"},
+		{"uppercase", map[string]interface{}{"uppercase": []string{"name"}},
+			map[string]interface{}{"name": "alice"}, "name", "ALICE"},
+		{"lowercase", map[string]interface{}{"lowercase": []string{"name"}},
+			map[string]interface{}{"name": "ALICE"}, "name", "alice"},
+		{"convert float", map[string]interface{}{"convert": map[string]interface{}{"n": "float"}},
+			map[string]interface{}{"n": "1.5"}, "n", 1.5},
+		{"convert boolean", map[string]interface{}{"convert": map[string]interface{}{"n": "boolean"}},
+			map[string]interface{}{"n": "yes"}, "n", true},
+		{"split", map[string]interface{}{"split": map[string]interface{}{"n": "|"}},
+			map[string]interface{}{"n": "a|b"}, "n", []string{"a", "b"}},
+		{"gsub", map[string]interface{}{"gsub": []string{"n", "[0-9]", "#"}},
+			map[string]interface{}{"n": "a1b2"}, "n", "a#b#"},
+		{"strip", map[string]interface{}{"strip": []string{"n"}},
+			map[string]interface{}{"n": "  a  "}, "n", "a"},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		h.Receive("test", test.fields)
+		if !h.AssertSentCount(t, PORT_SUCCESS, 1) {
+			continue
+		}
+		value, err := h.Sent(PORT_SUCCESS)[0].Fields().ValueForPath(test.path)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.expected, value, test.name)
+	}
 }
 
 func TestStart(t *testing.T) {
-	p := New(nil)
-	em := new(testutils.Event)
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{}))
 
-	ret := p.Start(em)
+	ret := h.Start()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
 
 func TestStop(t *testing.T) {
-	p := New(nil)
-	em := new(testutils.Event)
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{}))
 
-	ret := p.Stop(em)
+	ret := h.Stop()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
 
 func TestTick(t *testing.T) {
-	p := New(nil)
-	em := new(testutils.Event)
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{}))
 
-	ret := p.Tick(em)
+	ret := h.Tick()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
+}
+
+func TestReceiveOrderedOperations(t *testing.T) {
+	h := ptesting.New(New())
+	err := h.Configure(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"rename": map[string]interface{}{"status": "code"}},
+			map[string]interface{}{"convert": map[string]interface{}{"code": "integer"}},
+			map[string]interface{}{"add_field": map[string]interface{}{"label": "%{code}-%{name}"}},
+			map[string]interface{}{"uppercase": []string{"label"}},
+			map[string]interface{}{"gsub": []string{"label", "-", "_"}},
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	h.Receive("test", map[string]interface{}{"status": "404", "name": "not-found"})
+	if !h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		return
+	}
+	fields := h.Sent(PORT_SUCCESS)[0].Fields()
+	assert.Equal(t, false, fields.Exists("status"))
+	code, _ := fields.ValueForPath("code")
+	assert.Equal(t, 404, code, "convert runs after rename")
+	assert.Equal(t, "404_NOT_FOUND", fields.ValueOrEmptyForPathString("label"), "gsub runs after uppercase")
+}
+
+func TestConfigureOperationsErrors(t *testing.T) {
+	h := ptesting.New(New())
+	err := h.Configure(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"rename": map[string]interface{}{"a": "b"}, "strip": []string{"b"}},
+		},
+	})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "filter-mutate : operations[0] : an operation holds one option, got rename, strip", err.Error())
+	}
+
+	err = h.Configure(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"renme": map[string]interface{}{"a": "b"}},
+		},
+	})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, `filter-mutate : operations[0].renme : unknown option, did you mean "rename" ?`, err.Error())
+	}
+}
+
+func TestReceiveConvertStrict(t *testing.T) {
+	h := ptesting.New(New())
+	conf := map[string]interface{}{
+		"convert": map[string]interface{}{"code": "integer"},
+		"strict":  true,
+	}
+	assert.Nil(t, h.Configure(conf))
+
+	h.Receive("test", map[string]interface{}{"code": "oops"})
+	if !h.AssertSentCount(t, PORT_FAILURE, 1) {
+		return
+	}
+	fields := h.Sent(PORT_FAILURE)[0].Fields()
+	assert.Equal(t, `code : can not convert "oops" to integer`, fields.ValueOrEmptyForPathString("_error.reason"))
+	tags, _ := fields.ValueForPath("tags")
+	assert.Equal(t, []string{"_mutate_error"}, tags)
+
+	h = ptesting.New(New())
+	delete(conf, "strict")
+	assert.Nil(t, h.Configure(conf))
+	h.Receive("test", map[string]interface{}{"code": "oops"})
+	if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		assert.Equal(t, "oops", h.Sent(PORT_SUCCESS)[0].Fields().ValueOrEmptyForPathString("code"), "value passes through")
+	}
+}
+
+func TestReceiveConvertStrictStops(t *testing.T) {
+	conf := map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"convert": map[string]interface{}{"code": "integer"}},
+			map[string]interface{}{"uppercase": []string{"name"}},
+			map[string]interface{}{"convert": map[string]interface{}{"size": "float"}},
+		},
+		"convert": map[string]interface{}{"ratio": "float"},
+		"strip":   []string{"name"},
+		"strict":  true,
+	}
+	fields := func() map[string]interface{} {
+		return map[string]interface{}{"code": "oops", "ratio": "half", "size": "big", "name": " alice "}
+	}
+
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(conf))
+	h.Receive("test", fields())
+	if h.AssertSentCount(t, PORT_FAILURE, 1) {
+		em := h.Sent(PORT_FAILURE)[0]
+		h.AssertField(t, em, "name", " alice ")
+		h.AssertField(t, em, "_error.reason", `ratio : can not convert "half" to float`)
+		h.AssertField(t, em, "_error.value", "half")
+	}
+
+	h = ptesting.New(New())
+	delete(conf, "strict")
+	assert.Nil(t, h.Configure(conf))
+	h.Receive("test", fields())
+	if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		h.AssertField(t, h.Sent(PORT_SUCCESS)[0], "name", "ALICE")
+	}
+	for _, reason := range []string{`ratio : can not convert \"half\"`, `code : can not convert \"oops\"`, `size : can not convert \"big\"`} {
+		assert.Contains(t, h.Logs(), reason)
+	}
+}
+
+func TestConfigureConvertTarget(t *testing.T) {
+	h := ptesting.New(New())
+	err := h.Configure(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"convert": map[string]interface{}{"code": "int"}},
+		},
+	})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), `filter-mutate : operations[0].convert : "int" is not one of integer,`)
+	}
+}
+
+func TestReceiveNewOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		fields   map[string]interface{}
+		path     string
+		expected interface{}
+	}{
+		{"copy", map[string]interface{}{"copy": map[string]interface{}{"src": "dst"}},
+			map[string]interface{}{"src": map[string]interface{}{"a": "b"}}, "dst", map[string]interface{}{"a": "b"}},
+		{"copy keeps source", map[string]interface{}{"copy": map[string]interface{}{"src": "dst"}},
+			map[string]interface{}{"src": "value"}, "src", "value"},
+		{"coerce missing", map[string]interface{}{"coerce": map[string]interface{}{"level": "info"}},
+			map[string]interface{}{}, "level", "info"},
+		{"coerce null", map[string]interface{}{"coerce": map[string]interface{}{"level": "info"}},
+			map[string]interface{}{"level": nil}, "level", "info"},
+		{"coerce set", map[string]interface{}{"coerce": map[string]interface{}{"level": "info"}},
+			map[string]interface{}{"level": "warn"}, "level", "warn"},
+		{"capitalize", map[string]interface{}{"capitalize": []string{"name"}},
+			map[string]interface{}{"name": "éLODIE"}, "name", "Élodie"},
+		{"split limit", map[string]interface{}{"split": map[string]interface{}{"n": ","}, "split_limit": 2},
+			map[string]interface{}{"n": "a,b,c"}, "n", []string{"a", "b,c"}},
+		{"merge string into array", map[string]interface{}{"merge": map[string]interface{}{"dst": "src"}},
+			map[string]interface{}{"dst": []string{"a"}, "src": "b"}, "dst", []string{"a", "b"}},
+		{"merge into string", map[string]interface{}{"merge": map[string]interface{}{"dst": "src"}},
+			map[string]interface{}{"dst": "a", "src": []interface{}{"b", 1.0}}, "dst", []interface{}{"a", "b", 1.0}},
+		{"merge hashes", map[string]interface{}{"merge": map[string]interface{}{"dst": "src"}},
+			map[string]interface{}{
+				"dst": map[string]interface{}{"a": 1.0, "nested": map[string]interface{}{"x": "1", "y": "2"}},
+				"src": map[string]interface{}{"b": 2.0, "nested": map[string]interface{}{"y": "3"}},
+			},
+			"dst", map[string]interface{}{"a": 1.0, "b": 2.0, "nested": map[string]interface{}{"x": "1", "y": "3"}}},
+		{"merge hash with string", map[string]interface{}{"merge": map[string]interface{}{"dst": "src"}},
+			map[string]interface{}{"dst": map[string]interface{}{"a": 1.0}, "src": "b"}, "dst", map[string]interface{}{"a": 1.0}},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		h.Receive("test", test.fields)
+		if !h.AssertSentCount(t, PORT_SUCCESS, 1) {
+			continue
+		}
+		// arrays are read as a whole, and not as several values of the path
+		value := (*h.Sent(PORT_SUCCESS)[0].Fields())[test.path]
+		assert.Equal(t, test.expected, value, test.name)
+	}
+}
+
+func TestReceiveCopyIsDeep(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"copy": map[string]interface{}{"src": "dst"}},
+			map[string]interface{}{"replace": map[string]interface{}{"[dst][a]": "changed"}},
+		},
+	}))
+
+	h.Receive("test", map[string]interface{}{"src": map[string]interface{}{"a": "b"}})
+	if h.AssertSentCount(t, PORT_SUCCESS, 1) {
+		fields := h.Sent(PORT_SUCCESS)[0].Fields()
+		assert.Equal(t, "b", fields.ValueOrEmptyForPathString("src.a"))
+		assert.Equal(t, "changed", fields.ValueOrEmptyForPathString("dst.a"))
+	}
+}
+
+func TestConfigureOperationSetting(t *testing.T) {
+	h := ptesting.New(New())
+	err := h.Configure(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"split_limit": 2},
+		},
+	})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "filter-mutate : operations[0].split_limit : is a setting of the filter, not an operation", err.Error())
+	}
 }
@@ -2,13 +2,20 @@
 package split
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/veino/processors"
 	"github.com/veino/veino"
 )
 
 const (
-	PORT_SUCCESS = 0
-	PORT_ERROR   = 1
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
+
+	// PORT_ERROR is the former name of PORT_FAILURE
+	PORT_ERROR = PORT_FAILURE
 )
 
 func New() veino.Processor {
@@ -18,13 +25,28 @@ func New() veino.Processor {
 type processor struct {
 	processors.Base
 
-	// The field which value is split by the terminator
+	pattern *regexp.Regexp
+
+	// The field which value is split by the terminator. Arrays are split
+	// into their elements
+	// @default : "message"
 	Field string
 	// The field within the new event which the value is split into. If not set, target field defaults to split field name
 	Target string
 	// The string to split on. This is usually a line terminator, but can be any string
-	// Default value is "\n"
+	// @default : "\n"
 	Terminator string
+	// A regular expression to split strings on, instead of Terminator
+	Pattern string
+
+	// The maximum number of events an event is split into. An event holding
+	// more values is tagged with "_splitoverflow" and sent to the failure port.
+	// There is no limit when 0
+	Max_splits int
+
+	// Store the position of each new event among the split values into this
+	// field, starting at 0, as "split_index"
+	Index_field string
 
 	// If this filter is successful, add any arbitrary fields to this event.
 	// Field names can be dynamic and include parts of the event using the %{field}.
@@ -43,37 +65,94 @@ type processor struct {
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
-	return p.ConfigureAndValidate(ctx, conf, p)
+	p.Field = "message"
+	p.Terminator = "\n"
+
+	if err := p.ConfigureAndValidate(ctx, conf, p); err != nil {
+		return err
+	}
+
+	if p.Target == "" {
+		p.Target = p.Field
+	}
+	if p.Pattern != "" {
+		pattern, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return &processors.OptionError{Processor: "filter-split", Key: "pattern", Reason: err.Error()}
+		}
+		p.pattern = pattern
+	}
+	return nil
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-	// recupere les splits
-	splits, _ := e.Fields().ValuesForPath(p.Field)
-	// veino.Logger().Infof("err = %#v\nvalue=%#v\n\n", err, splits)
+	value, err := processors.GetField(e.Fields(), p.Field)
+	if err != nil {
+		p.Fail(e, fmt.Errorf("field %s not found", p.Field), nil)
+		return nil
+	}
 
+	splits := p.split(value)
 	if len(splits) == 0 {
-		p.Send(e, PORT_ERROR)
+		p.Fail(e, fmt.Errorf("field %s holds no value to split", p.Field), value)
+		return nil
+	}
+	if p.Max_splits > 0 && len(splits) > p.Max_splits {
+		p.Fail(e, fmt.Errorf("field %s holds %d values, more than %d", p.Field, len(splits), p.Max_splits), nil, "_splitoverflow")
 		return nil
 	}
 
-	// iterate over found splits
-	for _, split := range splits {
-		// create a new event
-		// set target value with split
+	timestamp, hasTimestamp := (*e.Fields())["@timestamp"]
+	for i, split := range splits {
 		cp, _ := e.Fields().Copy()
-		cp.SetValueForPath(split, p.Target)
-
-		processors.ProcessCommonFields2(&cp,
+		// the builder sets the message and may set a new @timestamp
+		child := p.NewPacket(e.Message(), cp)
+		if hasTimestamp {
+			(*child.Fields())["@timestamp"] = timestamp
+		}
+		processors.SetField(child.Fields(), p.Target, split)
+		if p.Index_field != "" {
+			processors.SetField(child.Fields(), p.Index_field, i)
+		}
+
+		processors.ProcessCommonFields2(child.Fields(),
 			p.Add_field,
 			p.Add_tag,
 			p.Remove_field,
 			p.Remove_Tag,
 		)
 
-		// e := veino.NewEvent(e.ToAgentName(), e.Message(), cp)
-		e := p.NewPacket(e.Message(), cp)
-		p.Send(e, 0)
+		p.Send(child, PORT_SUCCESS)
 	}
 
 	return nil
 }
+
+// split returns the values value is split into : the elements of an array,
+// the non empty parts of a string, or value itself
+func (p *processor) split(value interface{}) []interface{} {
+	splits := []interface{}{}
+	switch v := value.(type) {
+	case []interface{}:
+		splits = v
+	case []string:
+		for _, s := range v {
+			splits = append(splits, s)
+		}
+	case string:
+		var parts []string
+		if p.pattern != nil {
+			parts = p.pattern.Split(v, -1)
+		} else {
+			parts = strings.Split(v, p.Terminator)
+		}
+		for _, part := range parts {
+			if part != "" {
+				splits = append(splits, part)
+			}
+		}
+	default:
+		splits = append(splits, v)
+	}
+	return splits
+}
@@ -0,0 +1,115 @@
+package split
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+)
+
+func TestReceive(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{
+		"field":   "items",
+		"target":  "item",
+		"add_tag": []string{"splitted"},
+	}))
+
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"items": []interface{}{"a", "b", "c"}}))
+
+	if !h.AssertSentCount(t, PORT_SUCCESS, 3) {
+		return
+	}
+	for i, expected := range []string{"a", "b", "c"} {
+		e := h.Sent(PORT_SUCCESS)[i]
+		h.AssertField(t, e, "item", expected)
+		h.AssertTags(t, e, "splitted")
+	}
+}
+
+func TestReceiveNoField(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"field": "items", "target": "item"}))
+
+	assert.Nil(t, h.Receive("test", nil))
+	h.AssertSentCount(t, PORT_SUCCESS, 0)
+	h.AssertSentCount(t, PORT_FAILURE, 1)
+}
+
+func TestReceiveString(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		fields   map[string]interface{}
+		path     string
+		expected []interface{}
+	}{
+		{"terminator", map[string]interface{}{"field": "body"}, map[string]interface{}{"body": "a\nb\n\nc\n"},
+			"body", []interface{}{"a", "b", "c"}},
+		{"custom terminator", map[string]interface{}{"field": "csv", "terminator": ","}, map[string]interface{}{"csv": "a,b"},
+			"csv", []interface{}{"a", "b"}},
+		{"pattern", map[string]interface{}{"field": "body", "target": "record", "pattern": `\s*;\s*`}, map[string]interface{}{"body": "a ; b;c"},
+			"record", []interface{}{"a", "b", "c"}},
+		{"array of hashes", map[string]interface{}{"field": "items", "target": "item"},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 1.0}, map[string]interface{}{"id": 2.0}}},
+			"item", []interface{}{map[string]interface{}{"id": 1.0}, map[string]interface{}{"id": 2.0}}},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", test.fields), test.name)
+		if !h.AssertSentCount(t, PORT_SUCCESS, len(test.expected)) {
+			continue
+		}
+		for i, expected := range test.expected {
+			assert.Equal(t, expected, (*h.Sent(PORT_SUCCESS)[i].Fields())[test.path], test.name)
+		}
+	}
+}
+
+func TestReceiveTimestampAndIndex(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"field": "items", "target": "item", "index_field": "split_index"}))
+
+	assert.Nil(t, h.Receive("test", map[string]interface{}{
+		"@timestamp": "2017-03-01T10:00:00Z",
+		"items":      []string{"a", "b"},
+	}))
+	if !h.AssertSentCount(t, PORT_SUCCESS, 2) {
+		return
+	}
+	for i, e := range h.Sent(PORT_SUCCESS) {
+		h.AssertField(t, e, "@timestamp", "2017-03-01T10:00:00Z")
+		h.AssertField(t, e, "split_index", i)
+		h.AssertField(t, e, "message", "test")
+	}
+}
+
+func TestReceiveMaxSplits(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"field": "items", "max_splits": 2}))
+
+	assert.Nil(t, h.Receive("test", map[string]interface{}{"items": []string{"a", "b", "c"}}))
+	h.AssertSentCount(t, PORT_SUCCESS, 0)
+	if h.AssertSentCount(t, PORT_FAILURE, 1) {
+		h.AssertTags(t, h.Sent(PORT_FAILURE)[0], "_splitoverflow")
+	}
+}
+
+func TestConfigureBadPattern(t *testing.T) {
+	assert.NotNil(t, ptesting.New(New()).Configure(map[string]interface{}{"pattern": "("}))
+}
+
+func TestReceiveMessage(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{}))
+
+	assert.Nil(t, h.Receive("a\nb", nil))
+	if h.AssertSentCount(t, PORT_SUCCESS, 2) {
+		h.AssertField(t, h.Sent(PORT_SUCCESS)[0], "message", "a")
+		h.AssertField(t, h.Sent(PORT_SUCCESS)[1], "message", "b")
+	}
+}
@@ -1,11 +1,24 @@
 package uuid
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/nu7hatch/gouuid"
 	"github.com/veino/processors"
 	"github.com/veino/veino"
 )
 
+const (
+	PORT_SUCCESS = processors.PORT_SUCCESS
+	PORT_FAILURE = processors.PORT_FAILURE
+)
+
 func New() veino.Processor {
 	return &processor{opt: &options{}}
 }
@@ -13,7 +26,8 @@ func New() veino.Processor {
 type processor struct {
 	processors.Base
 
-	opt *options
+	namespace *uuid.UUID
+	opt       *options
 }
 
 type options struct {
@@ -36,31 +50,193 @@ type options struct {
 	// Defaults to false (i.e. if the field is present, with ANY value, it won’t be overridden)
 	Overwrite bool
 
+	// The namespace of v5 UUIDs, a UUID or one of dns, url, oid and x500
+	// @default : "url"
+	Namespace string
+
+	// The fields v5 UUIDs and hashes are computed from, the same values give
+	// the same identifier. The name of a single string field is its value, as
+	// uuid5(namespace, "alice"), other values and several fields are named by
+	// their JSON encoding, as ["alice",42]. An event missing one of them is
+	// sent to the failure port
+	Source_fields []string
+
 	// Add a UUID to a field
 	Target string
+
+	// The kind of identifier :
+	// v4 a random UUID
+	// v5 a UUID named by the Source_fields values in Namespace
+	// hash the SHA-256 of the Source_fields values, in hexadecimal
+	// v7 a UUID ordered by the event's @timestamp
+	// ulid a ULID ordered by the event's @timestamp
+	// @default : "v4"
+	Type string `enum:"v4,v5,hash,v7,ulid"`
+}
+
+var namespaces = map[string]*uuid.UUID{
+	"dns":  uuid.NamespaceDNS,
+	"url":  uuid.NamespaceURL,
+	"oid":  uuid.NamespaceOID,
+	"x500": uuid.NamespaceX500,
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
 	p.opt.Overwrite = false
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	p.opt.Namespace = "url"
+	p.opt.Type = "v4"
+
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	if p.opt.Type == "v5" || p.opt.Type == "hash" {
+		if len(p.opt.Source_fields) == 0 {
+			return &processors.OptionError{Processor: "filter-uuid", Key: "source_fields", Reason: "required by type " + p.opt.Type}
+		}
+	}
+
+	if namespace, ok := namespaces[strings.ToLower(p.opt.Namespace)]; ok {
+		p.namespace = namespace
+	} else {
+		namespace, err := uuid.ParseHex(p.opt.Namespace)
+		if err != nil {
+			return &processors.OptionError{Processor: "filter-uuid", Key: "namespace", Reason: err.Error()}
+		}
+		p.namespace = namespace
+	}
+	return nil
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
+	id, err := p.newID(e)
+	if err != nil {
+		p.Fail(e, err, nil)
+		return nil
+	}
+
+	if !(p.opt.Overwrite == false && processors.FieldExists(e.Fields(), p.opt.Target) == true) {
+		processors.SetField(e.Fields(), p.opt.Target, id)
+	}
+
+	processors.ProcessCommonFields2(e.Fields(),
+		p.opt.Add_field,
+		p.opt.Add_tag,
+		p.opt.Remove_field,
+		p.opt.Remove_Tag,
+	)
+
+	p.Send(e, PORT_SUCCESS)
+	return nil
+}
+
+// newID returns the identifier of e, of kind Type
+func (p *processor) newID(e veino.IPacket) (string, error) {
+	switch p.opt.Type {
+	case "v5", "hash":
+		name, err := p.name(e)
+		if err != nil {
+			return "", err
+		}
+		if p.opt.Type == "hash" {
+			sum := sha256.Sum256(name)
+			return hex.EncodeToString(sum[:]), nil
+		}
+		id, err := uuid.NewV5(p.namespace, name)
+		if err != nil {
+			return "", err
+		}
+		return rfc4122(id), nil
+	case "v7":
+		return newV7(processors.Timestamp(e.Fields()))
+	case "ulid":
+		return newULID(processors.Timestamp(e.Fields()))
+	}
+
 	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return rfc4122(id), nil
+}
+
+// rfc4122 returns id with the RFC 4122 variant bits, 10, gouuid sets 01
+func rfc4122(id *uuid.UUID) string {
+	id[8] = id[8]&0x3f | 0x80
+	return id.String()
+}
 
-	if err == nil {
-		if !(p.opt.Overwrite == false && e.Fields().Exists(p.opt.Target) == true) {
-			e.Fields().SetValueForPath(id.String(), p.opt.Target)
+// name returns the value of the source field, as is when it is a string, or
+// the values of the Source_fields as a JSON array
+func (p *processor) name(e veino.IPacket) ([]byte, error) {
+	values := make([]interface{}, len(p.opt.Source_fields))
+	for i, field := range p.opt.Source_fields {
+		value, err := processors.GetField(e.Fields(), field)
+		if err != nil {
+			return nil, fmt.Errorf("source field %s not found", field)
 		}
+		values[i] = value
+	}
+	if len(values) == 1 {
+		if s, ok := values[0].(string); ok {
+			return []byte(s), nil
+		}
+		return json.Marshal(values[0])
+	}
+	return json.Marshal(values)
+}
 
-		processors.ProcessCommonFields2(e.Fields(),
-			p.opt.Add_field,
-			p.opt.Add_tag,
-			p.opt.Remove_field,
-			p.opt.Remove_Tag,
-		)
+// timeOrdered returns 16 bytes starting with the milliseconds since epoch of
+// t on 48 bits, followed by random bits
+func timeOrdered(t time.Time) ([16]byte, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return b, err
+	}
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
 	}
+	return b, nil
+}
 
-	p.Send(e, 0)
-	return nil
+// newV7 returns a version 7 UUID of t (RFC 9562)
+func newV7(t time.Time) (string, error) {
+	b, err := timeOrdered(t)
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	id, err := uuid.Parse(b[:])
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID of t, 26 characters of Crockford's base32
+func newULID(t time.Time) (string, error) {
+	b, err := timeOrdered(t)
+	if err != nil {
+		return "", err
+	}
+	// 128 bits as 26 groups of 5 bits, the first one holding 3 bits
+	id := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		bit := 128 - 5*(26-i)
+		var v uint
+		for j := 0; j < 5; j++ {
+			n := bit + j
+			if n < 0 {
+				continue
+			}
+			v |= uint(b[n/8]>>(7-uint(n%8))&1) << uint(4-j)
+		}
+		id[i] = crockford[v]
+	}
+	return string(id), nil
 }
@@ -0,0 +1,97 @@
+package uuid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+)
+
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      map[string]interface{}
+		fields    map[string]interface{}
+		untouched bool
+	}{
+		{"new field", map[string]interface{}{"target": "id"}, nil, false},
+		{"existing field", map[string]interface{}{"target": "id"}, map[string]interface{}{"id": "keep"}, true},
+		{"overwrite", map[string]interface{}{"target": "id", "overwrite": true}, map[string]interface{}{"id": "keep"}, false},
+	}
+
+	for _, test := range tests {
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.name) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", test.fields), test.name)
+		if !h.AssertSentCount(t, 0, 1) {
+			continue
+		}
+		id := h.Sent(0)[0].Fields().ValueOrEmptyForPathString("id")
+		if test.untouched {
+			assert.Equal(t, "keep", id, test.name)
+		} else {
+			assert.Len(t, id, 36, test.name)
+			assert.Contains(t, "89ab", id[19:20], "%s : RFC 4122 variant of %s", test.name, id)
+		}
+	}
+}
+
+func TestReceiveTypes(t *testing.T) {
+	fields := func() map[string]interface{} {
+		return map[string]interface{}{"@timestamp": "2017-03-01T10:00:00Z", "user": "alice", "n": 42.0}
+	}
+	tests := []struct {
+		conf   map[string]interface{}
+		prefix string
+		length int
+	}{
+		{map[string]interface{}{"type": "v5", "source_fields": []string{"user", "n"}}, "a4b62bee-4f50-57af-ac90-fbffe10b8953", 36},
+		{map[string]interface{}{"type": "v5", "source_fields": []string{"user", "n"}, "namespace": "dns"}, "f7446e09-50a0-512b-a8a8-80b291869515", 36},
+		{map[string]interface{}{"type": "v5", "source_fields": []string{"user", "n"}, "namespace": "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}, "f7446e09-50a0-512b-a8a8-80b291869515", 36},
+		{map[string]interface{}{"type": "v5", "source_fields": []string{"user"}}, "9750dcc2-516e-5ea0-8a26-54fa6ff6986b", 36},
+		{map[string]interface{}{"type": "hash", "source_fields": []string{"user", "n"}}, "16510c99787768c484d54d8e7c642006c74e835245d49cb833452cf4ec3d583b", 64},
+		{map[string]interface{}{"type": "hash", "source_fields": []string{"user"}}, "2bd806c97f0e00af1a1fc3328fa763a9269723c8db8fac4f93af71db186d6e90", 64},
+		{map[string]interface{}{"type": "v7"}, "015a894f-e900-7", 36},
+		{map[string]interface{}{"type": "ulid"}, "01BA4MZT80", 26},
+	}
+
+	for _, test := range tests {
+		test.conf["target"] = "id"
+		h := ptesting.New(New())
+		if !assert.Nil(t, h.Configure(test.conf), test.prefix) {
+			continue
+		}
+		assert.Nil(t, h.Receive("test", fields()), test.prefix)
+		assert.Nil(t, h.Receive("test", fields()), test.prefix)
+		if !h.AssertSentCount(t, PORT_SUCCESS, 2) {
+			continue
+		}
+		first := h.Sent(PORT_SUCCESS)[0].Fields().ValueOrEmptyForPathString("id")
+		second := h.Sent(PORT_SUCCESS)[1].Fields().ValueOrEmptyForPathString("id")
+		assert.Len(t, first, test.length, test.prefix)
+		assert.True(t, strings.HasPrefix(first, test.prefix), "%s : %s", test.prefix, first)
+		assert.True(t, strings.HasPrefix(second, test.prefix), "%s : %s", test.prefix, second)
+	}
+}
+
+func TestReceiveMissingSourceField(t *testing.T) {
+	h := ptesting.New(New())
+	assert.Nil(t, h.Configure(map[string]interface{}{"target": "id", "type": "hash", "source_fields": []string{"user"}}))
+	assert.Nil(t, h.Receive("test", nil))
+	if h.AssertSentCount(t, PORT_FAILURE, 1) {
+		h.AssertNoField(t, h.Sent(PORT_FAILURE)[0], "id")
+	}
+}
+
+func TestConfigureErrors(t *testing.T) {
+	for _, conf := range []map[string]interface{}{
+		{"target": "id", "type": "v6"},
+		{"target": "id", "type": "v5"},
+		{"target": "id", "type": "v5", "source_fields": []string{"user"}, "namespace": "nope"},
+	} {
+		assert.NotNil(t, ptesting.New(New()).Configure(conf), "%v", conf)
+	}
+}
@@ -3,11 +3,12 @@ package amqpinput
 import (
 	"crypto/tls"
 	"fmt"
+	"net/url"
 	"time"
 
-	"github.com/clbanning/mxj"
 	"github.com/streadway/amqp"
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 )
 
@@ -18,8 +19,9 @@ func New() veino.Processor {
 type processor struct {
 	processors.Base
 
-	opt  *options
-	conn *amqp.Connection
+	opt     *options
+	conn    *amqp.Connection
+	decoder codec.Decoder
 }
 
 type options struct {
@@ -46,7 +48,7 @@ type options struct {
 	// The codec used for input data. Default value is "json"
 	//
 	// Input codecs are a convenient method for decoding your data before it enters the input, without needing a separate filter in your Logfan pipeline.
-	Codec string `mapstructure:"codec"`
+	Codec codec.Codec `mapstructure:"codec"`
 
 	// Time in seconds to wait before retrying a connection. Default value is 1
 	ConnectRetryInterval int `mapstructure:"connect_retry_interval"`
@@ -73,9 +75,10 @@ type options struct {
 	// This is only relevant for direct or topic exchanges.
 	Key string `mapstructure:"key"`
 
-	// Not implemented! Enable the storage of message headers and properties in @metadata. Default value is false
+	// Enable the storage of message headers and properties in @metadata. Default value is false
 	//
-	// This may impact performance
+	// Headers are stored in [@metadata][rabbitmq_headers], properties in
+	// [@metadata][rabbitmq_properties]. This may impact performance
 	MetadataEnabled bool `mapstructure:"metadata_enabled"`
 
 	// Use queue passively declared, meaning it must already exist on the server. Default value is false
@@ -128,7 +131,7 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 		Ack:                  true,
 		AutoDelete:           false,
 		ConnectRetryInterval: 1,
-		Codec:                "json",
+		Codec:                codec.New("json"),
 		Durable:              false,
 		Exclusive:            false,
 		MetadataEnabled:      false, // Not implemented
@@ -144,7 +147,13 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 	}
 
 	p.opt = &defaults
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	var err error
+	p.decoder, err = p.opt.Codec.NewDecoder()
+	return err
 }
 
 func (p *processor) Start(e veino.IPacket) error {
@@ -153,24 +162,30 @@ func (p *processor) Start(e veino.IPacket) error {
 		for {
 			deliveries, err := p.consume()
 			if err == nil {
-				fmt.Println("Connected")
+				p.Logger.Info("connected", "host", p.opt.Host, "queue", p.opt.Queue)
 
 				for msg := range deliveries {
-					event := p.parse(msg.Body)
-					processors.AddFields(p.opt.AddField, event.Fields())
+					sent := true
+					for _, event := range p.parse(msg.Body) {
+						if p.opt.MetadataEnabled {
+							processors.SetMetadata(event.Fields(), "rabbitmq_headers", deliveryHeaders(msg))
+							processors.SetMetadata(event.Fields(), "rabbitmq_properties", deliveryProperties(msg))
+						}
+						processors.AddFields(p.opt.AddField, event.Fields())
+
+						if len(p.opt.Tags) > 0 {
+							processors.AddTags(p.opt.Tags, event.Fields())
+						}
 
-					if len(p.opt.Tags) > 0 {
-						processors.AddTags(p.opt.Tags, event.Fields())
+						sent = p.Send(event, 0) && sent
 					}
 
-					if p.Send(event, 0) {
-						if p.opt.Ack {
-							msg.Ack(false)
-						}
+					if sent && p.opt.Ack {
+						msg.Ack(false)
 					}
 				}
 			} else {
-				fmt.Println(err)
+				p.Logger.Error("can not consume", "host", p.opt.Host, "error", err)
 			}
 			time.Sleep(time.Duration(p.opt.ConnectRetryInterval) * time.Second)
 		}
@@ -181,16 +196,22 @@ func (p *processor) Start(e veino.IPacket) error {
 
 func (p *processor) setup() (*amqp.Connection, *amqp.Channel, error) {
 	scheme := map[bool]string{true: "amqps", false: "amqp"}[p.opt.SSL]
-	url := fmt.Sprintf("%s://%s:%s@%s:%d/%s", scheme, p.opt.User, p.opt.Password, p.opt.Host, p.opt.Port, p.opt.Vhost)
+	// credentials are escaped, and never logged
+	uri := url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(p.opt.User, p.opt.Password),
+		Host:   fmt.Sprintf("%s:%d", p.opt.Host, p.opt.Port),
+		Path:   "/" + p.opt.Vhost,
+	}
 
-	fmt.Println("Connecting to " + url)
+	p.Logger.Info("connecting", "host", p.opt.Host, "port", p.opt.Port, "vhost", p.opt.Vhost)
 
 	amqpConfig := amqp.Config{Heartbeat: time.Duration(p.opt.Heartbeat) * time.Second}
 	if p.opt.SSL {
 		amqpConfig.TLSClientConfig = &tls.Config{InsecureSkipVerify: !p.opt.VerifySSL}
 	}
 
-	conn, err := amqp.DialConfig(url, amqpConfig)
+	conn, err := amqp.DialConfig(uri.String(), amqpConfig)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -252,23 +273,62 @@ func (p *processor) consume() (<-chan amqp.Delivery, error) {
 	return deliveries, err
 }
 
-func (p *processor) parse(message []byte) veino.IPacket {
-	var event veino.IPacket
+// parse decodes a message body with the configured codec
+func (p *processor) parse(body []byte) []veino.IPacket {
+	events, err := p.decoder.Decode(body)
+	if err != nil {
+		return []veino.IPacket{p.NewPacket(string(body), nil)}
+	}
 
-	switch p.opt.Codec {
-	case "json":
-		fields, err := mxj.NewMapJson(message)
-		if err != nil {
-			event = p.NewPacket(string(message), nil)
-		} else {
-			event = p.NewPacket(string(message), fields)
-		}
+	packets := []veino.IPacket{}
+	for _, fields := range events {
+		packets = append(packets, p.NewPacket(string(body), fields))
+	}
+	return packets
+}
 
-	default:
-		event = p.NewPacket(string(message), nil)
+// deliveryHeaders returns the headers of msg
+func deliveryHeaders(msg amqp.Delivery) map[string]interface{} {
+	headers := map[string]interface{}{}
+	for k, v := range msg.Headers {
+		headers[k] = v
 	}
+	return headers
+}
 
-	return event
+// deliveryProperties returns the properties of msg which are set
+func deliveryProperties(msg amqp.Delivery) map[string]interface{} {
+	properties := map[string]interface{}{
+		"consumer-tag": msg.ConsumerTag,
+		"exchange":     msg.Exchange,
+		"routing-key":  msg.RoutingKey,
+		"redeliver":    msg.Redelivered,
+	}
+	for k, v := range map[string]string{
+		"app-id":           msg.AppId,
+		"content-encoding": msg.ContentEncoding,
+		"content-type":     msg.ContentType,
+		"correlation-id":   msg.CorrelationId,
+		"expiration":       msg.Expiration,
+		"message-id":       msg.MessageId,
+		"reply-to":         msg.ReplyTo,
+		"type":             msg.Type,
+		"user-id":          msg.UserId,
+	} {
+		if v != "" {
+			properties[k] = v
+		}
+	}
+	if msg.DeliveryMode != 0 {
+		properties["delivery-mode"] = int(msg.DeliveryMode)
+	}
+	if msg.Priority != 0 {
+		properties["priority"] = int(msg.Priority)
+	}
+	if !msg.Timestamp.IsZero() {
+		properties["timestamp"] = msg.Timestamp.Unix()
+	}
+	return properties
 }
 
 func (p *processor) Stop(e veino.IPacket) error {
@@ -40,7 +40,7 @@ func (p *processor) serve() error {
 			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
 				continue
 			}
-			// log.Printf("Error accepting connection: %v", err)
+			p.Logger.Warn("error accepting connection", "error", err)
 			continue
 		}
 
@@ -90,28 +90,57 @@ func (p *processor) clientServe(c net.Conn, wg *sync.WaitGroup, clientTerm chan
 	defer wg.Done()
 	defer c.Close()
 
-	// log.Printf("[%s] accepting lumberjack connection", c.RemoteAddr().String())
+	p.Logger.Debug("accepting lumberjack connection", "remote", c.RemoteAddr().String())
+
+	// each connection has its own decoder, as codecs like multiline keep a state
+	decoder, err := p.opt.Codec.NewDecoder()
+	if err != nil {
+		p.Logger.Error("codec error", "codec", p.opt.Codec, "error", err)
+		return
+	}
 
 	dataChan := make(chan map[string]interface{}, 3)
-	go NewParser(c, dataChan).Parse()
+	go NewParser(c, dataChan, p.Logger).Parse()
 
+	var last map[string]interface{}
 	for {
 		select {
 		case fields := <-dataChan:
 			if fields == nil {
-				// log.Printf("[%s] closing lumberjack connection", c.RemoteAddr().String())
+				p.Logger.Debug("closing lumberjack connection", "remote", c.RemoteAddr().String())
+				for _, decoded := range decoder.Flush() {
+					p.send(last, decoded)
+				}
 				return
 			}
-			msg := ""
-			if txt, ok := fields["message"]; ok {
-				msg = txt.(string)
+			last = fields
+			msg, _ := fields["message"].(string)
+			events, err := decoder.Decode([]byte(msg))
+			if err != nil {
+				p.Logger.Warn("codec error", "codec", p.opt.Codec, "remote", c.RemoteAddr().String(), "error", err)
+			}
+			for _, decoded := range events {
+				p.send(fields, decoded)
 			}
-			e := p.NewPacket(msg, fields)
-			processors.ProcessCommonFields(e.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
-			p.Send(e)
 		case <-clientTerm:
 			c.SetReadDeadline(time.Now())
 		}
 	}
 
 }
+
+// send merges fields decoded from the message into beat's event fields
+func (p *processor) send(fields map[string]interface{}, decoded map[string]interface{}) {
+	event := make(map[string]interface{}, len(fields)+len(decoded))
+	for k, v := range fields {
+		event[k] = v
+	}
+	for k, v := range decoded {
+		event[k] = v
+	}
+
+	msg, _ := event["message"].(string)
+	e := p.NewPacket(msg, event)
+	processors.ProcessCommonFields(e.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
+	p.Send(e)
+}
@@ -2,6 +2,7 @@ package beatsinput
 
 import (
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 )
 
@@ -18,7 +19,10 @@ type processor struct {
 
 type options struct {
 	Add_field map[string]interface{}
-	Codec     string
+
+	// The codec used to decode the message field of beats events
+	// @default : "plain"
+	Codec codec.Codec
 
 	// The number of seconds before we raise a timeout,
 	// this option is useful to control how much time to wait if something is blocking
@@ -61,7 +65,7 @@ type options struct {
 	//   if the clients doesn’t provide it the connection will be closed.
 	// This option need to be used with ssl_certificate_authorities and a defined list of CA.
 	// Value can be any of: none, peer, force_peer
-	Ssl_verify_mode string
+	Ssl_verify_mode string `enum:"none,peer,force_peer"`
 
 	// Add any number of arbitrary tags to your event
 	Tags []string
@@ -76,8 +80,15 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 	p.opt.Port = 5044
 	p.opt.Ssl = false
 	p.opt.Ssl_verify_mode = "none"
+	p.opt.Codec = codec.New("plain")
+
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
 
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	// fail at configuration time when codec is unknown or misconfigured
+	_, err := p.opt.Codec.NewDecoder()
+	return err
 }
 
 func (p *processor) Start(e veino.IPacket) error {
@@ -7,9 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"strings"
+
+	"github.com/veino/processors"
 )
 
 const (
@@ -23,12 +24,14 @@ type Parser struct {
 	out        chan map[string]interface{}
 	wlen, plen uint32
 	buffer     io.Reader
+	logger     processors.Logger
 }
 
-func NewParser(c net.Conn, dc chan map[string]interface{}) *Parser {
+func NewParser(c net.Conn, dc chan map[string]interface{}, logger processors.Logger) *Parser {
 	return &Parser{
-		Conn: c,
-		out:  dc,
+		Conn:   c,
+		out:    dc,
+		logger: logger.With("remote", c.RemoteAddr().String()),
 	}
 }
 
@@ -181,7 +184,7 @@ Read:
 			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
 				break Read
 			}
-			log.Printf("[%s] error reading %v", p.Conn.RemoteAddr().String(), err)
+			p.logger.Warn("error reading", "error", err)
 			break Read
 		}
 
@@ -194,17 +197,17 @@ Read:
 			seq, err := p.read()
 
 			if err != nil {
-				log.Printf("[%s] error parsing %v", p.Conn.RemoteAddr().String(), err)
+				p.logger.Warn("error parsing", "error", err)
 				break Read
 			}
 
 			if err := p.ack(seq); err != nil {
-				log.Printf("[%s] error acking %v", p.Conn.RemoteAddr().String(), err)
+				p.logger.Warn("error acking", "error", err)
 				break Read
 			}
 		default:
 			// This really shouldn't happen
-			log.Printf("[%s] Received unknown type (%s): %s", p.Conn.RemoteAddr().String(), b, err)
+			p.logger.Warn("received unknown type", "type", string(b))
 			break Read
 		}
 	}
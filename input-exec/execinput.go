@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 )
 
@@ -20,7 +21,7 @@ type options struct {
 	Args      []string
 	Add_field map[string]interface{}
 	Interval  string
-	Codec     string
+	Codec     codec.Codec // plain, the command output is handed to the codec
 	Tags      []string
 	Type      string
 }
@@ -28,12 +29,20 @@ type options struct {
 type processor struct {
 	processors.Base
 
-	opt *options
-	q   chan bool
+	opt     *options
+	q       chan bool
+	decoder codec.Decoder
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	p.opt.Codec = codec.New("plain")
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	var err error
+	p.decoder, err = p.opt.Codec.NewDecoder()
+	return err
 }
 
 func (p *processor) Tick(e veino.IPacket) error {
@@ -48,13 +57,21 @@ func (p *processor) Tick(e veino.IPacket) error {
 		return fmt.Errorf("Error while executing command '%s' (%s)", p.opt.Command, err.Error())
 	}
 
-	e.SetMessage(data)
-	e.Fields().SetValueForPath(data, "stdout")
-	e.Fields().SetValueForPath(p.opt.Command, "command")
-	e.Fields().SetValueForPath(strings.Join(p.opt.Args, ", "), "args")
+	events, err := p.decoder.Decode([]byte(data))
+	if err != nil {
+		return fmt.Errorf("Error while decoding command '%s' output with codec %s (%s)", p.opt.Command, p.opt.Codec, err.Error())
+	}
+
+	for _, fields := range events {
+		message, _ := fields["message"].(string)
+		ne := p.NewPacket(message, fields)
+		ne.Fields().SetValueForPath(data, "stdout")
+		ne.Fields().SetValueForPath(p.opt.Command, "command")
+		ne.Fields().SetValueForPath(strings.Join(p.opt.Args, ", "), "args")
 
-	processors.ProcessCommonFields(e.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
-	p.Send(e, 0)
+		processors.ProcessCommonFields(ne.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
+		p.Send(ne, 0)
+	}
 
 	return nil
 }
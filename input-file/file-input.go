@@ -2,13 +2,16 @@ package fileinput
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 
 	"github.com/hpcloud/tail"
@@ -33,17 +36,17 @@ type processor struct {
 
 type options struct {
 	Add_field              map[string]interface{}
-	Close_older            int // 3600
-	Codec                  string
-	Delimiter              string // \n
-	Discover_interval      int    // 15
+	Close_older            int         // 3600
+	Codec                  codec.Codec // plain
+	Delimiter              string      // \n
+	Discover_interval      int         // 15
 	Exclude                []string
 	Ignore_older           int // 86400
 	Max_open_files         string
 	Path                   []string `validate:"required"`
 	Sincedb_path           string
 	Sincedb_write_interval int    // 15
-	Start_position         string // end
+	Start_position         string `enum:"beginning,end"` // end
 	Stat_interval          int    // 1
 	Tags                   []string
 	Type                   string
@@ -51,15 +54,27 @@ type options struct {
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
 	p.opt.Start_position = "end"
-	p.opt.Sincedb_path = ".sincedb.json"
+	p.opt.Sincedb_path = "~/.sincedb.json"
 	p.opt.Sincedb_write_interval = 15
 	p.opt.Stat_interval = 1
+	p.opt.Codec = codec.New("plain")
 
-	if usr, err := user.Current(); err == nil {
-		p.opt.Sincedb_path = usr.HomeDir + "/" + p.opt.Sincedb_path
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
 	}
 
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	// ~ is the user's home directory, or the current directory when unknown
+	if strings.HasPrefix(p.opt.Sincedb_path, "~/") {
+		home := "."
+		if usr, err := user.Current(); err == nil {
+			home = usr.HomeDir
+		}
+		p.opt.Sincedb_path = filepath.Join(home, p.opt.Sincedb_path[2:])
+	}
+
+	// fail at configuration time when codec is unknown or misconfigured
+	_, err := p.opt.Codec.NewDecoder()
+	return err
 }
 func (p *processor) Start(e veino.IPacket) error {
 	watch.POLL_DURATION = time.Second * time.Duration(p.opt.Stat_interval)
@@ -133,7 +148,7 @@ func (p *processor) tailFile(path string, q chan bool) error {
 	}
 
 	t, err := tail.TailFile(path, tail.Config{
-		Logger: p.Logger,
+		Logger: log.New(processors.LogWriter(p.Logger, processors.DebugLevel), "", 0),
 		Location: &tail.SeekInfo{
 			Offset: since.Offset,
 			Whence: whence,
@@ -153,23 +168,43 @@ func (p *processor) tailFile(path string, q chan bool) error {
 
 	host, err := os.Hostname()
 	if err != nil {
-		p.Logger.Printf("can not get hostname : %s", err.Error())
+		p.Logger.Warn("can not get hostname", "error", err)
 	}
 
-	for line := range t.Lines {
+	// each file has its own decoder, as codecs like multiline keep a state
+	decoder, err := p.opt.Codec.NewDecoder()
+	if err != nil {
+		return err
+	}
 
-		e := p.NewPacket(line.Text, map[string]interface{}{
-			"host":       host,
-			"path":       path,
-			"@timestamp": line.Time.Format(veino.VeinoTime),
-		})
+	for line := range t.Lines {
+		events, err := decoder.Decode([]byte(line.Text))
+		if err != nil {
+			p.Logger.Warn("codec error", "codec", p.opt.Codec, "path", path, "error", err)
+		}
 
 		since.Offset, _ = t.Tell()
 
-		processors.ProcessCommonFields(e.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
-		p.Send(e)
+		p.sendEvents(events, host, path, line.Time)
 		p.checkSaveSinceDBInfos()
 	}
 
+	p.sendEvents(decoder.Flush(), host, path, time.Now())
+
 	return nil
 }
+
+func (p *processor) sendEvents(events []map[string]interface{}, host string, path string, t time.Time) {
+	for _, fields := range events {
+		fields["host"] = host
+		fields["path"] = path
+		if _, ok := fields["@timestamp"]; !ok {
+			fields["@timestamp"] = t.Format(veino.VeinoTime)
+		}
+		message, _ := fields["message"].(string)
+		e := p.NewPacket(message, fields)
+
+		processors.ProcessCommonFields(e.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
+		p.Send(e)
+	}
+}
@@ -25,22 +25,22 @@ func (p *processor) loadSinceDBInfos() (err error) {
 	p.sinceDBInfos = map[string]*sinceDBInfo{}
 
 	if p.opt.Sincedb_path == "" || p.opt.Sincedb_path == "/dev/null" {
-		p.Logger.Println("No valid sincedb path")
+		p.Logger.Info("no valid sincedb path, positions are not loaded")
 		return
 	}
 
 	if _, err := os.Stat(p.opt.Sincedb_path); os.IsNotExist(err) {
-		p.Logger.Printf("sincedb not found: %q", p.opt.Sincedb_path)
+		p.Logger.Info("sincedb not found", "path", p.opt.Sincedb_path)
 		return err
 	}
 
 	if raw, err = ioutil.ReadFile(p.opt.Sincedb_path); err != nil {
-		p.Logger.Printf("Read sincedb failed: %q\n%s", p.opt.Sincedb_path, err)
+		p.Logger.Error("read sincedb failed", "path", p.opt.Sincedb_path, "error", err)
 		return
 	}
 
 	if err = json.Unmarshal(raw, &p.sinceDBInfos); err != nil {
-		p.Logger.Printf("Unmarshal sincedb failed: %q\n%s", p.opt.Sincedb_path, err)
+		p.Logger.Error("unmarshal sincedb failed", "path", p.opt.Sincedb_path, "error", err)
 		return
 	}
 
@@ -55,14 +55,14 @@ func (p *processor) saveSinceDBInfos() (err error) {
 	p.sinceDBLastSaveTime = time.Now()
 
 	if p.opt.Sincedb_path == "" || p.opt.Sincedb_path == "/dev/null" {
-		p.Logger.Println("No valid sincedb path")
+		p.Logger.Debug("no valid sincedb path, positions are not saved")
 		return
 	}
 
 	p.sinceDBInfosMutex.Lock()
 	if raw, err = json.Marshal(p.sinceDBInfos); err != nil {
 		p.sinceDBInfosMutex.Unlock()
-		p.Logger.Printf("Marshal sincedb failed: %s", err)
+		p.Logger.Error("marshal sincedb failed", "error", err)
 		return
 	}
 	p.sinceDBInfosMutex.Unlock()
@@ -70,7 +70,7 @@ func (p *processor) saveSinceDBInfos() (err error) {
 	p.sinceDBLastInfosRaw = raw
 
 	if err = ioutil.WriteFile(p.opt.Sincedb_path, raw, 0664); err != nil {
-		p.Logger.Printf("Write sincedb failed: %q\n%s", p.opt.Sincedb_path, err)
+		p.Logger.Error("write sincedb failed", "path", p.opt.Sincedb_path, "error", err)
 		return
 	}
 
@@ -83,7 +83,7 @@ func (p *processor) checkSaveSinceDBInfos() (err error) {
 	)
 	if time.Since(p.sinceDBLastSaveTime) > time.Duration(p.opt.Sincedb_write_interval)*time.Second {
 		if raw, err = json.Marshal(p.sinceDBInfos); err != nil {
-			p.Logger.Printf("Marshal sincedb failed: %s", err)
+			p.Logger.Error("marshal sincedb failed", "error", err)
 			return
 		}
 		if bytes.Compare(raw, p.sinceDBLastInfosRaw) != 0 {
@@ -45,16 +45,16 @@ func (p *processor) Tick(e veino.IPacket) error {
 	case "GET":
 		resp, body, errs = p.request.Get(p.opt.Url).End()
 	default:
-		p.Logger.Printf("Method %s not implemented", p.opt.Method)
+		p.Logger.Error("method not implemented", "method", p.opt.Method)
 		return nil
 	}
 
 	if errs != nil {
-		p.Logger.Printf("while http requesting %s : %#v", p.opt.Url, errs)
+		p.Logger.Warn("http request failed", "url", p.opt.Url, "errors", errs)
 		return nil
 	}
 	if resp.StatusCode >= 400 {
-		p.Logger.Printf("http response code %s : %d (%s)", p.opt.Url, resp.StatusCode, resp.Status)
+		p.Logger.Warn("http request failed", "url", p.opt.Url, "code", resp.StatusCode, "status", resp.Status)
 		return nil
 	}
 
@@ -3,6 +3,7 @@ package imap_input
 import (
 	"encoding/json"
 
+	"github.com/veino/processors"
 	"github.com/veino/veino"
 )
 
@@ -10,10 +11,11 @@ type toJsonHandler struct {
 	packetFactory veino.PacketBuilder
 	send          veino.PacketSender
 	packet        veino.IPacket
+	logger        processors.Logger
 }
 
 func (hnd *toJsonHandler) Deliver(email string) error {
-	docJSON, _ := json.Marshal(getMsg(email))
+	docJSON, _ := json.Marshal(getMsg(email, hnd.logger))
 	e := hnd.packetFactory(string(docJSON), nil)
 	hnd.send(e, 0)
 	return nil
@@ -23,6 +25,6 @@ func (hnd *toJsonHandler) Describe() string {
 	return "To JSON Handler"
 }
 
-func newToJsonHandler(pFactory veino.PacketBuilder, packet veino.IPacket, sender veino.PacketSender) *toJsonHandler {
-	return &toJsonHandler{packetFactory: pFactory, packet: packet, send: sender}
+func newToJsonHandler(pFactory veino.PacketBuilder, packet veino.IPacket, sender veino.PacketSender, logger processors.Logger) *toJsonHandler {
+	return &toJsonHandler{packetFactory: pFactory, packet: packet, send: sender, logger: logger}
 }
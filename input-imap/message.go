@@ -3,10 +3,10 @@ package imap_input
 import (
 	"bytes"
 	"io/ioutil"
-	"log"
 	"net/mail"
 	"time"
 
+	"github.com/veino/processors"
 	"github.com/vjeantet/go.enmime"
 )
 
@@ -26,7 +26,7 @@ type Part struct {
 	Content     []byte
 }
 
-func getMsg(emailbody string) (m *Msg) {
+func getMsg(emailbody string, logger processors.Logger) (m *Msg) {
 	msg, _ := mail.ReadMessage(bytes.NewBufferString(emailbody))
 	m = &Msg{}
 	if enmime.IsMultipartMessage(msg) {
@@ -36,7 +36,7 @@ func getMsg(emailbody string) (m *Msg) {
 			//panic(err)
 			m.Text = err.Error()
 		} else {
-			log.Printf("mime.Text = %#v", mime.Text)
+			logger.Debug("multipart message parsed", "text", mime.Text)
 			m.Text = mime.Text
 			m.Html = mime.Html
 			msg.Header["Subject"] = []string{mime.GetHeader("Subject")}
@@ -7,36 +7,67 @@ import (
 )
 
 func New() veino.Processor {
-	return &processor{}
+	return &processor{opt: &options{}}
 }
 
 type processor struct {
 	processors.Base
 
+	opt     *options
 	config  *watch.Flags
 	watcher *watch.Watch
 }
 
+type options struct {
+	// IMAP server host
+	Host string `validate:"required"`
+
+	// IMAP server port
+	// @default : 993
+	Port uint
+
+	// Connect to the server with SSL
+	// @default : true
+	Ssl bool
+
+	// Mailbox to watch for new emails
+	// @default : "INBOX"
+	Mailbox string
+
+	// Username of the IMAP account
+	Username string `validate:"required"`
+
+	// Password of the IMAP account
+	Password string
+}
+
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
+	p.opt.Port = 993
+	p.opt.Ssl = true
+	p.opt.Mailbox = "INBOX"
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
 	p.config = watch.NewFlags()
-	p.config.Host = conf["host"].(string)
-	p.config.Port = uint(conf["port"].(float64))
-	p.config.Ssl = conf["ssl"].(bool)
-	p.config.Mailbox = conf["mailbox"].(string)
-	p.config.Password = conf["password"].(string)
-	p.config.Username = conf["username"].(string)
+	p.config.Host = p.opt.Host
+	p.config.Port = p.opt.Port
+	p.config.Ssl = p.opt.Ssl
+	p.config.Mailbox = p.opt.Mailbox
+	p.config.Password = p.opt.Password
+	p.config.Username = p.opt.Username
 	return nil
 }
 
 func (p *processor) Stop(e veino.IPacket) error {
-	p.Logger.Printf("imap input - closing connection...")
+	p.Logger.Info("closing connection")
 	p.watcher.Stop()
 
 	return nil
 }
 
 func (p *processor) Start(e veino.IPacket) error {
-	p.watcher = watch.New(p.config, newToJsonHandler(p.NewPacket, e, p.Send))
+	p.watcher = watch.New(p.config, newToJsonHandler(p.NewPacket, e, p.Send, p.Logger))
 	go p.watcher.Start()
 	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 )
 
@@ -26,18 +27,28 @@ type options struct {
 
 	// The codec used for input data. Input codecs are a convenient method for decoding
 	// your data before it enters the input, without needing a separate filter in your veino pipeline
-	Codec string
+	// Each line read from stdin is handed to the codec
+	// @default : "line"
+	Codec codec.Codec
 }
 
 type processor struct {
 	processors.Base
 
-	opt *options
-	q   chan bool
+	opt     *options
+	q       chan bool
+	decoder codec.Decoder
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	p.opt.Codec = codec.New("line")
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	var err error
+	p.decoder, err = p.opt.Codec.NewDecoder()
+	return err
 }
 func (p *processor) Start(e veino.IPacket) error {
 	p.q = make(chan bool)
@@ -56,20 +67,18 @@ func (p *processor) Start(e veino.IPacket) error {
 
 	host, err := os.Hostname()
 	if err != nil {
-		p.Logger.Printf("can not get hostname : %s", err.Error())
+		p.Logger.Warn("can not get hostname", "error", err)
 	}
 
 	go func(ch chan string) {
 		for {
 			select {
 			case stdin, _ := <-ch:
-
-				ne := p.NewPacket(stdin, map[string]interface{}{
-					"host": host,
-				})
-
-				processors.ProcessCommonFields(ne.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
-				p.Send(ne)
+				events, err := p.decoder.Decode([]byte(stdin))
+				if err != nil {
+					p.Logger.Warn("codec error", "codec", p.opt.Codec, "error", err)
+				}
+				p.sendEvents(events, host)
 
 			case <-time.After(5 * time.Second):
 
@@ -77,6 +86,7 @@ func (p *processor) Start(e veino.IPacket) error {
 
 			select {
 			case <-p.q:
+				p.sendEvents(p.decoder.Flush(), host)
 				close(p.q)
 				close(ch)
 				return
@@ -88,6 +98,19 @@ func (p *processor) Start(e veino.IPacket) error {
 	return nil
 }
 
+func (p *processor) sendEvents(events []map[string]interface{}, host string) {
+	for _, fields := range events {
+		if _, ok := fields["host"]; !ok {
+			fields["host"] = host
+		}
+		message, _ := fields["message"].(string)
+		ne := p.NewPacket(message, fields)
+
+		processors.ProcessCommonFields(ne.Fields(), p.opt.Add_field, p.opt.Tags, p.opt.Type)
+		p.Send(ne)
+	}
+}
+
 func (p *processor) Stop(e veino.IPacket) error {
 	p.q <- true
 	<-p.q
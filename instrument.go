@@ -0,0 +1,79 @@
+package processors
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/veino/processors/metrics"
+	"github.com/veino/veino"
+)
+
+// MetricsScope returns the metrics of the processor, labeled with its name,
+// it is nil until the processor is configured
+func (b *Base) MetricsScope() *metrics.Scope {
+	return b.Metrics
+}
+
+// Receive hands e to p, counting the events it receives and the errors
+// returned, and timing it, in the metrics of processors embedding Base.
+// Runtimes call it rather than p.Receive, events sent by processors embedding
+// Base are counted per port by Send.
+func Receive(p veino.Processor, e veino.IPacket) error {
+	d, ok := p.(describable)
+	if !ok || d.base().received == nil {
+		return p.Receive(e)
+	}
+
+	b := d.base()
+	b.received.Inc()
+	start := time.Now()
+	err := p.Receive(e)
+	b.receiveDuration.ObserveSince(start)
+	if err != nil {
+		b.receiveErrors.Inc()
+	}
+	return err
+}
+
+// countingSender wraps send to count packets sent, and failing to be sent, per
+// port
+func countingSender(send veino.PacketSender, scope *metrics.Scope) veino.PacketSender {
+	var mu sync.RWMutex
+	sent := map[int]*metrics.Counter{}
+	failed := map[int]*metrics.Counter{}
+
+	counters := func(port int) (*metrics.Counter, *metrics.Counter) {
+		mu.RLock()
+		s, ok := sent[port]
+		f := failed[port]
+		mu.RUnlock()
+		if ok {
+			return s, f
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		label := strconv.Itoa(port)
+		sent[port] = scope.Counter("processor_events_sent_total", "Events sent by the processor", "port", label)
+		failed[port] = scope.Counter("processor_send_failures_total", "Events the processor failed to send", "port", label)
+		return sent[port], failed[port]
+	}
+
+	return func(e veino.IPacket, ports ...int) bool {
+		ok := send(e, ports...)
+
+		if len(ports) == 0 {
+			ports = []int{0}
+		}
+		for _, port := range ports {
+			s, f := counters(port)
+			if ok {
+				s.Inc()
+			} else {
+				f.Inc()
+			}
+		}
+		return ok
+	}
+}
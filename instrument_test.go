@@ -0,0 +1,75 @@
+package processors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veino/processors/metrics"
+	"github.com/veino/veino"
+)
+
+type instrumentedOptions struct{}
+
+// echo sends received packets on port 1, and fails on empty messages
+type echo struct {
+	Base
+}
+
+func (p *echo) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
+	return p.ConfigureAndValidate(ctx, conf, &instrumentedOptions{})
+}
+
+func (p *echo) Receive(e veino.IPacket) error {
+	if e.Message() == "" {
+		return errors.New("empty message")
+	}
+	p.Send(e, 1)
+	return nil
+}
+
+func TestReceive(t *testing.T) {
+	counter := func(instance string, name string, labels ...string) float64 {
+		return metrics.Default.Scope("processor", "processors", "instance", instance).Counter(name, "", labels...).Value()
+	}
+	received := counter("echo", "processor_events_received_total")
+	errs := counter("echo", "processor_receive_errors_total")
+	sent := counter("echo", "processor_events_sent_total", "port", "1")
+	failed := counter("echo", "processor_send_failures_total", "port", "1")
+
+	p := &echo{}
+	assert.Nil(t, p.Configure(veino.ProcessorContext{}, map[string]interface{}{"id": "echo"}))
+
+	assert.Nil(t, Receive(p, NewPacket("hello", nil)))
+	assert.NotNil(t, Receive(p, NewPacket("", nil)))
+
+	assert.Equal(t, received+2, counter("echo", "processor_events_received_total"))
+	assert.Equal(t, errs+1, counter("echo", "processor_receive_errors_total"))
+	assert.Equal(t, sent, counter("echo", "processor_events_sent_total", "port", "1"), "the default sender discards packets")
+	assert.Equal(t, failed+1, counter("echo", "processor_send_failures_total", "port", "1"))
+
+	p = &echo{}
+	assert.Nil(t, p.Configure(veino.ProcessorContext{
+		PacketSender: func() veino.PacketSender {
+			return func(veino.IPacket, ...int) bool { return true }
+		},
+	}, map[string]interface{}{"id": "echo2"}))
+	assert.Nil(t, Receive(p, NewPacket("hello", nil)))
+	assert.Equal(t, 1.0, counter("echo2", "processor_events_sent_total", "port", "1"))
+	assert.Equal(t, sent, counter("echo", "processor_events_sent_total", "port", "1"), "instances have their own metrics")
+
+	assert.NotNil(t, Receive(&echo{}, NewPacket("", nil)), "unconfigured processors receive events uncounted")
+	assert.Equal(t, errs+1, counter("echo", "processor_receive_errors_total"))
+}
+
+func TestInstances(t *testing.T) {
+	first, second := &echo{}, &echo{}
+	assert.Nil(t, first.Configure(veino.ProcessorContext{}, map[string]interface{}{}))
+	assert.Nil(t, second.Configure(veino.ProcessorContext{}, map[string]interface{}{}))
+	assert.NotEqual(t, "", first.instance)
+	assert.NotEqual(t, first.instance, second.instance)
+
+	instance := first.instance
+	assert.Nil(t, first.Configure(veino.ProcessorContext{}, map[string]interface{}{}))
+	assert.Equal(t, instance, first.instance, "a reconfigured processor keeps its instance")
+}
@@ -0,0 +1,252 @@
+package processors
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jodaToken is a pattern letter repeated count times, or a literal text
+type jodaToken struct {
+	letter  byte
+	count   int
+	literal string
+}
+
+// parseJoda splits a Joda-Time pattern, as used by Logstash ("YYYY.MM.dd"),
+// into tokens. Letters within single quotes are literals, two single quotes
+// write a quote.
+func parseJoda(pattern string) []jodaToken {
+	tokens := []jodaToken{}
+	var literal bytes.Buffer
+	flush := func() {
+		if literal.Len() > 0 {
+			tokens = append(tokens, jodaToken{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\'':
+			if i+1 < len(pattern) && pattern[i+1] == '\'' {
+				literal.WriteByte('\'')
+				i++
+				continue
+			}
+			end := strings.IndexByte(pattern[i+1:], '\'')
+			if end < 0 {
+				literal.WriteString(pattern[i+1:])
+				i = len(pattern)
+				continue
+			}
+			literal.WriteString(pattern[i+1 : i+1+end])
+			i += end + 1
+		case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			flush()
+			count := 1
+			for i+1 < len(pattern) && pattern[i+1] == c {
+				count++
+				i++
+			}
+			tokens = append(tokens, jodaToken{letter: c, count: count})
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// JodaFormatter formats times with a Joda-Time pattern, parsed once
+type JodaFormatter struct {
+	tokens []jodaToken
+}
+
+// NewJodaFormatter returns the formatter of a Joda-Time pattern, as "YYYY.MM.dd"
+func NewJodaFormatter(pattern string) *JodaFormatter {
+	return &JodaFormatter{tokens: parseJoda(pattern)}
+}
+
+// Format formats t, in its location
+func (f *JodaFormatter) Format(t time.Time) string {
+	return formatJoda(t, f.tokens)
+}
+
+// formatJoda formats t with the tokens of a Joda-Time pattern
+func formatJoda(t time.Time, tokens []jodaToken) string {
+	var buf bytes.Buffer
+	for _, token := range tokens {
+		if token.letter == 0 {
+			buf.WriteString(token.literal)
+			continue
+		}
+
+		n := token.count
+		switch token.letter {
+		case 'G':
+			buf.WriteString("AD")
+		case 'C':
+			buf.WriteString(pad(t.Year()/100, n))
+		case 'Y', 'y':
+			if n == 2 {
+				buf.WriteString(pad(t.Year()%100, 2))
+			} else {
+				buf.WriteString(pad(t.Year(), n))
+			}
+		case 'x':
+			year, _ := t.ISOWeek()
+			if n == 2 {
+				buf.WriteString(pad(year%100, 2))
+			} else {
+				buf.WriteString(pad(year, n))
+			}
+		case 'w':
+			_, week := t.ISOWeek()
+			buf.WriteString(pad(week, n))
+		case 'M':
+			switch {
+			case n >= 4:
+				buf.WriteString(t.Month().String())
+			case n == 3:
+				buf.WriteString(t.Month().String()[:3])
+			default:
+				buf.WriteString(pad(int(t.Month()), n))
+			}
+		case 'd':
+			buf.WriteString(pad(t.Day(), n))
+		case 'D':
+			buf.WriteString(pad(t.YearDay(), n))
+		case 'e':
+			buf.WriteString(pad((int(t.Weekday())+6)%7+1, n))
+		case 'E':
+			if n >= 4 {
+				buf.WriteString(t.Weekday().String())
+			} else {
+				buf.WriteString(t.Weekday().String()[:3])
+			}
+		case 'a':
+			buf.WriteString(t.Format("PM"))
+		case 'H':
+			buf.WriteString(pad(t.Hour(), n))
+		case 'k':
+			buf.WriteString(pad((t.Hour()+23)%24+1, n))
+		case 'K':
+			buf.WriteString(pad(t.Hour()%12, n))
+		case 'h':
+			buf.WriteString(pad((t.Hour()+11)%12+1, n))
+		case 'm':
+			buf.WriteString(pad(t.Minute(), n))
+		case 's':
+			buf.WriteString(pad(t.Second(), n))
+		case 'S':
+			fraction := fmt.Sprintf("%09d", t.Nanosecond())
+			if n > 9 {
+				fraction += strings.Repeat("0", n-9)
+			}
+			buf.WriteString(fraction[:n])
+		case 'z':
+			buf.WriteString(t.Format("MST"))
+		case 'Z':
+			switch {
+			case n == 1:
+				buf.WriteString(t.Format("-0700"))
+			case n == 2:
+				buf.WriteString(t.Format("-07:00"))
+			default:
+				buf.WriteString(t.Location().String())
+			}
+		default:
+			buf.WriteString(strings.Repeat(string(token.letter), n))
+		}
+	}
+	return buf.String()
+}
+
+// pad writes i with at least n digits
+func pad(i int, n int) string {
+	s := strconv.Itoa(i)
+	if len(s) < n {
+		s = strings.Repeat("0", n-len(s)) + s
+	}
+	return s
+}
+
+// JodaLayout translates a Joda-Time pattern, as "dd/MMM/yyyy:HH:mm:ss Z", into
+// the equivalent layout for time.Parse. Month and day names are English,
+// fractions of second (S) must follow a "." or ",". Time zone IDs (ZZZ) and
+// week based fields are not supported
+func JodaLayout(pattern string) (string, error) {
+	var layout bytes.Buffer
+	for _, token := range parseJoda(pattern) {
+		if token.letter == 0 {
+			if strings.IndexAny(token.literal, "0123456789") >= 0 {
+				return "", fmt.Errorf("literal %q holds digits", token.literal)
+			}
+			layout.WriteString(token.literal)
+			continue
+		}
+
+		n := token.count
+		switch token.letter {
+		case 'Y', 'y':
+			layout.WriteString(pick(n == 2, "06", "2006"))
+		case 'M':
+			switch {
+			case n >= 4:
+				layout.WriteString("January")
+			case n == 3:
+				layout.WriteString("Jan")
+			default:
+				layout.WriteString(pick(n == 2, "01", "1"))
+			}
+		case 'd':
+			layout.WriteString(pick(n == 2, "02", "2"))
+		case 'D':
+			layout.WriteString(pick(n == 3, "002", "__2"))
+		case 'E':
+			layout.WriteString(pick(n >= 4, "Monday", "Mon"))
+		case 'a':
+			layout.WriteString("PM")
+		case 'H':
+			layout.WriteString("15")
+		case 'h':
+			layout.WriteString(pick(n == 2, "03", "3"))
+		case 'm':
+			layout.WriteString(pick(n == 2, "04", "4"))
+		case 's':
+			layout.WriteString(pick(n == 2, "05", "5"))
+		case 'S':
+			b := layout.Bytes()
+			if len(b) == 0 || (b[len(b)-1] != '.' && b[len(b)-1] != ',') {
+				return "", fmt.Errorf("fraction of second %s does not follow a . or ,", strings.Repeat("S", n))
+			}
+			// any number of digits is parsed
+			layout.WriteString(strings.Repeat("9", n))
+		case 'z':
+			layout.WriteString("MST")
+		case 'Z':
+			switch n {
+			case 1:
+				layout.WriteString("Z0700")
+			case 2:
+				layout.WriteString("Z07:00")
+			default:
+				return "", fmt.Errorf("time zone ID %s is not supported, use a time zone option", strings.Repeat("Z", n))
+			}
+		default:
+			return "", fmt.Errorf("pattern letter %c is not supported", token.letter)
+		}
+	}
+	return layout.String(), nil
+}
+
+func pick(cond bool, yes string, no string) string {
+	if cond {
+		return yes
+	}
+	return no
+}
@@ -0,0 +1,47 @@
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJodaLayout(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		value    string
+		expected string
+	}{
+		{"dd/MMM/yyyy:HH:mm:ss Z", "11/Dec/2013:00:01:45 -0800", "2013-12-11T08:01:45Z"},
+		{"yyyy-MM-dd'T'HH:mm:ss.SSSZZ", "2013-12-11T00:01:45.123-08:00", "2013-12-11T08:01:45.123Z"},
+		{"yyyy-MM-dd HH:mm:ss,SSS", "2013-12-11 00:01:45,5", "2013-12-11T00:01:45.5Z"},
+		{"EEE MMM d h:m:s a yy", "Wed Dec 1 3:4:5 PM 13", "2013-12-01T15:04:05Z"},
+		{"EEEE, MMMM dd yyyy", "Wednesday, December 11 2013", "2013-12-11T00:00:00Z"},
+		{"yyyy DDD", "2013 345", "2013-12-11T00:00:00Z"},
+		{"yyyyMMddHHmmssZ", "20131211000145Z", "2013-12-11T00:01:45Z"},
+	}
+
+	for _, test := range tests {
+		layout, err := JodaLayout(test.pattern)
+		if !assert.Nil(t, err, test.pattern) {
+			continue
+		}
+		parsed, err := time.Parse(layout, test.value)
+		if assert.Nil(t, err, test.pattern) {
+			assert.Equal(t, test.expected, parsed.UTC().Format(time.RFC3339Nano), test.pattern)
+		}
+	}
+}
+
+func TestJodaLayoutErrors(t *testing.T) {
+	for _, pattern := range []string{"HH:mm:ssSSS", "yyyy ZZZ", "xxxx-ww", "yyyy'1'"} {
+		_, err := JodaLayout(pattern)
+		assert.NotNil(t, err, pattern)
+	}
+}
+
+func TestJodaFormatter(t *testing.T) {
+	date := time.Date(2013, 12, 11, 0, 1, 45, 5e6, time.FixedZone("", -8*3600))
+	assert.Equal(t, "2013-12-11 00:01:45.005 -0800", NewJodaFormatter("yyyy-MM-dd HH:mm:ss.SSS Z").Format(date))
+}
@@ -0,0 +1,194 @@
+package processors
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	keystoreVersion    = 1
+	keystoreIterations = 100000
+)
+
+// Keystore is a SecretProvider reading secrets from a local file encrypted
+// with a password (AES-256-GCM, key derived with PBKDF2-SHA256).
+//
+//	ks, err := processors.CreateKeystore("veino.keystore", password)
+//	ks.Set("rabbitmq.password", "s3cr3t")
+//	err = ks.Save()
+//
+//	ks, err = processors.OpenKeystore("veino.keystore", password)
+//	processors.SetSecretProvider(ks)
+type Keystore struct {
+	mu       sync.RWMutex
+	path     string
+	password []byte
+	secrets  map[string]string
+}
+
+// keystoreFile is the content of a keystore file
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// CreateKeystore returns an empty keystore, saved to path by Save. It fails
+// when path exists
+func CreateKeystore(path string, password string) (*Keystore, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("keystore %s already exists", path)
+	}
+	return &Keystore{path: path, password: []byte(password), secrets: map[string]string{}}, nil
+}
+
+// OpenKeystore reads and decrypts the keystore at path
+func OpenKeystore(path string, password string) (*Keystore, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := keystoreFile{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("keystore %s : %s", path, err.Error())
+	}
+	if f.Version != keystoreVersion {
+		return nil, fmt.Errorf("keystore %s : unsupported version %d", path, f.Version)
+	}
+
+	gcm, err := keystoreCipher([]byte(password), f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("keystore %s : wrong password or corrupted file", path)
+	}
+
+	ks := &Keystore{path: path, password: []byte(password), secrets: map[string]string{}}
+	if err := json.Unmarshal(plain, &ks.secrets); err != nil {
+		return nil, fmt.Errorf("keystore %s : %s", path, err.Error())
+	}
+	return ks, nil
+}
+
+// Secret returns the secret name
+func (ks *Keystore) Secret(name string) (string, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	value, ok := ks.secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// Set sets the secret name to value
+func (ks *Keystore) Set(name string, value string) {
+	ks.mu.Lock()
+	ks.secrets[name] = value
+	ks.mu.Unlock()
+}
+
+// Remove removes the secret name
+func (ks *Keystore) Remove(name string) {
+	ks.mu.Lock()
+	delete(ks.secrets, name)
+	ks.mu.Unlock()
+}
+
+// Names returns the sorted names of secrets
+func (ks *Keystore) Names() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	names := make([]string, 0, len(ks.secrets))
+	for name := range ks.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the keystore to its file, readable by its owner only
+func (ks *Keystore) Save() error {
+	ks.mu.RLock()
+	plain, err := json.Marshal(ks.secrets)
+	ks.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	f := keystoreFile{Version: keystoreVersion, Iterations: keystoreIterations, Salt: make([]byte, 16)}
+	if _, err := io.ReadFull(rand.Reader, f.Salt); err != nil {
+		return err
+	}
+	gcm, err := keystoreCipher(ks.password, f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, f.Nonce); err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, plain, nil)
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ks.path, b, 0600)
+}
+
+func keystoreCipher(password []byte, salt []byte, iterations int) (cipher.AEAD, error) {
+	if len(password) == 0 {
+		return nil, errors.New("keystore password is empty")
+	}
+	if iterations <= 0 {
+		return nil, errors.New("keystore iterations must be positive")
+	}
+	block, err := aes.NewCipher(pbkdf2SHA256(password, salt, iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key of keyLen bytes from password (RFC 2898)
+func pbkdf2SHA256(password []byte, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := []byte{}
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		var counter [4]byte
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -1,9 +1,16 @@
 package processors
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync/atomic"
 )
 
 var (
@@ -14,8 +21,8 @@ var (
 	DiscardingLogger = log.New(ioutil.Discard, "", 0)
 )
 
-// Logger represents log.Logger functions from the standard library
-type logger interface {
+// StdLogger represents log.Logger functions from the standard library
+type StdLogger interface {
 	Fatal(v ...interface{})
 	Fatalf(format string, v ...interface{})
 	Fatalln(v ...interface{})
@@ -28,3 +35,166 @@ type logger interface {
 	Printf(format string, v ...interface{})
 	Println(v ...interface{})
 }
+
+// Level is the severity of a log entry
+type Level int32
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < DebugLevel || l > ErrorLevel {
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named s (debug, info, warn or error)
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return WarnLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %q", s)
+}
+
+var logLevel = int32(InfoLevel)
+
+// SetLogLevel sets the minimum level of logged entries, entries below are
+// dropped. Default level is InfoLevel
+func SetLogLevel(l Level) {
+	atomic.StoreInt32(&logLevel, int32(l))
+}
+
+// LogLevel returns the minimum level of logged entries
+func LogLevel() Level {
+	return Level(atomic.LoadInt32(&logLevel))
+}
+
+// Logger is a leveled and structured logger.
+//
+// Each entry is a message followed by key/value pairs
+//
+//	p.Logger.Warn("can not open file", "path", path, "error", err)
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+
+	// With returns a Logger adding keyvals to each entry
+	With(keyvals ...interface{}) Logger
+}
+
+// NewLogger returns a Logger writing entries as "level=... msg=... key=value"
+// lines to the standard logger l, keyvals are added to each entry
+func NewLogger(l StdLogger, keyvals ...interface{}) Logger {
+	if l == nil {
+		l = DefaultLogger
+	}
+	return &stdLogger{std: l, keyvals: keyvals}
+}
+
+// stdLogger adapts a StdLogger to Logger
+type stdLogger struct {
+	std     StdLogger
+	keyvals []interface{}
+}
+
+func (l *stdLogger) Debug(msg string, keyvals ...interface{}) { l.log(DebugLevel, msg, keyvals) }
+func (l *stdLogger) Info(msg string, keyvals ...interface{})  { l.log(InfoLevel, msg, keyvals) }
+func (l *stdLogger) Warn(msg string, keyvals ...interface{})  { l.log(WarnLevel, msg, keyvals) }
+func (l *stdLogger) Error(msg string, keyvals ...interface{}) { l.log(ErrorLevel, msg, keyvals) }
+
+func (l *stdLogger) With(keyvals ...interface{}) Logger {
+	all := make([]interface{}, 0, len(l.keyvals)+len(keyvals))
+	all = append(all, l.keyvals...)
+	return &stdLogger{std: l.std, keyvals: append(all, keyvals...)}
+}
+
+func (l *stdLogger) log(level Level, msg string, keyvals []interface{}) {
+	if level < LogLevel() {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("level=" + level.String())
+	writeKeyvals(&buf, l.keyvals)
+	buf.WriteString(" msg=" + quote(msg))
+	writeKeyvals(&buf, keyvals)
+	l.std.Print(buf.String())
+}
+
+func writeKeyvals(buf *bytes.Buffer, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprintf("%v", keyvals[i])
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		if err, ok := value.(error); ok && err != nil {
+			value = err.Error()
+		}
+		buf.WriteString(" " + key + "=" + quote(fmt.Sprintf("%v", value)))
+	}
+}
+
+// quote quotes s when it holds spaces, quotes or = signs, secrets are masked
+// before quoting
+func quote(s string) string {
+	s = maskSecrets(s)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// LogWriter returns a writer logging each written line with l at level, to
+// hand Logger to libraries expecting a *log.Logger or an io.Writer
+func LogWriter(l Logger, level Level) io.Writer {
+	return &logWriter{logger: l, level: level}
+}
+
+type logWriter struct {
+	logger Logger
+	level  Level
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		switch w.level {
+		case DebugLevel:
+			w.logger.Debug(line)
+		case WarnLevel:
+			w.logger.Warn(line)
+		case ErrorLevel:
+			w.logger.Error(line)
+		default:
+			w.logger.Info(line)
+		}
+	}
+	return len(p), nil
+}
+
+// processorName names a processor after the package of its options, as
+// "filter-grok"
+func processorName(rawVal interface{}) string {
+	t := reflect.TypeOf(rawVal)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.PkgPath() == "" {
+		return ""
+	}
+	return path.Base(t.PkgPath())
+}
@@ -0,0 +1,66 @@
+package processors
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veino/veino"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(log.New(&buf, "", 0), "processor", "filter-test")
+
+	l.Info("hello world", "count", 2, "error", errors.New("bad thing"))
+	assert.Equal(t, "level=info processor=filter-test msg=\"hello world\" count=2 error=\"bad thing\"\n", buf.String())
+
+	buf.Reset()
+	l.With("path", "/tmp/a").Warn("can not open", "odd")
+	assert.Equal(t, "level=warn processor=filter-test path=/tmp/a msg=\"can not open\" odd=(MISSING)\n", buf.String())
+}
+
+func TestLogLevel(t *testing.T) {
+	defer SetLogLevel(LogLevel())
+
+	var buf bytes.Buffer
+	l := NewLogger(log.New(&buf, "", 0))
+
+	l.Debug("hidden")
+	assert.Equal(t, "", buf.String(), "debug is below default level")
+
+	SetLogLevel(ErrorLevel)
+	l.Warn("hidden")
+	l.Error("shown")
+	assert.Equal(t, "level=error msg=shown\n", buf.String())
+
+	level, err := ParseLevel("WARNING")
+	assert.Nil(t, err)
+	assert.Equal(t, WarnLevel, level)
+	_, err = ParseLevel("verbose")
+	assert.NotNil(t, err)
+}
+
+func TestLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	std := log.New(LogWriter(NewLogger(log.New(&buf, "", 0)), WarnLevel), "", 0)
+	std.Printf("line %d", 1)
+	assert.Equal(t, "level=warn msg=\"line 1\"\n", buf.String())
+}
+
+func TestBaseLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := veino.ProcessorContext{Logger: func() *log.Logger { return log.New(&buf, "", 0) }}
+
+	opt := &struct{ Name string }{}
+	b := &Base{}
+	assert.Nil(t, b.ConfigureAndValidate(ctx, map[string]interface{}{}, opt))
+	b.Logger.Info("anonymous options")
+
+	type options struct{ Name string }
+	assert.Nil(t, b.ConfigureAndValidate(ctx, map[string]interface{}{}, &options{}))
+	b.Logger.Info("configured")
+	assert.Equal(t, "level=info msg=\"anonymous options\"\nlevel=info processor=processors msg=configured\n", buf.String())
+}
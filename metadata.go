@@ -0,0 +1,69 @@
+package processors
+
+import (
+	"github.com/clbanning/mxj"
+)
+
+// MetadataField holds values which travel with an event through the pipeline
+// but are never written by outputs : routing keys, raw headers, computed index
+// names...
+//
+// Its fields are read and written like any other, "[@metadata][key]", with the
+// field helpers, in templates "%{[@metadata][key]}" and in when conditions.
+const MetadataField = "@metadata"
+
+// Metadata returns the event's metadata, creating it when missing
+func Metadata(data *mxj.Map) map[string]interface{} {
+	if m, ok := (*data)[MetadataField].(map[string]interface{}); ok {
+		return m
+	}
+	if m, ok := (*data)[MetadataField].(mxj.Map); ok {
+		return m
+	}
+	m := map[string]interface{}{}
+	(*data)[MetadataField] = m
+	return m
+}
+
+// GetMetadata returns the value at ref within the event's metadata, as
+// GetField(data, "[@metadata]"+ref)
+func GetMetadata(data *mxj.Map, ref string) (interface{}, error) {
+	r, err := metadataReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(data)
+}
+
+// SetMetadata sets value at ref within the event's metadata
+func SetMetadata(data *mxj.Map, ref string, value interface{}) error {
+	r, err := metadataReference(ref)
+	if err != nil {
+		return err
+	}
+	return r.Set(data, value)
+}
+
+func metadataReference(ref string) (FieldReference, error) {
+	r, err := ParseFieldReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	return append(FieldReference{MetadataField}, r...), nil
+}
+
+// WithoutMetadata returns the fields to serialize for fields, without
+// metadata. fields is returned as is when it holds no metadata, otherwise a
+// shallow copy is returned and fields is left untouched
+func WithoutMetadata(fields map[string]interface{}) map[string]interface{} {
+	if _, ok := fields[MetadataField]; !ok {
+		return fields
+	}
+	out := make(map[string]interface{}, len(fields)-1)
+	for k, v := range fields {
+		if k != MetadataField {
+			out[k] = v
+		}
+	}
+	return out
+}
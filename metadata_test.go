@@ -0,0 +1,45 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/clbanning/mxj"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata(t *testing.T) {
+	fields := mxj.Map{"message": "hello"}
+
+	assert.Nil(t, SetMetadata(&fields, "[routing][key]", "logs.app"))
+	value, err := GetMetadata(&fields, "routing.key")
+	assert.Nil(t, err)
+	assert.Equal(t, "logs.app", value)
+
+	value, err = GetField(&fields, "[@metadata][routing][key]")
+	assert.Nil(t, err)
+	assert.Equal(t, "logs.app", value, "metadata should be reachable with field references")
+
+	Metadata(&fields)["index"] = "logs"
+	str := "%{[@metadata][index]}-%{message}"
+	Dynamic(&str, &fields)
+	assert.Equal(t, "logs-hello", str)
+
+	_, err = GetMetadata(&fields, "[unknow]")
+	assert.NotNil(t, err)
+	assert.NotNil(t, SetMetadata(&fields, "[bad", "value"))
+}
+
+func TestWithoutMetadata(t *testing.T) {
+	fields := map[string]interface{}{"message": "hello"}
+	assert.Equal(t, fields, WithoutMetadata(fields))
+
+	fields[MetadataField] = map[string]interface{}{"index": "logs"}
+	assert.Equal(t, map[string]interface{}{"message": "hello"}, WithoutMetadata(fields))
+	assert.Contains(t, fields, MetadataField, "fields should be left untouched")
+}
+
+func TestRemoveAllButFieldsKeepsMetadata(t *testing.T) {
+	fields := mxj.Map{"a": 1, "b": 2, MetadataField: map[string]interface{}{"index": "logs"}}
+	RemoveAllButFields([]string{"a"}, &fields)
+	assert.Equal(t, mxj.Map{"a": 1, MetadataField: map[string]interface{}{"index": "logs"}}, fields)
+}
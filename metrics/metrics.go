@@ -0,0 +1,257 @@
+// Package metrics holds counters, gauges and histograms of processors, and
+// exposes them in the Prometheus text format.
+//
+// Each metric is a family, identified by its name, of series identified by
+// label pairs.
+//
+//	received := metrics.Default.Counter("events_received_total", "Events received", "processor", "filter-grok")
+//	received.Inc()
+//
+//	http.Handle("/metrics", metrics.Handler(metrics.Default))
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default is the registry processors report to
+var Default = NewRegistry()
+
+// DefaultBuckets are latency buckets, in seconds, used by histograms created
+// without buckets
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+type kind string
+
+const (
+	counterKind   kind = "counter"
+	gaugeKind     kind = "gauge"
+	histogramKind kind = "histogram"
+)
+
+// Registry holds metric families
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    kind
+	buckets []float64
+	series  map[string]interface{}
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Counter returns the counter name with labels, labels are key/value pairs.
+// It panics when name is already registered with another type.
+func (r *Registry) Counter(name string, help string, labels ...string) *Counter {
+	return r.series(name, help, counterKind, nil, labels, func(*family) interface{} {
+		return &Counter{}
+	}).(*Counter)
+}
+
+// Gauge returns the gauge name with labels, labels are key/value pairs.
+// It panics when name is already registered with another type.
+func (r *Registry) Gauge(name string, help string, labels ...string) *Gauge {
+	return r.series(name, help, gaugeKind, nil, labels, func(*family) interface{} {
+		return &Gauge{}
+	}).(*Gauge)
+}
+
+// Histogram returns the histogram name with labels, labels are key/value
+// pairs. Buckets are upper bounds, DefaultBuckets are used when nil.
+// It panics when name is already registered with another type.
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return r.series(name, help, histogramKind, buckets, labels, func(f *family) interface{} {
+		return newHistogram(f.buckets)
+	}).(*Histogram)
+}
+
+func (r *Registry) series(name string, help string, k kind, buckets []float64, labels []string, create func(*family) interface{}) interface{} {
+	if len(labels)%2 != 0 {
+		panic(fmt.Sprintf("metrics : %s labels are not key/value pairs : %v", name, labels))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		sorted := append([]float64{}, buckets...)
+		sort.Float64s(sorted)
+		f = &family{name: name, help: help, kind: k, buckets: sorted, series: map[string]interface{}{}}
+		r.families[name] = f
+	}
+	if f.kind != k {
+		panic(fmt.Sprintf("metrics : %s is a %s, not a %s", name, f.kind, k))
+	}
+
+	key := labelString(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = create(f)
+		f.series[key] = s
+	}
+	return s
+}
+
+// Scope returns a Scope adding labels to each metric it creates
+func (r *Registry) Scope(labels ...string) *Scope {
+	return &Scope{registry: r, labels: labels}
+}
+
+// Scope creates metrics in a registry with a common set of labels, as the
+// processor name
+type Scope struct {
+	registry *Registry
+	labels   []string
+}
+
+// Counter returns the counter name with the scope labels and labels
+func (s *Scope) Counter(name string, help string, labels ...string) *Counter {
+	return s.registry.Counter(name, help, s.with(labels)...)
+}
+
+// Gauge returns the gauge name with the scope labels and labels
+func (s *Scope) Gauge(name string, help string, labels ...string) *Gauge {
+	return s.registry.Gauge(name, help, s.with(labels)...)
+}
+
+// Histogram returns the histogram name with the scope labels and labels
+func (s *Scope) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return s.registry.Histogram(name, help, buckets, s.with(labels)...)
+}
+
+func (s *Scope) with(labels []string) []string {
+	all := make([]string, 0, len(s.labels)+len(labels))
+	all = append(all, s.labels...)
+	return append(all, labels...)
+}
+
+// Counter is a value which only goes up
+type Counter struct {
+	bits uint64
+}
+
+// Inc adds 1 to the counter
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds v to the counter, v must not be negative
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	addFloat(&c.bits, v)
+}
+
+// Value returns the counter value
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
+
+// Gauge is a value which goes up and down
+type Gauge struct {
+	bits uint64
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Inc adds 1 to the gauge
+func (g *Gauge) Inc() { g.Add(1) }
+
+// Dec subtracts 1 from the gauge
+func (g *Gauge) Dec() { g.Add(-1) }
+
+// Add adds v to the gauge
+func (g *Gauge) Add(v float64) { addFloat(&g.bits, v) }
+
+// Value returns the gauge value
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe adds v to the histogram
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// ObserveSince adds the seconds elapsed since start to the histogram
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func addFloat(bits *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		new := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(bits, old, new) {
+			return
+		}
+	}
+}
+
+// labelString renders label pairs as {k1="v1",k2="v2"}, sorted by key
+func labelString(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := []string{}
+	for i := 0; i < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+"="+quoteLabel(labels[i+1]))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func quoteLabel(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	v = strings.Replace(v, `"`, `\"`, -1)
+	return `"` + v + `"`
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.Counter("events_total", "Events", "processor", "grok")
+	c.Inc()
+	c.Add(2)
+	c.Add(-1)
+	assert.Equal(t, 3.0, c.Value())
+	assert.True(t, c == r.Counter("events_total", "Events", "processor", "grok"), "same labels give the same counter")
+	assert.True(t, c != r.Counter("events_total", "Events", "processor", "date"))
+
+	g := r.Scope("processor", "grok").Gauge("queue_size", "Queue size")
+	g.Set(10)
+	g.Dec()
+	assert.Equal(t, 9.0, g.Value())
+
+	assert.Panics(t, func() { r.Gauge("events_total", "") }, "a counter can not be a gauge")
+	assert.Panics(t, func() { r.Counter("odd_total", "", "processor") }, "labels are pairs")
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	s := r.Scope("processor", "filter-grok")
+	s.Counter("events_sent_total", "Events sent", "port", "1").Add(2)
+	s.Counter("events_sent_total", "Events sent", "port", "0").Inc()
+	r.Gauge("up", "").Set(1)
+	h := s.Histogram("receive_seconds", "Receive\nduration", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	var buf bytes.Buffer
+	assert.Nil(t, r.WritePrometheus(&buf))
+	assert.Equal(t, `# HELP events_sent_total Events sent
+# TYPE events_sent_total counter
+events_sent_total{port="0",processor="filter-grok"} 1
+events_sent_total{port="1",processor="filter-grok"} 2
+# HELP receive_seconds Receive\nduration
+# TYPE receive_seconds histogram
+receive_seconds_bucket{processor="filter-grok",le="0.1"} 1
+receive_seconds_bucket{processor="filter-grok",le="1"} 2
+receive_seconds_bucket{processor="filter-grok",le="+Inf"} 3
+receive_seconds_sum{processor="filter-grok"} 2.55
+receive_seconds_count{processor="filter-grok"} 3
+# TYPE up gauge
+up 1
+`, buf.String())
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("hits_total", "", "path", `a"b`).Inc()
+
+	w := httptest.NewRecorder()
+	Handler(r).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4", w.Header().Get("Content-Type"))
+	assert.Equal(t, "# TYPE hits_total counter\nhits_total{path=\"a\\\"b\"} 1\n", w.Body.String())
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes every metric of the registry in the Prometheus text
+// exposition format (version 0.0.4)
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make([]*family, len(names))
+	for i, name := range names {
+		families[i] = r.families[name]
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		r.mu.Lock()
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		series := make(map[string]interface{}, len(f.series))
+		for _, key := range keys {
+			series[key] = f.series[key]
+		}
+		r.mu.Unlock()
+		sort.Strings(keys)
+
+		if f.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+		for _, key := range keys {
+			switch s := series[key].(type) {
+			case *Counter:
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, key, formatFloat(s.Value()))
+			case *Gauge:
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, key, formatFloat(s.Value()))
+			case *Histogram:
+				writeHistogram(bw, f.name, key, s)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func writeHistogram(w io.Writer, name string, key string, h *Histogram) {
+	h.mu.Lock()
+	counts := append([]uint64{}, h.counts...)
+	count, sum := h.count, h.sum
+	h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(key, "le", formatFloat(upper)), counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(key, "le", "+Inf"), count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, key, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, key, count)
+}
+
+// withLabel adds a label to a rendered label set
+func withLabel(key string, name string, value string) string {
+	label := name + "=" + quoteLabel(value)
+	if key == "" {
+		return "{" + label + "}"
+	}
+	return strings.TrimSuffix(key, "}") + "," + label + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	help = strings.Replace(help, `\`, `\\`, -1)
+	return strings.Replace(help, "\n", `\n`, -1)
+}
+
+// Handler returns an http.Handler serving the registry metrics in the
+// Prometheus text format
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := r.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ListenAndServe serves the Default registry metrics on /metrics at addr
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(Default))
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,366 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+// OptionError is an invalid option of a processor
+type OptionError struct {
+	// Processor is the name of the processor, as "filter-grok"
+	Processor string
+	// Key is the path of the option, as "codec.options" or "tags[1]"
+	Key string
+	// Reason tells what is wrong with the option
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	if e.Processor == "" {
+		return fmt.Sprintf("%s : %s", e.Key, e.Reason)
+	}
+	return fmt.Sprintf("%s : %s : %s", e.Processor, e.Key, e.Reason)
+}
+
+// OptionErrors lists the invalid options of a processor
+type OptionErrors []*OptionError
+
+func (e OptionErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// decodeOptions sets the options of rawVal from conf, one key at a time to
+// report each invalid key. Unknown keys are errors, with a suggestion when a
+// known key looks alike
+func decodeOptions(processor string, conf map[string]interface{}, rawVal interface{}) error {
+	v := reflect.ValueOf(rawVal)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%s : options must be set in a struct, got %T", processor, rawVal)
+	}
+
+	known := map[string]bool{}
+	names := []string{}
+	for _, f := range optionFields(v.Type()) {
+		known[strings.ToLower(optionName(f))] = true
+		names = append(names, optionName(f))
+	}
+
+	keys := make([]string, 0, len(conf))
+	for key := range conf {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	errs := OptionErrors{}
+	for _, key := range keys {
+		if !known[strings.ToLower(key)] {
+			errs = append(errs, unknownOption(processor, key, names))
+			continue
+		}
+
+		metadata := &mapstructure.Metadata{}
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(textUnmarshalerHook, weakTypeHook),
+			Metadata:   metadata,
+			Result:     rawVal,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := decoder.Decode(map[string]interface{}{key: conf[key]}); err != nil {
+			errs = append(errs, decodeErrors(processor, key, err)...)
+			continue
+		}
+		for _, unused := range metadata.Unused {
+			// mapstructure names the path after the struct field, as "Codec.nme"
+			if strings.HasPrefix(strings.ToLower(unused), strings.ToLower(key)) {
+				unused = key + unused[len(key):]
+			}
+			errs = append(errs, unknownOption(processor, unused, nestedOptionNames(v.Type(), unused)))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// decodeErrors turns mapstructure errors, as "'tags[1]' expected type
+// 'string', got ...", into OptionErrors on their key path
+func decodeErrors(processor string, key string, err error) OptionErrors {
+	messages := []string{err.Error()}
+	if e, ok := err.(*mapstructure.Error); ok {
+		messages = e.Errors
+	}
+	sort.Strings(messages)
+
+	errs := OptionErrors{}
+	for _, message := range messages {
+		e := &OptionError{Processor: processor, Key: key, Reason: message}
+		if strings.HasPrefix(message, "'") {
+			if end := strings.Index(message[1:], "'"); end >= 0 {
+				path := message[1 : end+1]
+				if strings.HasPrefix(strings.ToLower(path), strings.ToLower(key)) {
+					e.Key = path
+					e.Reason = strings.TrimLeft(message[end+2:], ": ")
+				}
+			}
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// validationErrors turns validator errors into OptionErrors
+func validationErrors(processor string, rawVal interface{}, err error) error {
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	t := reflect.TypeOf(rawVal)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields := make([]string, 0, len(verrs))
+	for field := range verrs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	errs := OptionErrors{}
+	for _, field := range fields {
+		verr := verrs[field]
+		key := verr.Field
+		if f, ok := t.FieldByName(verr.Field); ok {
+			key = optionName(f)
+		}
+		reason := "is " + verr.Tag
+		if verr.Param != "" {
+			reason += " " + verr.Param
+		}
+		errs = append(errs, &OptionError{Processor: processor, Key: key, Reason: reason})
+	}
+	return errs
+}
+
+func unknownOption(processor string, key string, names []string) *OptionError {
+	e := &OptionError{Processor: processor, Key: key, Reason: "unknown option"}
+	last := key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		last = key[i+1:]
+	}
+	if suggestion := closest(last, names); suggestion != "" {
+		e.Reason += fmt.Sprintf(", did you mean %q ?", suggestion)
+	}
+	return e
+}
+
+// nestedOptionNames returns the option names of the struct at the dotted path
+// parent of key, as "codec" for "codec.nme" or "operations[0]" for
+// "operations[0].nme"
+func nestedOptionNames(t reflect.Type, key string) []string {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		index := strings.Index(part, "[")
+		if index >= 0 {
+			part = part[:index]
+		}
+		found := false
+		for _, f := range optionFields(t) {
+			if strings.EqualFold(optionName(f), part) {
+				t, found = f.Type, true
+				break
+			}
+		}
+		if found && index >= 0 && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			t = t.Elem()
+		}
+		if !found || t.Kind() != reflect.Struct {
+			return nil
+		}
+	}
+
+	names := []string{}
+	for _, f := range optionFields(t) {
+		names = append(names, optionName(f))
+	}
+	return names
+}
+
+// closest returns the name nearest to key, when it is near enough to be a
+// typo of key
+func closest(key string, names []string) string {
+	best, bestDistance := "", -1
+	for _, name := range names {
+		d := levenshtein(strings.ToLower(key), strings.ToLower(name))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+
+	max := len(key) / 3
+	if max < 2 {
+		max = 2
+	}
+	if bestDistance < 0 || bestDistance > max {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// weakTypeHook converts values where no information is lost :
+//
+//	"5" to 5, 5.0 to 5, "1.5" to 1.5, "true" to true
+//	5 or true to "5" or "true"
+//	"30s" to a time.Duration, numbers are seconds
+//	a single value to an array of this value, as a hash to an array of hashes
+func weakTypeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to == durationType {
+		return toDuration(data)
+	}
+
+	switch to.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := data.(type) {
+		case string:
+			i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an integer", v)
+			}
+			return i, nil
+		case float64:
+			return floatToInt(v)
+		case float32:
+			return floatToInt(float64(v))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v := data.(type) {
+		case string:
+			i, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a positive integer", v)
+			}
+			return i, nil
+		case float64:
+			if v < 0 {
+				return nil, fmt.Errorf("%v is not a positive integer", v)
+			}
+			return floatToInt(v)
+		}
+	case reflect.Float32, reflect.Float64:
+		if v, ok := data.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", v)
+			}
+			return f, nil
+		}
+	case reflect.Bool:
+		if v, ok := data.(string); ok {
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a boolean", v)
+			}
+			return b, nil
+		}
+	case reflect.String:
+		switch v := data.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int, int64, bool:
+			return fmt.Sprintf("%v", v), nil
+		}
+	case reflect.Slice:
+		switch from.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+			return []interface{}{data}, nil
+		case reflect.Map:
+			if to.Elem().Kind() == reflect.Map {
+				return []interface{}{data}, nil
+			}
+		}
+	}
+	return data, nil
+}
+
+func floatToInt(f float64) (interface{}, error) {
+	if f != math.Trunc(f) {
+		return nil, fmt.Errorf("%v is not an integer", f)
+	}
+	return int64(f), nil
+}
+
+func toDuration(data interface{}) (interface{}, error) {
+	switch v := data.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return time.Duration(f * float64(time.Second)), nil
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a duration, as \"30s\" or \"1m30s\"", v)
+		}
+		return d, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	}
+	return nil, fmt.Errorf("%v is not a duration, as \"30s\" or \"1m30s\"", data)
+}
@@ -0,0 +1,104 @@
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veino/veino"
+)
+
+type server struct {
+	Name    string                 `mapstructure:"name"`
+	Options map[string]interface{} `mapstructure:"options"`
+}
+
+type typedOptions struct {
+	Add_field map[string]interface{}
+	Port      int           `mapstructure:"port"`
+	Ratio     float64       `mapstructure:"ratio"`
+	Enabled   bool          `mapstructure:"enabled"`
+	Label     string        `mapstructure:"label"`
+	Tags      []string      `mapstructure:"tags"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+	Server    server        `mapstructure:"server"`
+	Host      string        `mapstructure:"host" validate:"required"`
+}
+
+func configure(conf map[string]interface{}) (*typedOptions, error) {
+	opt := &typedOptions{}
+	err := (&Base{}).ConfigureAndValidate(veino.ProcessorContext{}, conf, opt)
+	return opt, err
+}
+
+func TestWeakTypes(t *testing.T) {
+	opt, err := configure(map[string]interface{}{
+		"host":    "localhost",
+		"port":    "5044",
+		"ratio":   "0.5",
+		"enabled": "true",
+		"label":   12.5,
+		"tags":    "single",
+		"timeout": "1m30s",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 5044, opt.Port)
+	assert.Equal(t, 0.5, opt.Ratio)
+	assert.Equal(t, true, opt.Enabled)
+	assert.Equal(t, "12.5", opt.Label)
+	assert.Equal(t, []string{"single"}, opt.Tags)
+	assert.Equal(t, 90*time.Second, opt.Timeout)
+
+	opt, err = configure(map[string]interface{}{"host": "localhost", "port": 80.0, "timeout": 2.0})
+	assert.Nil(t, err)
+	assert.Equal(t, 80, opt.Port)
+	assert.Equal(t, 2*time.Second, opt.Timeout, "numbers are seconds")
+}
+
+func TestOptionErrors(t *testing.T) {
+	tests := []struct {
+		conf     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"host": "h", "prot": 80},
+			`processors : prot : unknown option, did you mean "port" ?`},
+		{map[string]interface{}{"host": "h", "whatever": 80},
+			`processors : whatever : unknown option`},
+		{map[string]interface{}{"host": "h", "port": "eighty"},
+			`processors : port : "eighty" is not an integer`},
+		{map[string]interface{}{"host": "h", "port": 80.5},
+			`processors : port : 80.5 is not an integer`},
+		{map[string]interface{}{"host": "h", "timeout": "soon"},
+			`processors : timeout : "soon" is not a duration, as "30s" or "1m30s"`},
+		{map[string]interface{}{"host": "h", "tags": []interface{}{"a", map[string]interface{}{}}},
+			`processors : tags[1] : expected type 'string', got unconvertible type 'map[string]interface {}'`},
+		{map[string]interface{}{"host": "h", "server": map[string]interface{}{"nme": "a"}},
+			`processors : server.nme : unknown option, did you mean "name" ?`},
+		{map[string]interface{}{},
+			`processors : host : is required`},
+	}
+
+	for _, test := range tests {
+		_, err := configure(test.conf)
+		if assert.NotNil(t, err, test.expected) {
+			assert.Equal(t, test.expected, err.Error())
+		}
+	}
+}
+
+func TestOptionErrorsAreAllReported(t *testing.T) {
+	_, err := configure(map[string]interface{}{"host": "h", "prot": 80, "enabled": "maybe"})
+	errs, ok := err.(OptionErrors)
+	if assert.True(t, ok) && assert.Len(t, errs, 2) {
+		assert.Equal(t, "enabled", errs[0].Key)
+		assert.Equal(t, "prot", errs[1].Key)
+	}
+}
+
+func TestOptionsCase(t *testing.T) {
+	opt, err := configure(map[string]interface{}{"Host": "h", "ADD_FIELD": map[string]interface{}{"a": "b"}})
+	assert.Nil(t, err, "option names should not be case sensitive")
+	assert.Equal(t, map[string]interface{}{"a": "b"}, opt.Add_field)
+}
@@ -2,7 +2,6 @@ package elasticsearch
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/veino/processors"
 	"github.com/veino/veino"
@@ -20,6 +19,7 @@ type processor struct {
 
 	client *elastic.Client
 	opt    *options
+	index  *processors.Template
 }
 
 type options struct {
@@ -29,19 +29,29 @@ type options struct {
 	Port     int
 	User     string
 	Password string
+	// The index to write events to, it can be dynamic using the %{foo} and
+	// %{+YYYY.MM.dd} syntax
+	// @default : "logstash-%{+YYYY.MM.dd}"
+	Index string
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
 	p.opt.Protocol = "http"
 	p.opt.Port = 9200
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	p.opt.Index = "logstash-%{+YYYY.MM.dd}"
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	var err error
+	p.index, err = processors.NewTemplate(p.opt.Index)
+	return err
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-	t := time.Now()
-	index := fmt.Sprintf("logstash-%d.%02d.%02d", t.Year(), t.Month(), t.Day())
+	index := p.index.Render(e.Fields())
 	// Add a document to the index
-	data := e.Fields()
+	data := processors.WithoutMetadata(*e.Fields())
 	_, err := p.client.Index().
 		Index(index).
 		Type("logs").
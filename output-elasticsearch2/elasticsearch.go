@@ -1,11 +1,12 @@
 package elasticsearch2
 
 import (
+	"bytes"
 	"fmt"
 	"time"
 
-	"github.com/jehiah/go-strftime"
 	"github.com/veino/processors"
+	"github.com/veino/processors/metrics"
 	"github.com/veino/veino"
 	"gopkg.in/olivere/elastic.v3"
 )
@@ -22,6 +23,12 @@ type processor struct {
 	bulkProcessor *elastic.BulkProcessor
 	client        *elastic.Client
 	opt           *options
+	index         *processors.Template
+	documentType  *processors.Template
+
+	bulkRequests *metrics.Counter
+	bulkFailures *metrics.Counter
+	failedEvents *metrics.Counter
 }
 
 type options struct {
@@ -50,9 +57,11 @@ type options struct {
 	// This helps keep both fast and slow log streams moving along in near-real-time.
 	IdleFlushTime int `mapstructure:"idle_flush_time"`
 
-	// The index to write events to. Default value is "logstash-%Y.%m.%d"
+	// The index to write events to. Default value is "logstash-%{+YYYY.MM.dd}"
 	//
-	// This can be dynamic using the %{foo} syntax and strftime syntax (see http://strftime.org/).
+	// This can be dynamic using the %{foo} syntax, and %{+YYYY.MM.dd} to format
+	// the event's @timestamp. The former strftime syntax, as "logstash-%Y.%m.%d",
+	// is still supported.
 	// The default value will partition your indices by day.
 	Index string `mapstructure:"index"`
 
@@ -83,28 +92,81 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 		FlushSize:     5242880,
 		Host:          "localhost",
 		IdleFlushTime: 1,
-		Index:         "logstash-%Y.%m.%d",
+		Index:         "logstash-%{+YYYY.MM.dd}",
 		Path:          "/",
 		Port:          9200,
 		SSL:           false,
 		Workers:       1,
 	}
 	p.opt = &defaults
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	p.bulkRequests = p.Metrics.Counter("elasticsearch_bulk_requests_total", "Bulk requests sent to elasticsearch")
+	p.bulkFailures = p.Metrics.Counter("elasticsearch_bulk_failures_total", "Bulk requests which failed")
+	p.failedEvents = p.Metrics.Counter("elasticsearch_failed_events_total", "Events elasticsearch failed to index")
+
+	index, err := strftimeToTemplate(p.opt.Index)
+	if err != nil {
+		return &processors.OptionError{Processor: "output-elasticsearch2", Key: "index", Reason: err.Error()}
+	}
+	if p.index, err = processors.NewTemplate(index); err != nil {
+		return err
+	}
+	p.documentType, err = processors.NewTemplate(p.opt.DocumentType)
+	return err
 }
 
-func (p *processor) Receive(e veino.IPacket) error {
-	name := p.opt.Index
-	processors.Dynamic(&name, e.Fields())
-	index := strftime.Format(name, time.Now())
+// strftimeDirectives are the Joda-Time patterns of strftime directives
+var strftimeDirectives = map[byte]string{
+	'Y': "YYYY", 'y': "yy", 'm': "MM", 'd': "dd", 'e': "d", 'j': "DDD",
+	'H': "HH", 'I': "hh", 'M': "mm", 'S': "ss", 'p': "a",
+	'b': "MMM", 'B': "MMMM", 'a': "EEE", 'A': "EEEE", 's': "%s",
+}
 
-	documentType := p.opt.DocumentType
-	processors.Dynamic(&documentType, e.Fields())
+// strftimeToTemplate replaces the strftime directives of index, as "%Y", with
+// the template references formatting the event's @timestamp, as "%{+YYYY}"
+func strftimeToTemplate(index string) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(index); i++ {
+		if index[i] != '%' || i+1 == len(index) {
+			buf.WriteByte(index[i])
+			continue
+		}
+		if index[i+1] == '{' {
+			// a template reference, kept as is
+			end := bytes.IndexByte([]byte(index[i:]), '}')
+			if end < 0 {
+				end = len(index) - i - 1
+			}
+			buf.WriteString(index[i : i+end+1])
+			i += end
+			continue
+		}
+
+		i++
+		if index[i] == '%' {
+			buf.WriteByte('%')
+			continue
+		}
+		pattern, ok := strftimeDirectives[index[i]]
+		if !ok {
+			return "", fmt.Errorf("unknown strftime directive %%%c in %s, use %%{+YYYY.MM.dd}", index[i], index)
+		}
+		buf.WriteString("%{+" + pattern + "}")
+	}
+	return buf.String(), nil
+}
+
+func (p *processor) Receive(e veino.IPacket) error {
+	index := p.index.Render(e.Fields())
+	documentType := p.documentType.Render(e.Fields())
 
 	event := elastic.NewBulkIndexRequest().
 		Index(index).
 		Type(documentType).
-		Doc(e.Fields())
+		Doc(processors.WithoutMetadata(*e.Fields()))
 
 	p.bulkProcessor.Add(event)
 
@@ -128,11 +190,31 @@ func (p *processor) Start(e veino.IPacket) (err error) {
 		BulkActions(p.opt.FlushCount).
 		BulkSize(p.opt.FlushSize).
 		FlushInterval(time.Duration(p.opt.IdleFlushTime) * time.Second).
+		After(p.afterBulk).
 		Do()
 
 	return err
 }
 
+// afterBulk reports the outcome of each bulk request
+func (p *processor) afterBulk(executionId int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+	p.bulkRequests.Inc()
+	if err != nil {
+		p.bulkFailures.Inc()
+		p.failedEvents.Add(float64(len(requests)))
+		p.Logger.Error("bulk request failed", "events", len(requests), "error", err)
+		return
+	}
+	if failed := response.Failed(); len(failed) > 0 {
+		p.failedEvents.Add(float64(len(failed)))
+		reason := ""
+		if failed[0].Error != nil {
+			reason = failed[0].Error.Reason
+		}
+		p.Logger.Warn("events not indexed", "events", len(failed), "reason", reason)
+	}
+}
+
 func (p *processor) Stop(e veino.IPacket) error {
 	p.bulkProcessor.Close()
 	return nil
@@ -0,0 +1,42 @@
+package elasticsearch2
+
+import (
+	"testing"
+
+	"github.com/clbanning/mxj"
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+)
+
+func TestStrftimeToTemplate(t *testing.T) {
+	tests := []struct {
+		index    string
+		expected string
+	}{
+		{"logstash-%{+YYYY.MM.dd}", "logstash-%{+YYYY.MM.dd}"},
+		{"logstash-%Y.%m.%d", "logstash-%{+YYYY}.%{+MM}.%{+dd}"},
+		{"%{type}-%Y%%", "%{type}-%{+YYYY}%"},
+		{"%{+%s}-%H", "%{+%s}-%{+HH}"},
+	}
+	for _, test := range tests {
+		index, err := strftimeToTemplate(test.index)
+		assert.Nil(t, err, test.index)
+		assert.Equal(t, test.expected, index, test.index)
+	}
+
+	_, err := strftimeToTemplate("logs-%Q")
+	assert.NotNil(t, err)
+}
+
+func TestIndex(t *testing.T) {
+	p := New().(*processor)
+	assert.Nil(t, ptesting.New(p).Configure(map[string]interface{}{"index": "%{tag}-%Y.%m.%d"}))
+
+	fields := mxj.Map{"tag": "50%off", "@timestamp": "2016-06-01T22:03:04Z"}
+	assert.Equal(t, "50%off-2016.06.01", p.index.Render(&fields), "field values are not formatted")
+
+	err := ptesting.New(New()).Configure(map[string]interface{}{"index": "logs-%Q"})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "output-elasticsearch2 : index : unknown strftime directive %Q")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 )
 
@@ -17,33 +18,59 @@ func New() veino.Processor {
 type processor struct {
 	processors.Base
 
+	// The path to the file to write. Event fields and date formats can be
+	// used here, like "/var/log/%{host}/%{+YYYY-MM-dd}.log"
 	Path           string
 	Flush_interval interface{} // maybe a cron style or a number
+
+	// The codec used to write events, by default the message of each event
+	// is written on its own line
+	Codec codec.Codec
+
+	encoder codec.Encoder
+	path    *processors.Template
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
-	return p.ConfigureAndValidate(ctx, conf, p)
+	p.Codec = codec.Codec{Name: "line", Options: map[string]interface{}{"format": "%{message}"}}
+	if err := p.ConfigureAndValidate(ctx, conf, p); err != nil {
+		return err
+	}
+
+	var err error
+	if p.path, err = processors.NewTemplate(p.Path); err != nil {
+		return err
+	}
+	p.encoder, err = p.Codec.NewEncoder()
+	return err
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
+	data, err := p.encoder.Encode(processors.WithoutMetadata(*e.Fields()))
+	if err != nil {
+		return err
+	}
+
+	path := p.path.Render(e.Fields())
+
 	// When agent is Interval, only memorize e
 	if p.Flush_interval != nil {
-		lines["global"] = append(lines["global"], e.Message())
+		lines[path] = append(lines[path], string(data))
 		return nil
 	}
 
-	writeToFile(p.Path, e.Message())
+	writeToFile(path, string(data))
 	return nil
 }
 
 func (p *processor) Tick(e veino.IPacket) error {
-	if len(lines["global"]) == 0 {
-		return nil
+	for path, content := range lines {
+		if len(content) == 0 {
+			continue
+		}
+		writeToFile(path, strings.Join(content, ""))
+		delete(lines, path)
 	}
-
-	content := strings.Join(lines["global"], "\n")
-	writeToFile(p.Path, content)
-	lines["global"] = []string{}
 	return nil
 }
 
@@ -54,7 +81,7 @@ func writeToFile(path string, content string) {
 	}
 	defer f.Close()
 
-	if _, err = f.WriteString(content + "\n"); err != nil {
+	if _, err = f.WriteString(content); err != nil {
 		panic(err)
 	}
 }
@@ -6,6 +6,7 @@ package mongodb
 
 import (
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 	"gopkg.in/mgo.v2"
 )
@@ -23,10 +24,10 @@ type processor struct {
 }
 
 type options struct {
-	// The codec used for output data. Output codecs are a convenient method
-	// for encoding your data before it leaves the output, without needing a
-	// separate filter in your veino pipeline
-	Codec string
+	// The codec used for output data. Events are inserted as documents, with
+	// their native field types, the only codec is json
+	// @default : "json"
+	Codec codec.Codec
 
 	// The collection to use. This value can use %{foo} values to dynamically
 	// select a collection based on data in the event
@@ -56,7 +57,19 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 	p.opt.Retry_delay = 3
 	p.opt.Isodate = false
 	p.opt.GenerateId = false
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	p.opt.Codec = codec.New("json")
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	if p.opt.Codec.Name != "json" {
+		return &processors.OptionError{
+			Processor: "output-mongodb",
+			Key:       "codec",
+			Reason:    "events are inserted as documents, " + p.opt.Codec.Name + " is not supported, use json",
+		}
+	}
+	return nil
 }
 
 func (p *processor) Start(e veino.IPacket) error {
@@ -74,9 +87,7 @@ func (p *processor) Start(e veino.IPacket) error {
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-	err := p.collection.Insert(e.Fields())
-
-	return err
+	return p.collection.Insert(processors.WithoutMetadata(*e.Fields()))
 }
 
 func (p *processor) Stop(e veino.IPacket) error {
@@ -0,0 +1,17 @@
+package mongodb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ptesting "github.com/veino/processors/testing"
+)
+
+func TestConfigureCodec(t *testing.T) {
+	assert.Nil(t, ptesting.New(New()).Configure(map[string]interface{}{"database": "logs", "collection": "events"}))
+
+	err := ptesting.New(New()).Configure(map[string]interface{}{"codec": "plain"})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "output-mongodb : codec : events are inserted as documents, plain is not supported, use json", err.Error())
+	}
+}
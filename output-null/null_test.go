@@ -4,58 +4,54 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/veino/runtime/testutils"
+	ptesting "github.com/veino/processors/testing"
 )
 
 func TestNew(t *testing.T) {
-	p := New(nil)
+	p := New()
 	_, ok := p.(*processor)
-	assert.Equal(t, ok, true, "New(nil) should return a processor struct")
+	assert.Equal(t, ok, true, "New() should return a processor struct")
 }
 
 func TestConfigure(t *testing.T) {
-	p := New(nil).(*processor)
+	h := ptesting.New(New())
 	conf := map[string]interface{}{}
-	ret := p.Configure(conf)
+	ret := h.Configure(conf)
 	assert.Equal(t, ret, nil, "")
 }
 
 func TestReceive(t *testing.T) {
-	p := New(nil)
-	em := testutils.NewTestEvent("sourceAgentName", "a log message", nil)
+	h := ptesting.New(New())
+	h.Configure(map[string]interface{}{})
 
-	ret := p.Receive(&em)
+	ret := h.Receive("a log message", nil)
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
 
 func TestStart(t *testing.T) {
-	p := New(nil)
-	em := testutils.NewTestEvent("sourceAgentName", "a log message", nil)
+	h := ptesting.New(New())
+	h.Configure(map[string]interface{}{})
 
-	ret := p.Start(&em)
+	ret := h.Start()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
 
 func TestStop(t *testing.T) {
-	p := New(nil)
-	em := testutils.NewTestEvent("sourceAgentName", "a log message", nil)
+	h := ptesting.New(New())
+	h.Configure(map[string]interface{}{})
 
-	ret := p.Stop(&em)
+	ret := h.Stop()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
 
 func TestTick(t *testing.T) {
-	p := New(nil)
-	em := testutils.NewTestEvent("sourceAgentName", "a log message", nil)
+	h := ptesting.New(New())
+	h.Configure(map[string]interface{}{})
 
-	ret := p.Tick(&em)
+	ret := h.Tick()
 	assert.Equal(t, nil, ret, "")
-	em.AssertNotCalled(t, "Pipe")
-	em.AssertNotCalled(t, "Send")
+	h.AssertNothingSent(t)
 }
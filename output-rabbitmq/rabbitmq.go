@@ -3,10 +3,12 @@ package rabbitmqoutput
 import (
 	"crypto/tls"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/streadway/amqp"
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/veino"
 )
 
@@ -17,9 +19,11 @@ func New() veino.Processor {
 type processor struct {
 	processors.Base
 
-	opt  *options
-	conn *amqp.Connection
-	ch   *amqp.Channel
+	opt     *options
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	encoder codec.Encoder
+	key     *processors.Template
 }
 
 type options struct {
@@ -29,6 +33,9 @@ type options struct {
 	// Extra rabbitmq arguments. Default value is {}
 	Arguments amqp.Table `mapstructure:"arguments"`
 
+	// The codec used to encode message bodies. Default value is "json"
+	Codec codec.Codec `mapstructure:"codec"`
+
 	// Time in seconds to wait before retrying a connection. Default value is 1
 	ConnectRetryInterval int `mapstructure:"connect_retry_interval"`
 
@@ -44,8 +51,8 @@ type options struct {
 	// The name of the exchange to send message to. There is no default value for this setting.
 	Exchange string `mapstructure:"exchange" validate:"required"`
 
-	// The exchange type (fanout, topic, direct). There is no default value for this setting.
-	ExchangeType string `mapstructure:"exchange_type" validate:"required"`
+	// The exchange type (fanout, topic, direct, headers). There is no default value for this setting.
+	ExchangeType string `mapstructure:"exchange_type" validate:"required" enum:"fanout,topic,direct,headers"`
 
 	// Interval (in second) to send heartbeat to rabbitmq. Default value is 0
 	// If value if lower than 1, server's interval setting will be used.
@@ -57,7 +64,7 @@ type options struct {
 	// The routing key to use when binding a queue to the exchange. Default value is ""
 	// This is only relevant for direct or topic exchanges (Routing keys are ignored on fanout exchanges).
 	// This setting can be dynamic using the %{foo} syntax.
-	Key string `mapstructure:"host"`
+	Key string `mapstructure:"key"`
 
 	// Use queue passively declared, meaning it must already exist on the server. Default value is false
 	// To have Logfan to create the queue if necessary leave this option as false.
@@ -92,6 +99,7 @@ type options struct {
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
 	defaults := options{
+		Codec:                codec.New("json"),
 		ConnectRetryInterval: 1,
 		ConnectionTimeout:    0,
 		Durable:              true,
@@ -105,14 +113,22 @@ func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interf
 		Vhost:                "/",
 	}
 	p.opt = &defaults
-	return p.ConfigureAndValidate(ctx, conf, p.opt)
+	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
+		return err
+	}
+
+	var err error
+	if p.key, err = processors.NewTemplate(p.opt.Key); err != nil {
+		return err
+	}
+	p.encoder, err = p.opt.Codec.NewEncoder()
+	return err
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-	key := p.opt.Key
-	processors.Dynamic(&key, e.Fields())
+	key := p.key.Render(e.Fields())
 
-	body, err := e.Fields().Json()
+	body, err := p.encoder.Encode(processors.WithoutMetadata(*e.Fields()))
 	if err != nil {
 		return err
 	}
@@ -124,7 +140,7 @@ func (p *processor) Receive(e veino.IPacket) error {
 		false, // immediate
 		amqp.Publishing{
 			Headers:         amqp.Table{},
-			ContentType:     "application/json",
+			ContentType:     contentType(p.opt.Codec.Name),
 			ContentEncoding: "",
 			Body:            body,
 			DeliveryMode:    amqp.Transient, // 1=non-persistent, 2=persistent
@@ -148,10 +164,16 @@ func (p *processor) Stop(e veino.IPacket) error {
 
 func (p *processor) setup() (*amqp.Connection, *amqp.Channel, error) {
 	scheme := map[bool]string{true: "amqps", false: "amqp"}[p.opt.SSL]
-	url := fmt.Sprintf("%s://%s:%s@%s:%d/%s", scheme, p.opt.User, p.opt.Password, p.opt.Host, p.opt.Port, p.opt.Vhost)
+	// credentials are escaped, and never logged
+	uri := url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(p.opt.User, p.opt.Password),
+		Host:   fmt.Sprintf("%s:%d", p.opt.Host, p.opt.Port),
+		Path:   "/" + p.opt.Vhost,
+	}
 
 	if p.opt.Debug {
-		p.Logger.Printf("Connecting to %s", url)
+		p.Logger.Info("connecting", "host", p.opt.Host, "port", p.opt.Port, "vhost", p.opt.Vhost)
 	}
 
 	amqpConfig := amqp.Config{Heartbeat: time.Duration(p.opt.Heartbeat) * time.Second}
@@ -159,7 +181,7 @@ func (p *processor) setup() (*amqp.Connection, *amqp.Channel, error) {
 		amqpConfig.TLSClientConfig = &tls.Config{InsecureSkipVerify: !p.opt.VerifySSL}
 	}
 
-	conn, err := amqp.DialConfig(url, amqpConfig)
+	conn, err := amqp.DialConfig(uri.String(), amqpConfig)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -185,3 +207,15 @@ func (p *processor) setup() (*amqp.Connection, *amqp.Channel, error) {
 
 	return conn, ch, nil
 }
+
+// contentType returns the mime type of messages encoded by the named codec
+func contentType(codecName string) string {
+	switch codecName {
+	case "json", "json_lines":
+		return "application/json"
+	case "msgpack":
+		return "application/msgpack"
+	default:
+		return "text/plain"
+	}
+}
@@ -1,11 +1,11 @@
 package stdout
 
 import (
-	"fmt"
 	"net/http"
+	"os"
 
-	"github.com/k0kubun/pp"
 	"github.com/veino/processors"
+	"github.com/veino/processors/codec"
 	"github.com/veino/runtime/memory"
 	"github.com/veino/veino"
 )
@@ -22,7 +22,12 @@ func New() veino.Processor {
 }
 
 type options struct {
-	Codec string `validate:"required"`
+	// The codec used for output data, any codec able to encode can be used.
+	// "pp" is an alias of "rubydebug"
+	//
+	// @metadata is written with the "rubydebug" codec only
+	// @default : "line"
+	Codec codec.Codec
 }
 
 type processor struct {
@@ -30,40 +35,42 @@ type processor struct {
 
 	Memory *memory.Memory
 	// WebHook *veino.WebHook
-	opt *options
+	opt     *options
+	encoder codec.Encoder
 }
 
 func (p *processor) Configure(ctx veino.ProcessorContext, conf map[string]interface{}) error {
-	p.opt.Codec = CODEC_LINE
+	p.opt.Codec = codec.New(CODEC_LINE)
 	if err := p.ConfigureAndValidate(ctx, conf, p.opt); err != nil {
 		return err
 	}
 
-	if p.opt.Codec == CODEC_RUBYDEBUG {
-		p.opt.Codec = CODEC_PRETTYPRINT
+	if p.opt.Codec.Name == CODEC_PRETTYPRINT {
+		p.opt.Codec.Name = CODEC_RUBYDEBUG
 	}
 
-	return nil
+	var err error
+	p.encoder, err = p.opt.Codec.NewEncoder()
+	return err
 }
 
 func (p *processor) Receive(e veino.IPacket) error {
-	switch p.opt.Codec {
-	case CODEC_LINE:
-		fmt.Printf("%s %s %s\n",
-			e.Fields().ValueOrEmptyForPathString("@timestamp"),
-			e.Fields().ValueOrEmptyForPathString("host"),
-			e.Message(),
-		)
-	case CODEC_JSON:
-		json, _ := e.Fields().Json()
-		fmt.Printf("%s\n", json)
-		break
-	case CODEC_PRETTYPRINT:
-		pp.Printf("%s\n", e.Fields())
-		break
-	default:
-		p.Logger.Printf("unknow codec %s", p.opt.Codec)
+	fields := map[string]interface{}(*e.Fields())
+	if p.opt.Codec.Name != CODEC_RUBYDEBUG {
+		fields = processors.WithoutMetadata(fields)
+	}
+
+	data, err := p.encoder.Encode(fields)
+	if err != nil {
+		p.Logger.Warn("codec error", "codec", p.opt.Codec, "error", err)
+		return nil
+	}
+
+	// each event is written on its own line
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		data = append(data, '\n')
 	}
+	os.Stdout.Write(data)
 
 	p.Memory.Set("", e.Fields().StringIndentNoTypeInfo(2))
 	return nil
@@ -0,0 +1,40 @@
+package processors
+
+import (
+	"time"
+
+	"github.com/clbanning/mxj"
+	"github.com/veino/veino"
+)
+
+// packet is a veino.IPacket living in memory, it is used when the runtime
+// does not provide a PacketBuilder
+type packet struct {
+	fields mxj.Map
+}
+
+// NewPacket builds a packet holding message and fields. The message is set
+// into the "message" field, and "@timestamp" is set to now when missing.
+func NewPacket(message string, fields map[string]interface{}) veino.IPacket {
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	if _, ok := fields["@timestamp"]; !ok {
+		fields["@timestamp"] = time.Now().Format(veino.VeinoTime)
+	}
+	fields["message"] = message
+
+	return &packet{fields: mxj.Map(fields)}
+}
+
+func (p *packet) Message() string {
+	return p.fields.ValueOrEmptyForPathString("message")
+}
+
+func (p *packet) SetMessage(message string) {
+	p.fields["message"] = message
+}
+
+func (p *packet) Fields() *mxj.Map {
+	return &p.fields
+}
@@ -0,0 +1,272 @@
+// Package schema documents processors from their descriptor : it reads
+// descriptions from the processor's source and renders the descriptor as a
+// JSON Schema or as Markdown.
+//
+//	d, _ := processors.Describe(grok.New())
+//	schema.Document(d, "filter-grok")
+//	b, _ := schema.JSONSchema(d)
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/veino/processors"
+)
+
+// Document sets the descriptions of d and of its options from the doc
+// comments of the processor's source in dir.
+//
+// The processor description is the package doc, an option description is the
+// doc comment of its struct field. A "@default : value" line of a field
+// comment sets the option default when Configure does not set one.
+func Document(d *processors.Descriptor, dir string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]string{}
+	for _, pkg := range pkgs {
+		if d.Description == "" {
+			d.Description = strings.TrimSpace(doc.New(pkg, dir, doc.AllDecls).Doc)
+		}
+		for _, f := range pkg.Files {
+			if docs := optionsDocs(f, d); len(docs) > len(fields) {
+				fields = docs
+			}
+		}
+	}
+
+	for _, o := range d.Options {
+		description, def := parseComment(fields[o.Field])
+		o.Description = description
+		if o.Default == nil && def != nil {
+			o.Default = def
+		}
+	}
+	return nil
+}
+
+// optionsDocs returns the field comments of the struct of f holding the most
+// options of d
+func optionsDocs(f *ast.File, d *processors.Descriptor) map[string]string {
+	names := map[string]bool{}
+	for _, o := range d.Options {
+		names[o.Field] = true
+	}
+
+	best := map[string]string{}
+	bestCount := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		st, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+
+		docs := map[string]string{}
+		count := 0
+		for _, field := range st.Fields.List {
+			for _, name := range field.Names {
+				if !names[name.Name] {
+					continue
+				}
+				count++
+				if field.Doc != nil {
+					docs[name.Name] = field.Doc.Text()
+				}
+			}
+		}
+		if count > bestCount {
+			best, bestCount = docs, count
+		}
+		return true
+	})
+	return best
+}
+
+// parseComment splits a field comment into its description and the value of
+// its "@default :" line, decoded as JSON when possible
+func parseComment(comment string) (string, interface{}) {
+	var def interface{}
+	lines := []string{}
+	for _, line := range strings.Split(comment, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "@default") {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+			continue
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(trimmed, "@default"))
+		value = strings.TrimSpace(strings.TrimPrefix(value, ":"))
+		if err := json.Unmarshal([]byte(value), &def); err != nil {
+			def = value
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), def
+}
+
+// JSONSchema returns the JSON Schema (draft 4) of the processor's options
+func JSONSchema(d *processors.Descriptor) ([]byte, error) {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, o := range d.Options {
+		p := typeSchema(o.Type, o.Items)
+		if o.Description != "" {
+			p["description"] = o.Description
+		}
+		if o.Default != nil {
+			p["default"] = o.Default
+		}
+		if len(o.Enum) > 0 {
+			if o.Type == "array" {
+				p["items"] = map[string]interface{}{"type": "string", "enum": o.Enum}
+			} else if o.Type == "hash" {
+				p["additionalProperties"] = map[string]interface{}{"type": "string", "enum": o.Enum}
+			} else {
+				p["enum"] = o.Enum
+			}
+		}
+		properties[o.Name] = p
+		if o.Required {
+			required = append(required, o.Name)
+		}
+	}
+
+	s := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-04/schema#",
+		"title":                d.Name,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if d.Description != "" {
+		s["description"] = d.Description
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func typeSchema(typ string, items string) map[string]interface{} {
+	switch typ {
+	case "string", "integer", "number", "boolean":
+		return map[string]interface{}{"type": typ}
+	case "array":
+		s := map[string]interface{}{"type": "array"}
+		if items != "" && items != "any" {
+			s["items"] = typeSchema(items, "")
+		}
+		return s
+	case "hash":
+		s := map[string]interface{}{"type": "object"}
+		if items != "" && items != "any" {
+			s["additionalProperties"] = typeSchema(items, "")
+		}
+		return s
+	case "duration":
+		return map[string]interface{}{"type": []string{"string", "number"}}
+	case "any", "":
+		return map[string]interface{}{}
+	}
+	// types set from a string or from a hash, as codec
+	return map[string]interface{}{"anyOf": []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"type": "object"},
+	}}
+}
+
+// Markdown returns the processor's description followed by an Options
+// section describing each option
+func Markdown(d *processors.Descriptor) []byte {
+	var buf bytes.Buffer
+	if d.Description != "" {
+		buf.WriteString(d.Description + "\n\n")
+	}
+
+	buf.WriteString("## Options\n\n")
+	if len(d.Options) == 0 {
+		buf.WriteString("This processor has no options.\n")
+		return buf.Bytes()
+	}
+
+	buf.WriteString("| option | type | required | default |\n")
+	buf.WriteString("|--------|------|----------|---------|\n")
+	for _, o := range d.Options {
+		fmt.Fprintf(&buf, "| [%s](#%s) | %s | %s | %s |\n", o.Name, anchor(o.Name), typeString(o), yesNo(o.Required), defaultString(o.Default))
+	}
+
+	for _, o := range d.Options {
+		fmt.Fprintf(&buf, "\n### %s\n\n", o.Name)
+		fmt.Fprintf(&buf, "* type : %s\n", typeString(o))
+		if o.Required {
+			buf.WriteString("* required\n")
+		}
+		if o.Default != nil {
+			fmt.Fprintf(&buf, "* default : %s\n", defaultString(o.Default))
+		}
+		if len(o.Enum) > 0 {
+			fmt.Fprintf(&buf, "* values : `%s`\n", strings.Join(o.Enum, "`, `"))
+		}
+		if o.Description != "" {
+			buf.WriteString("\n" + o.Description + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func typeString(o *processors.OptionDescriptor) string {
+	switch {
+	case o.Type == "array" && o.Items != "" && o.Items != "any":
+		return "array of " + o.Items
+	case o.Type == "hash" && o.Items != "" && o.Items != "any":
+		return "hash of " + o.Items
+	}
+	return o.Type
+}
+
+func defaultString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("`%v`", v)
+	}
+	return "`" + string(b) + "`"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// anchor returns the id GitHub gives to the heading s
+func anchor(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case r == ' ':
+			return '-'
+		}
+		return -1
+	}, s)
+}
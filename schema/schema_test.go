@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veino/processors"
+)
+
+func exampleDescriptor() *processors.Descriptor {
+	return &processors.Descriptor{
+		Name: "example",
+		Options: []*processors.OptionDescriptor{
+			{Name: "host", Field: "Host", Type: "string", Required: true},
+			{Name: "mode", Field: "Mode", Type: "string", Enum: []string{"fast", "slow"}},
+			{Name: "tags", Field: "Tags", Type: "array", Items: "string"},
+			{Name: "undocumented", Field: "Undocumented", Type: "integer", Default: 3},
+			{Name: "codec", Field: "Codec", Type: "codec", Default: "line"},
+		},
+	}
+}
+
+func TestDocument(t *testing.T) {
+	d := exampleDescriptor()
+	if !assert.Nil(t, Document(d, "testdata/example")) {
+		return
+	}
+
+	assert.Equal(t, "Example processor, it does nothing", d.Description)
+	assert.Equal(t, "Host to connect to", d.Option("host").Description)
+	assert.Nil(t, d.Option("host").Default)
+	assert.Equal(t, "Mode of the connection", d.Option("mode").Description)
+	assert.Equal(t, "fast", d.Option("mode").Default, "@default lines should set defaults")
+	assert.Equal(t, []interface{}{"example"}, d.Option("tags").Default)
+	assert.Equal(t, "", d.Option("undocumented").Description)
+	assert.Equal(t, 3, d.Option("undocumented").Default)
+}
+
+func TestJSONSchema(t *testing.T) {
+	d := exampleDescriptor()
+	b, err := JSONSchema(d)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	s := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(b, &s))
+	assert.Equal(t, "example", s["title"])
+	assert.Equal(t, false, s["additionalProperties"])
+	assert.Equal(t, []interface{}{"host"}, s["required"])
+
+	properties := s["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"fast", "slow"}}, properties["mode"])
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}, properties["tags"])
+	assert.Equal(t, map[string]interface{}{"type": "integer", "default": 3.0}, properties["undocumented"])
+	assert.Contains(t, properties["codec"], "anyOf")
+}
+
+func TestMarkdown(t *testing.T) {
+	d := exampleDescriptor()
+	assert.Nil(t, Document(d, "testdata/example"))
+	md := string(Markdown(d))
+
+	assert.Contains(t, md, "Example processor, it does nothing\n\n## Options\n")
+	assert.Contains(t, md, "| [host](#host) | string | yes |  |\n")
+	assert.Contains(t, md, "| [tags](#tags) | array of string | no | `[\"example\"]` |\n")
+	assert.Contains(t, md, "### mode\n\n* type : string\n* default : `\"fast\"`\n* values : `fast`, `slow`\n\nMode of the connection\n")
+
+	assert.Contains(t, string(Markdown(&processors.Descriptor{Name: "empty"})), "This processor has no options.")
+}
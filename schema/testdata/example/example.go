@@ -0,0 +1,17 @@
+// Example processor, it does nothing
+package example
+
+type options struct {
+	// Host to connect to
+	Host string `validate:"required"`
+
+	// Mode of the connection
+	// @default : "fast"
+	Mode string
+
+	// Tags added to each event
+	// @default : ["example"]
+	Tags []string
+
+	Undocumented int
+}
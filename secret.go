@@ -0,0 +1,149 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrSecretNotFound is returned by secret providers which do not hold a secret
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider returns secrets referenced in options as ${secret:name}
+type SecretProvider interface {
+	Secret(name string) (string, error)
+}
+
+// EnvSecrets reads the secret name from the environment variable Prefix+name,
+// the name is upper cased and its dots and dashes replaced by underscores
+// ("db.password" is read from PREFIX_DB_PASSWORD)
+type EnvSecrets struct {
+	Prefix string
+}
+
+// Secret returns the secret name
+func (s EnvSecrets) Secret(name string) (string, error) {
+	key := s.Prefix + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(name))
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// DirSecrets reads the secret name from the file name in the directory, as
+// docker or kubernetes secrets mounted in /run/secrets. A trailing newline is
+// removed
+type DirSecrets string
+
+// Secret returns the secret name
+func (dir DirSecrets) Secret(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(string(dir), name))
+	if os.IsNotExist(err) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// SecretProviders looks up secrets in each provider, in order, and returns
+// the first found
+type SecretProviders []SecretProvider
+
+// Secret returns the secret name
+func (providers SecretProviders) Secret(name string) (string, error) {
+	for _, provider := range providers {
+		value, err := provider.Secret(name)
+		if err != ErrSecretNotFound {
+			return value, err
+		}
+	}
+	return "", ErrSecretNotFound
+}
+
+var secretProvider = struct {
+	sync.RWMutex
+	provider SecretProvider
+}{}
+
+// SetSecretProvider sets the provider of ${secret:name} references, there is
+// none by default
+func SetSecretProvider(p SecretProvider) {
+	secretProvider.Lock()
+	secretProvider.provider = p
+	secretProvider.Unlock()
+}
+
+func lookupSecret(name string) (string, error) {
+	secretProvider.RLock()
+	p := secretProvider.provider
+	secretProvider.RUnlock()
+	if p == nil {
+		return "", errors.New("no secret provider is set")
+	}
+
+	value, err := p.Secret(name)
+	if err == ErrSecretNotFound {
+		return "", fmt.Errorf("secret %q not found", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("secret %q : %s", name, err.Error())
+	}
+	MaskSecret(value)
+	return value, nil
+}
+
+// secrets holds the values masked in logs
+var secrets = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+const secretMask = "******"
+
+// MaskSecret masks value in every log line of Loggers. Secrets read from
+// ${secret:name} references are masked, as are variables expanded in
+// credential options
+func MaskSecret(value string) {
+	if value == "" {
+		return
+	}
+
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range secrets.values {
+		if v == value {
+			return
+		}
+	}
+	// longest first, a secret holding another one is masked as a whole
+	i := 0
+	for i < len(secrets.values) && len(secrets.values[i]) >= len(value) {
+		i++
+	}
+	secrets.values = append(secrets.values, "")
+	copy(secrets.values[i+1:], secrets.values[i:])
+	secrets.values[i] = value
+}
+
+// maskSecrets returns s with secrets replaced by a mask
+func maskSecrets(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, v := range secrets.values {
+		if strings.Contains(s, v) {
+			s = strings.Replace(s, v, secretMask, -1)
+		}
+	}
+	return s
+}
@@ -0,0 +1,195 @@
+package processors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clbanning/mxj"
+	"github.com/veino/veino"
+)
+
+// Template is a string holding references to event's fields, parsed once and
+// rendered for each event.
+//
+//	%{field}, %{[a][b]}     value of the field, non string values are rendered
+//	                        as JSON, missing fields as ""
+//	%{field:-default}       value of the field, or default when the field is
+//	                        missing or empty
+//	%{+YYYY.MM.dd}          event's @timestamp, in UTC, formatted with a
+//	                        Joda-Time pattern
+//	%{+%s}                  event's @timestamp as seconds since epoch
+type Template struct {
+	raw   string
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+
+	ref        FieldReference
+	def        string
+	hasDefault bool
+
+	date  []jodaToken
+	epoch bool
+}
+
+// NewTemplate parses s into a Template
+func NewTemplate(s string) (*Template, error) {
+	t := &Template{raw: s}
+
+	for rest := s; len(rest) > 0; {
+		start := strings.Index(rest, mark)
+		end := -1
+		if start >= 0 {
+			end = strings.IndexByte(rest[start:], '}')
+		}
+		if start < 0 || end < 0 {
+			t.parts = append(t.parts, templatePart{literal: rest})
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:start]})
+		}
+
+		part, err := parseTemplatePart(rest[start+len(mark) : start+end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q : %s", s, err.Error())
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[start+end+1:]
+	}
+
+	return t, nil
+}
+
+func parseTemplatePart(expr string) (templatePart, error) {
+	if strings.HasPrefix(expr, "+") {
+		if expr == "+%s" {
+			return templatePart{epoch: true}, nil
+		}
+		return templatePart{date: parseJoda(expr[1:])}, nil
+	}
+
+	part := templatePart{}
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		part.def = expr[i+2:]
+		part.hasDefault = true
+		expr = expr[:i]
+	}
+
+	ref, err := ParseFieldReference(expr)
+	if err != nil {
+		return part, err
+	}
+	part.ref = ref
+	return part, nil
+}
+
+// Render returns the template evaluated against the event's fields
+func (t *Template) Render(fields *mxj.Map) string {
+	if len(t.parts) == 1 && t.parts[0].isLiteral() {
+		return t.parts[0].literal
+	}
+
+	var buf bytes.Buffer
+	for _, part := range t.parts {
+		switch {
+		case part.isLiteral():
+			buf.WriteString(part.literal)
+		case part.epoch:
+			buf.WriteString(strconv.FormatInt(Timestamp(fields).Unix(), 10))
+		case part.date != nil:
+			buf.WriteString(formatJoda(Timestamp(fields).UTC(), part.date))
+		default:
+			buf.WriteString(part.render(fields))
+		}
+	}
+	return buf.String()
+}
+
+func (part templatePart) isLiteral() bool {
+	return part.ref == nil && part.date == nil && !part.epoch
+}
+
+func (part templatePart) render(fields *mxj.Map) string {
+	value, err := part.ref.Get(fields)
+	if err != nil || value == nil || value == "" {
+		return part.def
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(veino.VeinoTime)
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
+
+// String returns the template as written
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Timestamp returns the event's @timestamp, or now when the event has no
+// valid @timestamp
+func Timestamp(fields *mxj.Map) time.Time {
+	value, err := GetField(fields, "@timestamp")
+	if err != nil {
+		return time.Now()
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range []string{veino.VeinoTime, time.RFC3339Nano} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Now()
+}
+
+// templates caches templates parsed by Dynamic, it is reset when it grows too
+// much as Dynamic may be called with strings built from events
+var templates = struct {
+	sync.RWMutex
+	m map[string]*Template
+}{m: map[string]*Template{}}
+
+const maxCachedTemplates = 1024
+
+func cachedTemplate(s string) (*Template, error) {
+	templates.RLock()
+	t, ok := templates.m[s]
+	templates.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	t, err := NewTemplate(s)
+	if err != nil {
+		return nil, err
+	}
+
+	templates.Lock()
+	if len(templates.m) >= maxCachedTemplates {
+		templates.m = map[string]*Template{}
+	}
+	templates.m[s] = t
+	templates.Unlock()
+	return t, nil
+}
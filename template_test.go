@@ -0,0 +1,64 @@
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clbanning/mxj"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	fields := mxj.Map{
+		"@timestamp": "2016-06-01T22:03:04.5+02:00",
+		"name":       "Valere",
+		"empty":      "",
+		"count":      float64(3),
+		"ratio":      0.5,
+		"ok":         true,
+		"tags":       []string{"a", "b"},
+		"location":   map[string]interface{}{"city": "Paris"},
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"no reference", "no reference"},
+		{"", ""},
+		{"Hello %{name} !", "Hello Valere !"},
+		{"%{[location][city]}/%{location.city}", "Paris/Paris"},
+		{"%{unknow}|%{unknow:-none}|%{empty:-none}|%{name:-none}", "|none|none|Valere"},
+		{"%{count} %{ratio} %{ok}", "3 0.5 true"},
+		{"%{tags} %{[tags][1]}", `["a","b"] b`},
+		{"%{location}", `{"city":"Paris"}`},
+		{"logstash-%{+YYYY.MM.dd}", "logstash-2016.06.01"},
+		{"%{+yy-M-d HH:mm:ss.SSS}", "16-6-1 20:03:04.500"},
+		{"%{+EEE MMM dd 'week' ww, DDD}", "Wed Jun 01 week 22, 153"},
+		{"%{+%s}", "1464811384"},
+		{"%{name", "%{name"},
+	}
+
+	for _, test := range tests {
+		tpl, err := NewTemplate(test.template)
+		if !assert.Nil(t, err, test.template) {
+			continue
+		}
+		assert.Equal(t, test.expected, tpl.Render(&fields), test.template)
+		assert.Equal(t, test.template, tpl.String())
+	}
+
+	_, err := NewTemplate("%{[location}")
+	assert.NotNil(t, err, "invalid reference")
+}
+
+func TestTimestamp(t *testing.T) {
+	fields := mxj.Map{"@timestamp": "2016-06-01T10:00:00Z"}
+	assert.Equal(t, time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC), Timestamp(&fields).UTC())
+
+	fields = mxj.Map{"@timestamp": time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)}
+	assert.Equal(t, 2016, Timestamp(&fields).Year())
+
+	fields = mxj.Map{}
+	assert.WithinDuration(t, time.Now(), Timestamp(&fields), time.Minute)
+}
@@ -0,0 +1,242 @@
+// testing package helps unit testing processors without a veino runtime.
+//
+// A Harness builds a veino.ProcessorContext with a PacketSender recording
+// every packet per output port, a real PacketBuilder and a logger writing to
+// a buffer. It drives the processor's Configure, Start, Tick, Receive and Stop
+// and exposes what was emitted.
+//
+//	h := testing.New(mutate.New())
+//	h.Configure(map[string]interface{}{"uppercase": []string{"name"}})
+//	h.Receive("hello", map[string]interface{}{"name": "alice"})
+//	h.AssertSentCount(t, 0, 1)
+//	h.AssertField(t, h.Sent(0)[0], "name", "ALICE")
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/veino/processors"
+	"github.com/veino/veino"
+)
+
+// TB is the part of testing.TB used by assertion helpers
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Harness drives a processor and records what it emits
+type Harness struct {
+	Processor veino.Processor
+
+	mu   sync.Mutex
+	sent map[int][]veino.IPacket
+	logs *bytes.Buffer
+}
+
+// New returns a harness for the processor p
+func New(p veino.Processor) *Harness {
+	return &Harness{
+		Processor: p,
+		sent:      map[int][]veino.IPacket{},
+		logs:      &bytes.Buffer{},
+	}
+}
+
+// Context returns a ProcessorContext wired to the harness
+func (h *Harness) Context() veino.ProcessorContext {
+	return veino.ProcessorContext{
+		Logger: func() *log.Logger {
+			return log.New(&lockedWriter{mu: &h.mu, w: h.logs}, "", 0)
+		},
+		PacketSender: func() veino.PacketSender {
+			return h.send
+		},
+		PacketBuilder: func() veino.PacketBuilder {
+			return NewPacket
+		},
+	}
+}
+
+// NewPacket builds a packet, as the runtime's PacketBuilder would do
+func NewPacket(message string, fields map[string]interface{}) veino.IPacket {
+	return processors.NewPacket(message, fields)
+}
+
+// send records packet e on each port, a packet sent without port goes to
+// port 0
+func (h *Harness) send(e veino.IPacket, ports ...int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(ports) == 0 {
+		ports = []int{0}
+	}
+	for _, port := range ports {
+		h.sent[port] = append(h.sent[port], e)
+	}
+	return true
+}
+
+// Configure configures the processor with conf
+func (h *Harness) Configure(conf map[string]interface{}) error {
+	return h.Processor.Configure(h.Context(), conf)
+}
+
+// Start starts the processor
+func (h *Harness) Start() error {
+	return h.Processor.Start(NewPacket("", nil))
+}
+
+// Tick ticks the processor
+func (h *Harness) Tick() error {
+	return h.Processor.Tick(NewPacket("", nil))
+}
+
+// Stop stops the processor
+func (h *Harness) Stop() error {
+	return h.Processor.Stop(NewPacket("", nil))
+}
+
+// Receive builds a packet from message and fields and hands it to the
+// processor, with processors.Receive as the runtime does
+func (h *Harness) Receive(message string, fields map[string]interface{}) error {
+	return processors.Receive(h.Processor, NewPacket(message, fields))
+}
+
+// ReceivePacket hands packet e to the processor
+func (h *Harness) ReceivePacket(e veino.IPacket) error {
+	return processors.Receive(h.Processor, e)
+}
+
+// Sent returns packets sent on port, in sending order
+func (h *Harness) Sent(port int) []veino.IPacket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]veino.IPacket{}, h.sent[port]...)
+}
+
+// SentCount returns the number of packets sent on all ports
+func (h *Harness) SentCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, packets := range h.sent {
+		n += len(packets)
+	}
+	return n
+}
+
+// Logs returns what the processor logged
+func (h *Harness) Logs() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.logs.String()
+}
+
+// Reset forgets sent packets and logs
+func (h *Harness) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sent = map[int][]veino.IPacket{}
+	h.logs.Reset()
+}
+
+// AssertSentCount checks n packets were sent on port
+func (h *Harness) AssertSentCount(t TB, port int, n int) bool {
+	t.Helper()
+	if got := len(h.Sent(port)); got != n {
+		t.Errorf("expected %d packet(s) sent on port %d, got %d", n, port, got)
+		return false
+	}
+	return true
+}
+
+// AssertNothingSent checks no packet was sent on any port
+func (h *Harness) AssertNothingSent(t TB) bool {
+	t.Helper()
+	if n := h.SentCount(); n != 0 {
+		t.Errorf("expected no packet sent, got %d", n)
+		return false
+	}
+	return true
+}
+
+// AssertField checks the value at path in e's fields
+func (h *Harness) AssertField(t TB, e veino.IPacket, path string, expected interface{}) bool {
+	t.Helper()
+	value, err := e.Fields().ValueForPath(path)
+	if err != nil {
+		t.Errorf("field %s not found in %v", path, *e.Fields())
+		return false
+	}
+	if !reflect.DeepEqual(expected, value) {
+		t.Errorf("field %s : expected %#v, got %#v", path, expected, value)
+		return false
+	}
+	return true
+}
+
+// AssertNoField checks path does not exist in e's fields
+func (h *Harness) AssertNoField(t TB, e veino.IPacket, path string) bool {
+	t.Helper()
+	if e.Fields().Exists(path) {
+		value, _ := e.Fields().ValueForPath(path)
+		t.Errorf("field %s should not exist, got %#v", path, value)
+		return false
+	}
+	return true
+}
+
+// AssertTags checks e holds each of the tags
+func (h *Harness) AssertTags(t TB, e veino.IPacket, tags ...string) bool {
+	t.Helper()
+	current := fmt.Sprintf("%v", tagsOf(e))
+	for _, tag := range tags {
+		if !hasTag(e, tag) {
+			t.Errorf("tag %s not found in %s", tag, current)
+			return false
+		}
+	}
+	return true
+}
+
+// AssertNoTags checks e holds none of the tags
+func (h *Harness) AssertNoTags(t TB, e veino.IPacket, tags ...string) bool {
+	t.Helper()
+	for _, tag := range tags {
+		if hasTag(e, tag) {
+			t.Errorf("tag %s should not be set, got %v", tag, tagsOf(e))
+			return false
+		}
+	}
+	return true
+}
+
+func tagsOf(e veino.IPacket) []string {
+	return processors.Tags(e.Fields())
+}
+
+func hasTag(e veino.IPacket, tag string) bool {
+	for _, t := range tagsOf(e) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// lockedWriter serializes writes of concurrent processors' goroutines
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
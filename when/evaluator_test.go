@@ -4,7 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/veino/runtime/testutils"
+	ptesting "github.com/veino/processors/testing"
 	"github.com/veino/veino"
 	"github.com/vjeantet/govaluate"
 )
@@ -36,7 +36,7 @@ func getTestEvent() veino.IPacket {
 		},
 	}
 
-	return testutils.NewTestEvent("test", "test", m)
+	return ptesting.NewPacket("test", m)
 
 }
 
@@ -142,3 +142,33 @@ func TestExpressionBRoker(t *testing.T) {
 	_, err := p.assertExpressionWithFields(0, expression, event)
 	assert.NotNil(t, err, "err is not nil")
 }
+
+func TestFieldReferences(t *testing.T) {
+	tests := []string{
+		`[location][city] == "Paris"`,
+		`location.city == "Paris"`,
+		`[tags][0] == "mytag"`,
+		`[tags][-1] == "_dateparsefailure"`,
+		`tags[1] == "_grokparsefailure"`,
+		`[user.name] == "valere"`,
+		`user\.name == "valere"`,
+		`[way] =~ /^[A-Z]+$/`,
+		`[name] == "[location][city]"`,
+		`[testInt] in [3, 4]`,
+		`[@metadata][index] == "logs"`,
+		`@metadata.index == "logs"`,
+	}
+
+	for _, expression := range tests {
+		event := getTestEvent()
+		(*event.Fields())["user.name"] = "valere"
+		(*event.Fields())["name"] = "[location][city]"
+		(*event.Fields())["testInt"] = 4.0
+		(*event.Fields())["@metadata"] = map[string]interface{}{"index": "logs"}
+
+		p := &processor{compiledExpressions: map[int]*govaluate.EvaluableExpression{}}
+		result, err := p.assertExpressionWithFields(0, expression, event)
+		assert.Nil(t, err, expression)
+		assert.True(t, result, expression)
+	}
+}
@@ -2,6 +2,8 @@
 package when
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -54,7 +56,7 @@ func (p *processor) Receive(e veino.IPacket) error {
 
 		result, err := p.assertExpressionWithFields(order, expressionValue, e)
 		if err != nil {
-			p.Logger.Printf("When processor evaluation error : %s\n", err.Error())
+			p.Logger.Warn("evaluation error", "expression", expressionValue, "error", err)
 			continue
 		}
 
@@ -76,7 +78,7 @@ func (p *processor) assertExpressionWithFields(index int, expressionValue string
 	parameters := make(map[string]interface{})
 	for _, v := range expression.Tokens() {
 		if v.Kind == govaluate.VARIABLE {
-			paramValue, err := e.Fields().ValueForPath(v.Value.(string))
+			paramValue, err := processors.GetField(e.Fields(), v.Value.(string))
 			if err != nil {
 				return false, fmt.Errorf("conditional field not found : %s", err.Error())
 			}
@@ -94,7 +96,10 @@ func (p *processor) cacheExpression(index int, expressionValue string) (*govalua
 		return e, nil
 	}
 
-	expressionValue = strings.Replace(expressionValue, `][`, `.`, -1)
+	expressionValue, err := rewriteFieldReferences(expressionValue)
+	if err != nil {
+		return nil, err
+	}
 	expression, err := govaluate.NewEvaluableExpression(expressionValue)
 	if err != nil {
 		return nil, err
@@ -103,3 +108,116 @@ func (p *processor) cacheExpression(index int, expressionValue string) (*govalua
 
 	return expression, nil
 }
+
+// rewriteFieldReferences turns each field reference of the expression, as
+// "[location][city]" or "location.city", into a single govaluate variable
+// named with the reference, as processors.GetField understands it.
+// Strings, regexps and arrays as [1, 2] are left untouched.
+func rewriteFieldReferences(expression string) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := closingIndex(expression, i, c)
+			buf.WriteString(expression[i:end])
+			i = end
+		case c == '/' && strings.HasSuffix(strings.TrimSpace(buf.String()), "~"):
+			end := closingIndex(expression, i, c)
+			buf.WriteString(expression[i:end])
+			i = end
+		case c == '[':
+			end := i
+			for end < len(expression) && expression[end] == '[' {
+				closing := strings.IndexByte(expression[end:], ']')
+				if closing < 0 {
+					return "", fmt.Errorf("unclosed [ in %s", expression)
+				}
+				end += closing + 1
+			}
+			ref := expression[i:end]
+			if isArrayLiteral(ref) {
+				buf.WriteString(ref)
+			} else if err := writeVariable(&buf, ref); err != nil {
+				return "", err
+			}
+			i = end
+		case isIdentifierStart(c) && (i == 0 || !isIdentifierChar(expression[i-1])):
+			end := i
+			for end < len(expression) && (isIdentifierChar(expression[end]) || expression[end] == '.' || expression[end] == '\\') {
+				if expression[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			for end < len(expression) && expression[end] == '[' {
+				closing := strings.IndexByte(expression[end:], ']')
+				if closing < 0 {
+					return "", fmt.Errorf("unclosed [ in %s", expression)
+				}
+				end += closing + 1
+			}
+			ref := expression[i:end]
+			if strings.ContainsAny(ref, ".[\\@") {
+				if err := writeVariable(&buf, ref); err != nil {
+					return "", err
+				}
+			} else {
+				buf.WriteString(ref)
+			}
+			i = end
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	return buf.String(), nil
+}
+
+// writeVariable writes ref as a govaluate escaped variable
+func writeVariable(buf *bytes.Buffer, ref string) error {
+	r, err := processors.ParseFieldReference(ref)
+	if err != nil {
+		return err
+	}
+	buf.WriteByte('[')
+	for _, c := range r.String() {
+		if c == '\\' || c == '[' || c == ']' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(c)
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// closingIndex returns the index following the delimiter closing the string
+// opened at i
+func closingIndex(expression string, i int, delimiter byte) int {
+	for j := i + 1; j < len(expression); j++ {
+		if expression[j] == '\\' {
+			j++
+			continue
+		}
+		if expression[j] == delimiter {
+			return j + 1
+		}
+	}
+	return len(expression)
+}
+
+func isArrayLiteral(ref string) bool {
+	if strings.Count(ref, "[") != 1 {
+		return false
+	}
+	values := []interface{}{}
+	return json.Unmarshal([]byte(ref), &values) == nil
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}